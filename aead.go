@@ -0,0 +1,113 @@
+package gocrypt
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"sync"
+)
+
+// Pool of AEAD instances along with a copy of the key they are built from
+type aeadEntry struct {
+	key  []byte
+	pool sync.Pool
+}
+
+// Function to get the AEAD pool of a key held by a keyCache, creating it if
+// needed
+//
+// Pools are only kept as long as the keyCache (ie. the Encrypter) they
+// belong to: they are dropped along with the cached keys once it's full or
+// cleared, and the copy of the key is wiped.
+//
+//   alg Algorithm - Algorithm of the pool's AEAD instances
+//   key []byte    - Key the pool's AEAD instances are built with
+func (c *keyCache) aeadPool(alg Algorithm, key []byte) *aeadEntry {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The copy of the key hashed is wiped right away
+	buf := append([]byte{byte(alg)}, key...)
+	id := sha256.Sum256(buf)
+	Scrub(buf)
+
+	// Compare the full key as well so a fingerprint collision can never hand
+	// out an AEAD built from a different key
+	if e, ok := c.aeads[id]; ok {
+		if subtle.ConstantTimeCompare(e.key, key) == 1 {
+			return e
+		}
+		c.evictAEAD(id)
+	}
+
+	if len(c.aeadOrder) >= keyCacheSize {
+		c.evictAEAD(c.aeadOrder[0])
+	}
+
+	e := &aeadEntry{key: append([]byte(nil), key...)}
+	c.aeads[id] = e
+	c.aeadOrder = append(c.aeadOrder, id)
+
+	return e
+
+}
+
+// Function to drop an AEAD pool and wipe its copy of the key. Must be
+// called with c locked.
+//
+//   id [32]byte - Fingerprint of the algorithm and key to evict
+func (c *keyCache) evictAEAD(id [sha256.Size]byte) {
+
+	if e, ok := c.aeads[id]; ok {
+		Scrub(e.key)
+		delete(c.aeads, id)
+	}
+
+	for i, o := range c.aeadOrder {
+		if o == id {
+			c.aeadOrder = append(c.aeadOrder[:i], c.aeadOrder[i+1:]...)
+			break
+		}
+	}
+
+}
+
+// Function to get an AEAD for a key
+//
+// With a keyCache the AEAD comes from the pool of the key, so the cipher
+// isn't set up again by every call made with the same Encrypter. Without
+// one it's built for this call only.
+//
+//   alg  Algorithm - Algorithm of the AEAD
+//   key  []byte    - Key to get the AEAD for
+//   keys *keyCache - Cache of the keys in use (nil if none)
+//
+// Returns:
+//
+//   cipher.AEAD - AEAD to seal/open with
+//   func()      - Function to hand the AEAD back once done
+//   error       - Error
+func newAEAD(alg Algorithm, key []byte, keys *keyCache) (cipher.AEAD, func(), error) {
+
+	alg = alg.effective()
+	if keys == nil {
+		aead, err := buildAEAD(alg, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return aead, func() {}, nil
+	}
+
+	e := keys.aeadPool(alg, key)
+	aead, ok := e.pool.Get().(cipher.AEAD)
+	if !ok {
+		var err error
+		if aead, err = buildAEAD(alg, key); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return aead, func() { e.pool.Put(aead) }, nil
+
+}
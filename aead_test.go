@@ -0,0 +1,114 @@
+package gocrypt
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestNewAEADPool(t *testing.T) {
+
+	keys := newKeyCache()
+	key1, key2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	// Instances are never shared between keys
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, other := key1, key2
+			if i%2 == 1 {
+				key, other = key2, key1
+			}
+			for j := 0; j < 100; j++ {
+				aead, release, err := newAEAD(AES256GCM, key, keys)
+				if err != nil {
+					errs <- err
+					return
+				}
+				nonce := make([]byte, aead.NonceSize())
+				sealed := aead.Seal(nil, nonce, []byte(fmt.Sprint(i, j)), nil)
+				release()
+
+				check, release, err := newAEAD(AES256GCM, other, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := check.Open(nil, nonce, sealed, nil); err == nil {
+					errs <- fmt.Errorf("goroutine %d: sealed with the wrong key", i)
+					return
+				}
+				release()
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if n := len(keys.aeads); n != 2 {
+		t.Fatalf("%d pools, want 2", n)
+	}
+
+	// Clearing wipes the copies of the keys
+	var copies [][]byte
+	for _, e := range keys.aeads {
+		copies = append(copies, e.key)
+	}
+	keys.clear()
+	if len(keys.aeads) != 0 {
+		t.Fatal("pools left after clear")
+	}
+	for _, k := range copies {
+		if !bytes.Equal(k, make([]byte, 32)) {
+			t.Fatal("key copy not wiped")
+		}
+	}
+
+}
+
+func TestNewAEADError(t *testing.T) {
+
+	if _, _, err := newAEAD(AES256GCM, []byte("short"), newKeyCache()); err == nil {
+		t.Fatal("built an AEAD from a 5 byte key")
+	}
+	if _, _, err := newAEAD(AES256GCM, []byte("short"), nil); err == nil {
+		t.Fatal("built an AEAD from a 5 byte key")
+	}
+
+}
+
+func BenchmarkNewAEAD(b *testing.B) {
+
+	key := bytes.Repeat([]byte{1}, 32)
+
+	b.Run("built", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, release, err := newAEAD(AES256GCM, key, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			release()
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		keys := newKeyCache()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, release, err := newAEAD(AES256GCM, key, keys)
+			if err != nil {
+				b.Fatal(err)
+			}
+			release()
+		}
+	})
+
+}
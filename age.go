@@ -0,0 +1,187 @@
+package gocrypt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// First line of an age v1 header
+const ageIntro = "age-encryption.org/v1\n"
+
+// Maximum scrypt work factor (log2 N) accepted from an age header
+const ageMaxWorkFactor = 22
+
+// Size of the plaintext carried by each age payload chunk
+const ageChunkSize = 64 * 1024
+
+// Function to decrypt a file encrypted with a passphrase by age (age -p)
+//
+// This is a best-effort interop surface supporting only the scrypt recipient
+// of the age v1 format, which is the one used for passphrase encryption.
+// Files encrypted to public keys are rejected.
+//
+// Variables to pass in:
+//
+//   data []byte - age encrypted data (binary, not armored)
+//   pass string - Passphrase used to encrypt with age
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptAge(data []byte, pass string) ([]byte, error) {
+
+	if !bytes.HasPrefix(data, []byte(ageIntro)) {
+		return nil, errors.New("decrypt age: not an age v1 file")
+	}
+
+	// Header ends with the MAC line starting with "---"
+	end := bytes.Index(data, []byte("\n--- "))
+	if end < 0 {
+		return nil, errors.New("decrypt age: missing header MAC")
+	}
+	macLineEnd := bytes.IndexByte(data[end+1:], '\n')
+	if macLineEnd < 0 {
+		return nil, fmt.Errorf("decrypt age: %w header", ErrTruncated)
+	}
+	macLineEnd += end + 1
+
+	stanza := strings.Split(string(data[len(ageIntro):end+1]), "\n")
+	args := strings.Split(stanza[0], " ")
+	if len(args) != 4 || args[0] != "->" || args[1] != "scrypt" {
+		return nil, errors.New("decrypt age: only passphrase (scrypt) encrypted files are supported")
+	}
+
+	// The scrypt recipient must be the only one
+	body := strings.Join(stanza[1:len(stanza)-1], "")
+	if len(stanza) < 3 || strings.Contains(body, "->") || len(stanza[len(stanza)-2]) >= 64 {
+		return nil, errors.New("decrypt age: invalid scrypt stanza")
+	}
+
+	salt, err := base64.RawStdEncoding.Strict().DecodeString(args[2])
+	if err != nil || len(salt) != 16 {
+		return nil, errors.New("decrypt age: invalid scrypt salt")
+	}
+
+	logN, err := strconv.Atoi(args[3])
+	if err != nil || logN <= 0 || logN > ageMaxWorkFactor || args[3] != strconv.Itoa(logN) {
+		return nil, errors.New("decrypt age: invalid or too large scrypt work factor")
+	}
+
+	wrapped, err := base64.RawStdEncoding.Strict().DecodeString(body)
+	if err != nil || len(wrapped) != 16+chacha20poly1305.Overhead {
+		return nil, errors.New("decrypt age: invalid scrypt stanza body")
+	}
+
+	// Unwrap the file key
+	label := "age-encryption.org/v1/scrypt"
+	wrapKey, err := scrypt.Key([]byte(pass), append([]byte(label), salt...), 1<<logN, 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age: scrypt: %w", err)
+	}
+	defer Scrub(wrapKey)
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age: chacha20-poly1305: %w", err)
+	}
+	// A wrong passphrase fails here
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age: unwrap: %w", ErrAuthFailed)
+	}
+	defer Scrub(fileKey)
+
+	// Check the header MAC
+	mac, err := base64.RawStdEncoding.Strict().DecodeString(string(data[end+5 : macLineEnd]))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age: header: %w", err)
+	}
+	h := hmac.New(sha256.New, ageKey(fileKey, nil, "header"))
+	h.Write(data[:end+4])
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return nil, fmt.Errorf("decrypt age: header MAC: %w", ErrAuthFailed)
+	}
+
+	// Decrypt the payload
+	payload := data[macLineEnd+1:]
+	if len(payload) < 16 {
+		return nil, fmt.Errorf("decrypt age: %w payload", ErrTruncated)
+	}
+	streamKey := ageKey(fileKey, payload[:16], "payload")
+	defer Scrub(streamKey)
+
+	return ageOpenStream(streamKey, payload[16:])
+
+}
+
+// Function to derive an age key from the file key with HKDF-SHA-256
+//
+//   fileKey []byte - age file key
+//   salt    []byte - HKDF salt
+//   info    string - HKDF info
+func ageKey(fileKey []byte, salt []byte, info string) []byte {
+	key := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, fileKey, salt, []byte(info)), key)
+	return key
+}
+
+// Function to decrypt the age STREAM payload
+//
+//   key        []byte - Payload key
+//   ciphertext []byte - Payload without the nonce
+func ageOpenStream(key []byte, ciphertext []byte) ([]byte, error) {
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age: chacha20-poly1305: %w", err)
+	}
+
+	var plaintext []byte
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	frame := ageChunkSize + aead.Overhead()
+	for counter := uint64(0); ; counter++ {
+
+		for i, c := 0, counter; i < chacha20poly1305.NonceSize-1; i++ {
+			nonce[chacha20poly1305.NonceSize-2-i] = byte(c)
+			c >>= 8
+		}
+
+		chunk := ciphertext
+		last := len(chunk) <= frame
+		if !last {
+			chunk = chunk[:frame]
+		}
+		ciphertext = ciphertext[len(chunk):]
+
+		// Only the last chunk may be short and only the first may be empty
+		if last {
+			nonce[chacha20poly1305.NonceSize-1] = 1
+		}
+		out, err := aead.Open(nil, nonce, chunk, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt age: payload: %w", ErrAuthFailed)
+		}
+		if last && len(out) == 0 && counter > 0 {
+			return nil, fmt.Errorf("decrypt age: %w payload: empty last chunk", ErrCorrupted)
+		}
+		plaintext = append(plaintext, out...)
+
+		if last {
+			return plaintext, nil
+		}
+
+	}
+
+}
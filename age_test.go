@@ -0,0 +1,51 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// Files in testdata were encrypted by age v1.1.1 (age -p, work factor 10)
+// with this passphrase
+const agePassphrase = "correct horse battery staple"
+
+func TestDecryptAge(t *testing.T) {
+
+	tests := map[string][]byte{
+		"hello.age":  []byte("hello from age\n"),
+		"chunks.age": bytes.Repeat([]byte("0123456789abcdef"), 5000),
+	}
+	for name, want := range tests {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := DecryptAge(data, agePassphrase)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(pt, want) {
+			t.Fatalf("%s: decrypted %d bytes that don't match", name, len(pt))
+		}
+		if _, err := DecryptAge(data, "wrong"); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("%s: got %v, want ErrAuthFailed", name, err)
+		}
+
+		// Missing its last byte, the final chunk doesn't authenticate
+		if _, err := DecryptAge(data[:len(data)-1], agePassphrase); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("%s: got %v, want ErrAuthFailed", name, err)
+		}
+	}
+
+	// Only passphrase encryption is supported
+	data, err := os.ReadFile("testdata/x25519.age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptAge(data, agePassphrase); err == nil {
+		t.Fatal("decrypted a file encrypted to a public key")
+	}
+
+}
@@ -0,0 +1,96 @@
+package gocrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Error returned when data was encrypted with another algorithm than the
+// one configured
+var ErrAlgorithmMismatch = errors.New("algorithm mismatch")
+
+// AEAD algorithm used to encrypt data
+type Algorithm byte
+
+const (
+	// Use the default algorithm (AES-256-GCM) to encrypt and whatever the
+	// data was encrypted with to decrypt
+	DefaultAlgorithm Algorithm = 0
+	// AES-256-GCM, fastest on CPUs with AES instructions
+	AES256GCM Algorithm = 1
+	// ChaCha20-Poly1305, fastest on CPUs without AES instructions
+	ChaCha20Poly1305 Algorithm = 2
+)
+
+// Function to get the name of an algorithm
+//
+// Returns:
+//
+//   string - Name of the algorithm
+func (a Algorithm) String() string {
+	switch a {
+	case DefaultAlgorithm:
+		return "default"
+	case AES256GCM:
+		return "AES-256-GCM"
+	case ChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	}
+	return fmt.Sprintf("unknown algorithm %d", byte(a))
+}
+
+// Function to get the algorithm data is encrypted with
+func (a Algorithm) effective() Algorithm {
+	if a == DefaultAlgorithm {
+		return AES256GCM
+	}
+	return a
+}
+
+// Function to check an algorithm is one this package implements
+func (a Algorithm) valid() bool {
+	return a == AES256GCM || a == ChaCha20Poly1305
+}
+
+// Function to check data encrypted with an algorithm can be decrypted with
+// the one configured
+//
+//   used       Algorithm - Algorithm the data was encrypted with
+//   configured Algorithm - Algorithm set in Options
+func checkAlgorithm(used, configured Algorithm) error {
+
+	if !used.valid() {
+		return fmt.Errorf("unsupported algorithm %d", byte(used))
+	}
+	if configured != DefaultAlgorithm && configured != used {
+		return fmt.Errorf("%w: file uses %s, configured for %s", ErrAlgorithmMismatch, used, configured)
+	}
+
+	return nil
+
+}
+
+// Function to build an AEAD for a key
+//
+//   alg Algorithm - Algorithm of the AEAD
+//   key []byte    - Key to build the AEAD with
+func buildAEAD(alg Algorithm, key []byte) (cipher.AEAD, error) {
+
+	switch alg.effective() {
+	case AES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm %d", byte(alg))
+
+}
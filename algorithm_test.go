@@ -0,0 +1,49 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestAlgorithmMismatch(t *testing.T) {
+
+	algs := []Algorithm{AES256GCM, ChaCha20Poly1305}
+	for _, enc := range algs {
+
+		stream, streamSalt := encryptTestStream(t, []byte("data"), WithCipher(enc))
+		oneShot, salt, err := Encrypt([]byte("data"), "pass", testKDF, WithCipher(enc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info, err := Inspect(bytes.NewReader(stream)); err != nil || info.Algorithm != enc {
+			t.Fatalf("got %v, %v, want %v", info.Algorithm, err, enc)
+		}
+
+		for _, dec := range append([]Algorithm{DefaultAlgorithm}, algs...) {
+
+			streamErr := DecryptStream(ioutil.Discard, bytes.NewReader(stream), streamSalt, "pass", WithCipher(dec))
+			_, oneShotErr := Decrypt(oneShot, salt, "pass", WithCipher(dec))
+
+			if dec == DefaultAlgorithm || dec == enc {
+				if streamErr != nil || oneShotErr != nil {
+					t.Fatalf("%v with %v: got %v, %v", enc, dec, streamErr, oneShotErr)
+				}
+				continue
+			}
+
+			want := fmt.Sprintf("file uses %s, configured for %s", enc, dec)
+			for _, err := range []error{streamErr, oneShotErr} {
+				if !errors.Is(err, ErrAlgorithmMismatch) || !strings.Contains(err.Error(), want) {
+					t.Fatalf("%v with %v: got %v, want %q", enc, dec, err, want)
+				}
+			}
+
+		}
+
+	}
+
+}
@@ -0,0 +1,47 @@
+package gocrypt
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestBindToPath(t *testing.T) {
+
+	src, dst, out := t.TempDir()+"/", t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "data")
+	bind := WithBindToPath(true)
+	if err := EncryptFile("a.txt", src, dst, "pass", testKDF, bind); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, bind); err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := os.ReadFile(out + "a.txt"); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// Renamed or moved to another directory, the file no longer decrypts
+	moved := t.TempDir() + "/"
+	for _, to := range []string{dst + "b.txt", moved + "a.txt"} {
+		for _, ext := range []string{".3dfx", ".salt"} {
+			data, err := os.ReadFile(dst + "a.txt" + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, to+ext, string(data))
+		}
+	}
+	if err := DecryptFile("b.txt", dst, out, "pass", testKDF, bind); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("renamed: got %v, want ErrAuthFailed", err)
+	}
+	if err := DecryptFile("a.txt", moved, out, "pass", testKDF, bind); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("moved: got %v, want ErrAuthFailed", err)
+	}
+
+	// The path isn't stored, so it's needed to decrypt
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+}
@@ -0,0 +1,72 @@
+package gocrypt
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+)
+
+// Error returned when the canary of a stream doesn't authenticate, which
+// means the passphrase (or salt) is wrong or the header was tampered with.
+// It's also an ErrAuthFailed for errors.Is.
+var ErrWrongPassphrase error = wrongPassphraseError{}
+
+// Type of ErrWrongPassphrase
+type wrongPassphraseError struct{}
+
+// Function to describe the error
+func (wrongPassphraseError) Error() string {
+	return "wrong passphrase"
+}
+
+// Function to make the error match ErrAuthFailed as well
+func (wrongPassphraseError) Is(target error) bool {
+	return target == ErrAuthFailed
+}
+
+// Value sealed as the canary of a stream
+var canaryValue = []byte("GC-OK")
+
+// Size of the canary following the header (nonce + value + tag)
+const canarySize = 12 + 5 + 16
+
+// Function to seal the canary of a stream
+//
+// The canary is authenticated with the header, so it also catches a header
+// that was changed. Data encrypted by Encrypt has its canary right after
+// its header too, and authenticated with the data.
+//
+//   aead   cipher.AEAD - AEAD of the stream
+//   header []byte      - Raw header of the stream
+func sealCanary(aead cipher.AEAD, header []byte) ([]byte, error) {
+
+	nonce := make([]byte, aead.NonceSize(), canarySize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, canaryValue, header), nil
+
+}
+
+// Function to check the canary of a stream
+//
+//   aead   cipher.AEAD - AEAD of the stream
+//   header []byte      - Raw header of the stream
+//   canary []byte      - Canary read after the header
+func openCanary(aead cipher.AEAD, header, canary []byte) error {
+
+	if len(canary) != canarySize || aead.NonceSize() != 12 {
+		return fmt.Errorf("%w canary: invalid length", ErrCorrupted)
+	}
+
+	value, err := aead.Open(nil, canary[:12], canary[12:], header)
+	if err != nil || subtle.ConstantTimeCompare(value, canaryValue) != 1 {
+		return ErrWrongPassphrase
+	}
+
+	return nil
+
+}
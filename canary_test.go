@@ -0,0 +1,58 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCanary(t *testing.T) {
+
+	data := bytes.Repeat([]byte("x"), 3*chunkSize)
+	ct, salt := encryptTestStream(t, data, WithCanary(true), WithComment("note"))
+	if info, err := Inspect(bytes.NewReader(ct)); err != nil || !info.Canary {
+		t.Fatalf("got %v, %v, want a canary", info.Canary, err)
+	}
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(ct), salt, "pass"); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("got %d bytes, %v", out.Len(), err)
+	}
+
+	// Nothing past the canary is read with a wrong passphrase
+	_, raw, err := readHeader(bytes.NewReader(ct))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(raw) + canarySize
+	src := io.MultiReader(bytes.NewReader(ct[:n]), iotest.ErrReader(errors.New("payload read")))
+	out.Reset()
+	err = DecryptStream(&out, src, salt, "wrong")
+	if err != ErrWrongPassphrase || !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("got %d bytes written, want none", out.Len())
+	}
+
+	// A changed header fails the canary as well
+	tampered := bytes.Replace(ct, []byte("note"), []byte("nope"), 1)
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(tampered), salt, "pass"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	// Data encrypted by Encrypt gets one too
+	ciphertext, salt, err := Encrypt(data, "pass", testKDF, WithCanary(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := Decrypt(ciphertext, salt, "pass", testKDF); err != nil || !bytes.Equal(pt, data) {
+		t.Fatalf("got %d bytes, %v", len(pt), err)
+	}
+	if _, err := Decrypt(ciphertext, salt, "wrong", testKDF); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+
+}
@@ -0,0 +1,99 @@
+package gocrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// Function to walk the frames of a hash chained stream independently of
+// frameReader, opening each with the tag of the frame before it
+//
+//   t      *testing.T - Test
+//   stream []byte     - Encrypted stream, with the salt in its header
+//   skip   int        - Number of frames to take the tag of without opening
+func walkChain(t *testing.T, stream []byte, skip int) ([]byte, error) {
+	t.Helper()
+
+	info, header, err := readHeader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := NewOptions(testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := createHash(info.Salt, "pass", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := buildAEAD(info.Algorithm, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := make([]byte, chainSize)
+	rest := stream[len(header):]
+	for counter := uint64(0); len(rest) > 0; counter++ {
+		flags, size := rest[0], int(binary.BigEndian.Uint32(rest[1:]))
+		frame := rest[frameHeaderSize : frameHeaderSize+size]
+		rest = rest[frameHeaderSize+size:]
+
+		if int(counter) >= skip {
+			ad := append(append(append([]byte(nil), header...), prev...), make([]byte, 8)...)
+			binary.BigEndian.PutUint64(ad[len(ad)-8:], counter)
+			ad = append(ad, flags)
+			nonce := frame[:aead.NonceSize()]
+			if _, err := aead.Open(nil, nonce, frame[aead.NonceSize():], ad); err != nil {
+				return nil, err
+			}
+		}
+		prev = frame[len(frame)-chainSize:]
+	}
+
+	return prev, nil
+
+}
+
+func TestHashChain(t *testing.T) {
+
+	data := make([]byte, 4*minChunkSize)
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(data), "pass", testKDF, WithChunkSize(minChunkSize), WithSaltPosition(SaltHeader), WithHashChain(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := buf.Bytes()
+
+	if info, err := Inspect(bytes.NewReader(stream)); err != nil || !info.HashChain {
+		t.Fatalf("got %v, %v, want a hash chained stream", info.HashChain, err)
+	}
+
+	// The exported head is the tag reached by walking the chain
+	head, err := walkChain(t, stream, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.ChainHead) != chainSize || !bytes.Equal(head, meta.ChainHead) {
+		t.Fatalf("got head %x, recomputed %x", meta.ChainHead, head)
+	}
+
+	// Altering the first frame also breaks the frames after it
+	_, raw, _ := readHeader(bytes.NewReader(stream))
+	firstTag := len(raw) + frameHeaderSize + 12 + minChunkSize + 15
+	tampered := append([]byte(nil), stream...)
+	tampered[firstTag] ^= 1
+	if _, err := walkChain(t, tampered, 1); err == nil {
+		t.Fatal("later frames opened after the first was altered")
+	}
+	var frames int
+	err = DecryptEachFrame(bytes.NewReader(tampered), nil, "pass", func([]byte) error {
+		frames++
+		return nil
+	})
+	if !errors.Is(err, ErrAuthFailed) || frames != 0 {
+		t.Fatalf("got %d frames, %v, want ErrAuthFailed on the first", frames, err)
+	}
+
+}
@@ -0,0 +1,74 @@
+package gocrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Separator between the salt and the encrypted data of a combined string
+const combinedSeparator = ":"
+
+// Function to encrypt data into a single string holding its salt
+//
+// The string is base64(salt) + ":" + base64(ciphertext) using standard
+// base64, so it fits in a single text column. Decrypt it with
+// DecryptCombinedString.
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   string - Salt and encrypted data
+//   error  - Error
+func EncryptCombinedString(data []byte, pass string, opts ...Option) (string, error) {
+
+	ciphertext, salt, err := Encrypt(data, pass, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(salt) + combinedSeparator + base64.StdEncoding.EncodeToString(ciphertext), nil
+
+}
+
+// Function to decrypt a string made by EncryptCombinedString
+//
+// Variables to pass in:
+//
+//   s    string - Salt and encrypted data
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptCombinedString(s, pass string, opts ...Option) ([]byte, error) {
+
+	saltPart, dataPart, ok := strings.Cut(s, combinedSeparator)
+	if !ok {
+		return nil, fmt.Errorf("combined string: missing %q separator", combinedSeparator)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(saltPart)
+	if err != nil {
+		return nil, fmt.Errorf("combined string: invalid salt: %w", err)
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("combined string: empty salt")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(dataPart)
+	if err != nil {
+		return nil, fmt.Errorf("combined string: invalid data: %w", err)
+	}
+
+	return Decrypt(data, salt, pass, opts...)
+
+}
@@ -0,0 +1,31 @@
+package gocrypt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCombinedString(t *testing.T) {
+
+	s, err := EncryptCombinedString([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := DecryptCombinedString(s, "pass", testKDF); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	saltPart, dataPart, _ := strings.Cut(s, combinedSeparator)
+	for bad, want := range map[string]string{
+		saltPart + dataPart:                   "missing",
+		combinedSeparator + dataPart:          "empty salt",
+		"!!" + combinedSeparator + dataPart:   "invalid salt",
+		saltPart + combinedSeparator + "!!":   "invalid data",
+		saltPart + combinedSeparator + "AAAA": "too short",
+	} {
+		if _, err := DecryptCombinedString(bad, "pass", testKDF); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: got %v, want %q", bad, err, want)
+		}
+	}
+
+}
@@ -0,0 +1,67 @@
+package gocrypt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+)
+
+// Function to compress data with gzip
+//
+//   data  []byte - Data to compress
+//   level int    - Compression level (0 for the default)
+func compress(data []byte, level int) ([]byte, error) {
+
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+
+}
+
+// Function to inflate data compressed by compress
+//
+// Inflating stops with ErrTooLarge as soon as the output goes over limit,
+// so a small ciphertext can't expand into an unbounded plaintext.
+//
+//   data  []byte - Compressed data
+//   limit int64  - Maximum size of the output (0 means no limit)
+func decompress(data []byte, limit int64) ([]byte, error) {
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var r io.Reader = zr
+	if limit > 0 {
+		r = io.LimitReader(zr, limit+1)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		Scrub(out)
+		return nil, err
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		Scrub(out)
+		return nil, ErrTooLarge
+	}
+
+	return out, nil
+
+}
@@ -0,0 +1,57 @@
+package gocrypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressionLevel(t *testing.T) {
+
+	data := []byte(strings.Repeat("gocrypt compresses repetitive text well, ", 2000))
+
+	sizes := make(map[int]int)
+	for level := 0; level <= 9; level++ {
+		ct, salt, err := Encrypt(data, "pass", testKDF, WithCompression(true), WithCompressionLevel(level))
+		if err != nil {
+			t.Fatalf("level %d: encrypt: %v", level, err)
+		}
+		sizes[level] = len(ct)
+
+		// The level isn't needed to decrypt
+		pt, err := Decrypt(ct, salt, "pass")
+		if err != nil {
+			t.Fatalf("level %d: decrypt: %v", level, err)
+		}
+		if !bytes.Equal(pt, data) {
+			t.Fatalf("level %d: round trip mismatch", level)
+		}
+	}
+
+	if sizes[9] >= sizes[1] {
+		t.Fatalf("level 9 gave %d bytes, not smaller than the %d of level 1", sizes[9], sizes[1])
+	}
+	if sizes[1] >= len(data) {
+		t.Fatalf("level 1 gave %d bytes for %d bytes of text", sizes[1], len(data))
+	}
+
+}
+
+func TestCompressionLevelRange(t *testing.T) {
+
+	for _, level := range []int{-1, 10} {
+		if _, err := NewOptions(WithCompressionLevel(level)); err == nil {
+			t.Errorf("level %d accepted", level)
+		}
+	}
+
+	// Set directly, an invalid level still fails rather than being ignored
+	_, _, err := Encrypt([]byte("data"), "pass", testKDF, WithCompression(true), func(o *Options) error {
+		o.CompressionLevel = 42
+		return nil
+	})
+	if err == nil {
+		t.Fatal("encrypted with compression level 42")
+	}
+
+}
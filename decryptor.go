@@ -0,0 +1,225 @@
+package gocrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Error returned when reading a closed Decryptor
+var errDecryptorClosed = errors.New("decryptor closed")
+
+// Position in a stream to resume decryption from, see Decryptor.Checkpoint
+type Checkpoint struct {
+	Frames  uint64 // Number of frames whose plaintext was entirely returned
+	Offset  int64  // Offset in the stream of the next frame to read
+	Emitted int64  // Number of plaintext bytes returned
+	Skip    int64  // Plaintext of the next frame already returned
+	Chain   []byte // Tag of the last frame whose plaintext was entirely returned (hash chained streams)
+}
+
+// Streaming decryptor keeping track of its position
+//
+// It reads an encrypted stream as an io.Reader and can be checkpointed at
+// any point to resume decrypting later with ResumeDecrypt, without reading
+// the stream again from the start (ie. for downloads that get
+// interrupted).
+type Decryptor struct {
+	fr      *frameReader
+	pending []byte // Plaintext of the current frame not returned yet
+	emitted int64
+	start   Checkpoint // Position at the start of the current frame
+	err     error
+	closed  bool
+}
+
+// Function to decrypt a stream as it's read
+//
+// Only the framed format of EncryptStream is supported, not
+// Options.MinimalOverhead. If salt is nil the salt stored in the stream is
+// used.
+//
+// Variables to pass in:
+//
+//   src io.Reader - Reader to get the encrypted stream from
+//   salt []byte   - Salt to use to create hash
+//   pass string   - Passphrase to use for decryption
+//   opts          - Options (optional)
+//
+// Returns:
+//
+//   *Decryptor - Decryptor reading the plaintext
+//   error      - Error
+func NewDecryptor(src io.Reader, salt []byte, pass string, opts ...Option) (*Decryptor, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("decryptor: options: %w", err)
+	}
+	if o.MinimalOverhead {
+		return nil, errors.New("decryptor: minimal overhead streams aren't supported")
+	}
+
+	fr, err := newFrameReader(context.Background(), src, src, salt, pass, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Decryptor{fr: fr}, nil
+
+}
+
+// Function to resume decrypting a stream from a checkpoint
+//
+// The header is read from header, which must start at the beginning of the
+// stream (only the header is read from it), and the frames from src, which
+// must be positioned at cp.Offset. Reading the returned Decryptor gives the
+// plaintext following the cp.Emitted bytes already returned.
+//
+// Variables to pass in:
+//
+//   header io.Reader - Reader positioned at the start of the stream
+//   src io.Reader    - Reader positioned at cp.Offset in the stream
+//   salt []byte      - Salt to use to create hash
+//   pass string      - Passphrase to use for decryption
+//   cp Checkpoint    - Checkpoint to resume from
+//   opts             - Options (optional)
+//
+// Returns:
+//
+//   *Decryptor - Decryptor reading the rest of the plaintext
+//   error      - Error
+func ResumeDecrypt(header, src io.Reader, salt []byte, pass string, cp Checkpoint, opts ...Option) (*Decryptor, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("resume decrypt: options: %w", err)
+	}
+	if o.MinimalOverhead {
+		return nil, errors.New("resume decrypt: minimal overhead streams aren't supported")
+	}
+
+	fr, err := newFrameReader(context.Background(), header, src, salt, pass, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header is only read to derive the key, it can't be past the
+	// frames
+	if cp.Offset < fr.offset || cp.Skip < 0 || cp.Skip > cp.Emitted || (fr.chain != nil && len(cp.Chain) != chainSize) {
+		fr.close()
+		return nil, errors.New("resume decrypt: invalid checkpoint")
+	}
+	fr.counter = cp.Frames
+	fr.offset = cp.Offset
+	fr.emitted = cp.Emitted - cp.Skip
+	copy(fr.chain, cp.Chain)
+
+	d := &Decryptor{fr: fr, emitted: cp.Emitted - cp.Skip}
+
+	// The plaintext of the next frame already returned is read again and
+	// dropped
+	if cp.Skip > 0 {
+		if _, err := io.CopyN(io.Discard, d, cp.Skip); err != nil {
+			d.Close()
+			return nil, fmt.Errorf("resume decrypt: skip: %w", err)
+		}
+	}
+
+	return d, nil
+
+}
+
+// Function to read the plaintext
+//
+// Data is only returned once the frame holding it has been authenticated.
+// Returns io.EOF after the final frame.
+func (d *Decryptor) Read(p []byte) (int, error) {
+
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if !d.fr.done {
+			d.start = Checkpoint{Frames: d.fr.counter, Offset: d.fr.offset, Emitted: d.emitted, Chain: append([]byte(nil), d.fr.chain...)}
+		}
+		d.pending, d.err = d.fr.next()
+		if d.err != nil {
+			// Nothing more can be read
+			d.release()
+		}
+	}
+
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	d.emitted += int64(n)
+
+	return n, nil
+
+}
+
+// Function to get the number of frames whose plaintext was entirely
+// returned by Read
+//
+// Returns:
+//
+//   uint64 - Number of frames
+func (d *Decryptor) FramesConsumed() uint64 {
+	if len(d.pending) > 0 {
+		return d.start.Frames
+	}
+	return d.fr.counter
+}
+
+// Function to get the number of plaintext bytes returned by Read
+//
+// Returns:
+//
+//   int64 - Number of bytes
+func (d *Decryptor) BytesEmitted() int64 {
+	return d.emitted
+}
+
+// Function to get the position to resume decrypting from with
+// ResumeDecrypt
+//
+// Returns:
+//
+//   Checkpoint - Current position
+func (d *Decryptor) Checkpoint() Checkpoint {
+
+	// Past the final frame the checkpoint stays on it, so resuming reads it
+	// again to find the end of the stream
+	if len(d.pending) > 0 || d.fr.done {
+		cp := d.start
+		cp.Skip = d.emitted - cp.Emitted
+		cp.Emitted = d.emitted
+		return cp
+	}
+
+	return Checkpoint{Frames: d.fr.counter, Offset: d.fr.offset, Emitted: d.emitted, Chain: append([]byte(nil), d.fr.chain...)}
+
+}
+
+// Function to release the resources of the Decryptor
+//
+// Returns:
+//
+//   error - Error
+func (d *Decryptor) Close() error {
+
+	d.release()
+	d.pending, d.err = nil, errDecryptorClosed
+
+	return nil
+
+}
+
+// Function to release the frame reader once
+func (d *Decryptor) release() {
+	if !d.closed {
+		d.fr.close()
+		d.closed = true
+	}
+}
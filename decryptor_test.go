@@ -0,0 +1,66 @@
+package gocrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecryptorResume(t *testing.T) {
+
+	data := make([]byte, 5*minChunkSize+123)
+	rand.Read(data)
+	for _, opts := range [][]Option{
+		{WithChunkSize(minChunkSize)},
+		{WithChunkSize(minChunkSize), WithHashChain(true), WithCanary(true)},
+	} {
+		ct, salt := encryptTestStream(t, data, opts...)
+		for _, cut := range []int{0, 100, 3 * minChunkSize, 3*minChunkSize + 77, len(data)} {
+			d, err := NewDecryptor(bytes.NewReader(ct), salt, "pass")
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := make([]byte, cut)
+			if _, err := io.ReadFull(d, first); err != nil {
+				t.Fatal(err)
+			}
+			frames := uint64(cut / minChunkSize)
+			if cut == len(data) {
+				frames++
+			}
+			if d.BytesEmitted() != int64(cut) || d.FramesConsumed() != frames {
+				t.Fatalf("cut at %d: got %d bytes and %d frames", cut, d.BytesEmitted(), d.FramesConsumed())
+			}
+
+			// Seeing the end of the stream doesn't move the checkpoint past
+			// the final frame
+			if cut == len(data) {
+				if n, err := d.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+					t.Fatalf("got %d, %v, want io.EOF", n, err)
+				}
+			}
+			cp := d.Checkpoint()
+			d.Close()
+
+			// Only the header and what follows the checkpoint are read again
+			r, err := ResumeDecrypt(bytes.NewReader(ct), bytes.NewReader(ct[cp.Offset:]), salt, "pass", cp)
+			if err != nil {
+				t.Fatalf("cut at %d: %v", cut, err)
+			}
+			rest, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("cut at %d: %v", cut, err)
+			}
+			if !bytes.Equal(append(first, rest...), data) {
+				t.Fatalf("cut at %d: resumed plaintext mismatch", cut)
+			}
+			if r.BytesEmitted() != int64(len(data)) {
+				t.Fatalf("cut at %d: got %d bytes emitted, want %d", cut, r.BytesEmitted(), len(data))
+			}
+			r.Close()
+		}
+	}
+
+}
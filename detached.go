@@ -0,0 +1,74 @@
+package gocrypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Function to encrypt a stream of data with its header written separately
+//
+// The header (format version and salt) goes to headerW and the frames go to
+// ciphertextW, so the frames can be appended to stores where nothing can be
+// put in front of data already written. Only the header and the passphrase
+// are needed to decrypt.
+//
+// Variables to pass in:
+//
+//   src io.Reader         - Reader to get the data to be encrypted from
+//   ciphertextW io.Writer - Writer to output the encrypted frames to
+//   headerW io.Writer     - Writer to output the header to
+//   pass string           - Passphrase to use for encryption
+//   opts                  - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func EncryptDetachedHeader(src io.Reader, ciphertextW, headerW io.Writer, pass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("encrypt detached header: options: %w", err)
+	}
+
+	_, err = encryptStream(context.Background(), ciphertextW, headerW, src, pass, SaltHeader, o)
+
+	return err
+
+}
+
+// Function to decrypt a stream of data whose header was written separately
+//
+// Variables to pass in:
+//
+//   dst io.Writer         - Writer to output the decrypted data to
+//   headerR io.Reader     - Reader to get the header from
+//   ciphertextR io.Reader - Reader to get the encrypted frames from
+//   pass string           - Passphrase to use for decryption
+//   opts                  - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptDetachedHeader(dst io.Writer, headerR, ciphertextR io.Reader, pass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("decrypt detached header: options: %w", err)
+	}
+
+	err = decryptStream(context.Background(), headerR, ciphertextR, nil, pass, func(frame []byte) error {
+		_, err := dst.Write(frame)
+		return err
+	}, o)
+	if err != nil {
+		return err
+	}
+
+	if err := syncOutput(dst, o); err != nil {
+		return fmt.Errorf("decrypt detached header: sync: %w", err)
+	}
+
+	return nil
+
+}
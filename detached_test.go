@@ -0,0 +1,34 @@
+package gocrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetachedHeader(t *testing.T) {
+
+	data := bytes.Repeat([]byte("z"), 2*chunkSize+10)
+	var header, body, out bytes.Buffer
+	if err := EncryptDetachedHeader(bytes.NewReader(data), &body, &header, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	if header.Len() == 0 || bytes.HasPrefix(body.Bytes(), magic) {
+		t.Fatal("header not written apart from the ciphertext")
+	}
+
+	if err := DecryptDetachedHeader(&out, bytes.NewReader(header.Bytes()), bytes.NewReader(body.Bytes()), "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("round trip mismatch")
+	}
+
+	// The header is authenticated with the frames
+	h := append([]byte(nil), header.Bytes()...)
+	h[len(h)-1] ^= 1
+	out.Reset()
+	if err := DecryptDetachedHeader(&out, bytes.NewReader(h), bytes.NewReader(body.Bytes()), "pass"); err == nil {
+		t.Fatal("decrypted with a tampered header")
+	}
+
+}
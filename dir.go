@@ -0,0 +1,561 @@
+package gocrypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Error returned by EncryptDir and DecryptDir when some files failed
+type DirError struct {
+	Errs []error // Errors of the files that failed, in the order walked
+}
+
+// Function to describe the error
+func (e *DirError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d files failed: %s", len(e.Errs), strings.Join(msgs, "; "))
+}
+
+// Function to get the errors of the files that failed
+func (e *DirError) Unwrap() []error {
+	return e.Errs
+}
+
+// Function to check if the error of any file matches target, for
+// errors.Is
+//
+//   target error - Error to match
+func (e *DirError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to find the first error of a file matching target, for
+// errors.As
+//
+//   target any - Pointer to the type of error to find
+func (e *DirError) As(target any) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to encrypt every file in a directory tree
+//
+// The tree is walked and recreated under dst, empty directories included,
+// and each regular file is encrypted with EncryptFileStream to a .3dfx file
+// (plus a .salt file unless Options.SaltPosition stores the salt in the
+// stream). Symlinks and other special files are skipped, never followed. A
+// file that fails doesn't stop the others: the failures are returned
+// together as a *DirError. Options.Concurrency sets how many files are
+// encrypted at once. See PlanEncryptDir to preview the work.
+//
+// Variables to pass in:
+//
+//   src string  - Directory to encrypt
+//   dst string  - Directory to write the encrypted tree to (must not be
+//                 inside src)
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func EncryptDir(src, dst, pass string, opts ...Option) error {
+	return EncryptDirContext(context.Background(), src, dst, pass, opts...)
+}
+
+// Function to encrypt every file in a directory tree, stopping if ctx is
+// done
+//
+// ctx is checked between files and while each one is encrypted, and its
+// error returned as soon as it's done. The file being encrypted at that
+// point is removed, the ones before it are complete.
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context
+//   src string          - Directory to encrypt
+//   dst string          - Directory to write the encrypted tree to (must
+//                         not be inside src)
+//   pass string         - Passphrase to use for encryption
+//   opts                - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func EncryptDirContext(ctx context.Context, src, dst, pass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("encrypt dir: options: %w", err)
+	}
+	if err := o.checkPassphrase(pass); err != nil {
+		return fmt.Errorf("encrypt dir: %w", err)
+	}
+
+	// Progress is reported per file rather than per frame
+	if o.Progress != nil {
+		opts = append(opts[:len(opts):len(opts)], WithProgress(nil))
+	}
+
+	encrypt := func(path, rel, out string) error {
+		return encryptFileStream(ctx, path, filepath.Join(out, filepath.Base(path)), pass, opts)
+	}
+
+	if o.ObfuscateNames {
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return fmt.Errorf("encrypt dir: %w", err)
+		}
+		namer, err := newNamer(dst, pass, o)
+		if err != nil {
+			return fmt.Errorf("encrypt dir: names: %w", err)
+		}
+		defer namer.close()
+		encrypt = func(path, rel, out string) error {
+			rel = filepath.ToSlash(rel)
+			return encryptFileStream(ctx, path, filepath.Join(dst, namer.name(rel)), pass, append(opts[:len(opts):len(opts)], withName(rel)))
+		}
+	}
+
+	encrypt = dirProgress(src, func(string) bool { return true }, encrypt, o)
+	if err := walkTree(ctx, src, dst, !o.ObfuscateNames, o.Concurrency, encrypt); err != nil {
+		return fmt.Errorf("encrypt dir: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to decrypt a directory tree written by EncryptDir
+//
+// The tree is walked and recreated under dst, empty directories included,
+// and each .3dfx file is decrypted with DecryptFileStream, using the .salt
+// file next to it if there is one. Other files and symlinks are skipped. A
+// file that fails doesn't stop the others: the failures are returned
+// together as a *DirError. Options.Concurrency sets how many files are
+// decrypted at once.
+//
+// Variables to pass in:
+//
+//   src string  - Directory to decrypt
+//   dst string  - Directory to write the decrypted tree to (must not be
+//                 inside src)
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptDir(src, dst, pass string, opts ...Option) error {
+	return DecryptDirContext(context.Background(), src, dst, pass, opts...)
+}
+
+// Function to decrypt a directory tree written by EncryptDir, stopping if
+// ctx is done
+//
+// ctx is checked between files and while each one is decrypted, and its
+// error returned as soon as it's done. The file being decrypted at that
+// point is removed, the ones before it are complete.
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context
+//   src string          - Directory to decrypt
+//   dst string          - Directory to write the decrypted tree to (must
+//                         not be inside src)
+//   pass string         - Passphrase to use for decryption
+//   opts                - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptDirContext(ctx context.Context, src, dst, pass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("decrypt dir: options: %w", err)
+	}
+
+	// Progress is reported per file rather than per frame
+	if o.Progress != nil {
+		opts = append(opts[:len(opts):len(opts)], WithProgress(nil))
+	}
+
+	encrypted := func(path string) bool { return strings.HasSuffix(path, ".3dfx") }
+	decrypt := dirProgress(src, encrypted, func(path, rel, out string) error {
+		if !encrypted(path) {
+			return nil
+		}
+		return decryptDirFile(ctx, path, out, dst, pass, opts)
+	}, o)
+	err = walkTree(ctx, src, dst, true, o.Concurrency, decrypt)
+	if err != nil {
+		return fmt.Errorf("decrypt dir: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to decrypt a file of a tree written by EncryptDir
+//
+// A file whose header holds its original path is written there under dst,
+// others next to where they are in the tree.
+//
+//   ctx  context.Context - Context
+//   path string          - Path of the .3dfx file
+//   out  string          - Output directory matching the one of the file
+//   dst  string          - Root of the decrypted tree
+//   pass string          - Passphrase to use for decryption
+//   opts []Option        - Options
+func decryptDirFile(ctx context.Context, path, out, dst, pass string, opts []Option) error {
+
+	base := strings.TrimSuffix(path, ".3dfx")
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("decrypt file stream: open file: %w", err)
+	}
+	defer f.Close()
+
+	o, _ := newOptions(opts)
+	info, _, err := readHeader(f)
+	if o.MinimalOverhead || err != nil || info.sealedName == nil {
+		return decryptFileStream(ctx, base, filepath.Join(out, filepath.Base(base)), pass, opts)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("decrypt file stream: seek: %w", err)
+	}
+
+	salt, err := ioutil.ReadFile(base + ".salt")
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("decrypt file stream: read salt file: %w", err)
+	}
+
+	fr, err := newFrameReader(ctx, f, f, salt, pass, o)
+	if err != nil {
+		return err
+	}
+	defer fr.close()
+
+	name := filepath.FromSlash(fr.name)
+	if !localPath(name) {
+		return fmt.Errorf("decrypt file stream: unsafe name %q in header", fr.name)
+	}
+	toFile := filepath.Join(dst, name)
+	if err := os.MkdirAll(filepath.Dir(toFile), 0700); err != nil {
+		return fmt.Errorf("decrypt file stream: %w", err)
+	}
+
+	xf, err := os.Create(toFile)
+	if err != nil {
+		return fmt.Errorf("decrypt file stream: create file: %w", err)
+	}
+	for {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var plaintext []byte
+		plaintext, err = fr.next()
+		if err == io.EOF {
+			err = syncOutput(xf, o)
+			break
+		}
+		if err == nil {
+			_, err = xf.Write(plaintext)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if cerr := xf.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Never leave partial plaintext behind
+		os.Remove(toFile)
+		return err
+	}
+
+	return nil
+
+}
+
+// Function to check that a path stays inside the directory it's joined to
+//
+//   name string - Path to check
+func localPath(name string) bool {
+
+	if name == "" || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return false
+	}
+	clean := filepath.Clean(name)
+
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator))
+
+}
+
+// Name of the file holding the salt of the key obfuscated names derive from
+const namesSaltFile = ".gocrypt-names"
+
+// Deriver of the obfuscated names of a run of EncryptDir
+type namer struct {
+	mu    sync.Mutex
+	key   []byte
+	taken map[string]bool
+}
+
+// Function to get the key obfuscated names derive from
+//
+// The salt is kept in dst and reused, so encrypting the same tree to the
+// same place again gives the same names.
+//
+//   dst  string  - Directory the files are written to
+//   pass string  - Passphrase
+//   o    Options - Options in use
+func newNamer(dst, pass string, o Options) (*namer, error) {
+
+	saltPath := filepath.Join(dst, namesSaltFile)
+	salt, err := ioutil.ReadFile(saltPath)
+	if os.IsNotExist(err) {
+		salt = make([]byte, o.KDF.resolve().SaltLen)
+		if _, err = io.ReadFull(rand.Reader, salt); err == nil {
+			err = ioutil.WriteFile(saltPath, salt, 0600)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, master, err := createHash(salt, pass, o)
+	if err != nil {
+		return nil, err
+	}
+	defer Scrub(master)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("gocrypt names")), key); err != nil {
+		return nil, err
+	}
+
+	return &namer{key: key, taken: make(map[string]bool)}, nil
+
+}
+
+// Function to get the obfuscated name of a file
+//
+// Names are the HMAC of the path. On a collision the path is retried with
+// a counter, so the same tree always gets the same names.
+//
+//   rel string - Path of the file relative to the directory encrypted
+func (n *namer) name(rel string) string {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := 0; ; i++ {
+		h := hmac.New(sha256.New, n.key)
+		h.Write([]byte(rel))
+		if i > 0 {
+			fmt.Fprintf(h, "\x00%d", i)
+		}
+		name := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil)[:20]))
+		if !n.taken[name] {
+			n.taken[name] = true
+			return name
+		}
+	}
+
+}
+
+// Function to wipe the key of a namer
+func (n *namer) close() {
+	Scrub(n.key)
+}
+
+// Additional data the original path of a file is sealed with
+var nameAD = []byte("gocrypt name")
+
+// Function to seal the original path of a file for its header
+//
+//   aead cipher.AEAD - AEAD of the stream
+//   name string      - Path of the file
+func sealName(aead cipher.AEAD, name string) ([]byte, error) {
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(name)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, []byte(name), nameAD), nil
+
+}
+
+// Function to open the original path of a file sealed by sealName
+//
+//   aead   cipher.AEAD - AEAD of the stream
+//   sealed []byte      - Sealed path from the header
+func openName(aead cipher.AEAD, sealed []byte) (string, error) {
+
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("%w header: invalid name field", ErrCorrupted)
+	}
+
+	name, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nameAD)
+	if err != nil {
+		return "", ErrAuthFailed
+	}
+
+	return string(name), nil
+
+}
+
+// Function to walk a tree, recreate its directories under dst and call fn
+// for each regular file
+//
+// Errors of single files and directories are collected into a *DirError
+// while the walk goes on, until ctx is done. Up to workers calls to fn run
+// at once, and the walk waits for all of them before returning, whatever
+// the outcome.
+//
+//   ctx     context.Context - Context
+//   src     string          - Directory to walk
+//   dst     string          - Directory to recreate the tree in
+//   mirror  bool            - Whether to recreate the directories under dst
+//   workers int             - Number of files to process at once
+//   fn      func            - Function to call with the path of a file, its
+//                             path relative to src and the matching output
+//                             directory
+func walkTree(ctx context.Context, src, dst string, mirror bool, workers int, fn func(path, rel, out string) error) error {
+
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absSrc, absDst); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.New("destination must not be inside the source directory")
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	// One slot per error or file in walk order, so the errors come out in
+	// the same order however the files are scheduled
+	var mu sync.Mutex
+	var failed []error
+	slot := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, nil)
+		return len(failed) - 1
+	}
+	fail := func(i int, err error) {
+		mu.Lock()
+		failed[i] = err
+		mu.Unlock()
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			if path == src {
+				return err
+			}
+			fail(slot(), err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir() && mirror:
+			fi, err := d.Info()
+			if err == nil {
+				err = os.MkdirAll(out, fi.Mode().Perm()|0700)
+			}
+			if err != nil {
+				fail(slot(), fmt.Errorf("%s: %w", rel, err))
+				return filepath.SkipDir
+			}
+		case d.Type().IsRegular():
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			i := slot()
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := fn(path, rel, filepath.Dir(out)); err != nil {
+					fail(i, fmt.Errorf("%s: %w", rel, err))
+				}
+			}()
+		}
+		return nil
+	})
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, err := range failed {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &DirError{Errs: errs}
+	}
+
+	return nil
+
+}
@@ -0,0 +1,275 @@
+package gocrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Function to write a file for a test, creating its directory
+//
+//   t    *testing.T - Test
+//   path string     - Path of the file
+//   data string     - Contents of the file
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptDir(t *testing.T) {
+
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "top.txt"), "top")
+	writeTestFile(t, filepath.Join(src, "a", "b", "deep.txt"), "deep")
+	if err := os.MkdirAll(filepath.Join(src, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pos := range []SaltPosition{SaltDetached, SaltHeader} {
+		enc, dec := t.TempDir(), t.TempDir()
+		if err := EncryptDir(src, enc, "pass", testKDF, WithSaltPosition(pos)); err != nil {
+			t.Fatalf("encrypt dir: %v", err)
+		}
+		if _, err := os.Lstat(filepath.Join(enc, "link.3dfx")); err == nil {
+			t.Fatal("symlink followed")
+		}
+		if err := DecryptDir(enc, dec, "pass"); err != nil {
+			t.Fatalf("decrypt dir: %v", err)
+		}
+		for name, want := range map[string]string{"top.txt": "top", "a/b/deep.txt": "deep"} {
+			b, err := os.ReadFile(filepath.Join(dec, name))
+			if err != nil || string(b) != want {
+				t.Fatalf("%s: got %q, %v, want %q", name, b, err, want)
+			}
+		}
+		if fi, err := os.Stat(filepath.Join(dec, "empty")); err != nil || !fi.IsDir() {
+			t.Fatalf("empty directory not kept: %v", err)
+		}
+	}
+
+	if err := EncryptDir(src, filepath.Join(src, "out"), "pass", testKDF); err == nil {
+		t.Fatal("encrypted to a directory inside the source")
+	}
+
+}
+
+func TestDirError(t *testing.T) {
+
+	src, enc := t.TempDir(), t.TempDir()
+	writeTestFile(t, filepath.Join(src, "a"), "a")
+	writeTestFile(t, filepath.Join(src, "b"), "b")
+	if err := EncryptDir(src, enc, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(enc, "c.3dfx"), "garbage")
+
+	// Every file fails, and the rest are still tried
+	err := DecryptDir(enc, t.TempDir(), "wrong")
+	var de *DirError
+	if !errors.As(err, &de) || len(de.Errs) != 3 {
+		t.Fatalf("got %v, want a *DirError of 3 files", err)
+	}
+
+	// The errors of the files are matched without Go 1.20's multiple
+	// unwrapping
+	if !de.Is(ErrAuthFailed) || !de.Is(ErrCorrupted) {
+		t.Fatalf("%v doesn't match ErrAuthFailed and ErrCorrupted", err)
+	}
+	if de.Is(ErrLockedOut) {
+		t.Fatal("matched an error no file failed with")
+	}
+	var pe *os.PathError
+	if de.As(&pe) {
+		t.Fatalf("found %v", pe)
+	}
+
+	de = &DirError{Errs: []error{ErrCorrupted, fmt.Errorf("f: %w", &FrameError{Frame: 3, Err: ErrAuthFailed})}}
+	var fe *FrameError
+	if !de.As(&fe) || fe.Frame != 3 {
+		t.Fatalf("got %v, want the *FrameError of frame 3", fe)
+	}
+
+}
+
+func TestObfuscateNames(t *testing.T) {
+
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "secret.txt"), "top")
+	writeTestFile(t, filepath.Join(src, "plans", "deep.txt"), "deep")
+
+	for _, pos := range []SaltPosition{SaltDetached, SaltHeader} {
+		enc, dec := t.TempDir(), t.TempDir()
+		obfuscate := []Option{testKDF, WithSaltPosition(pos), WithObfuscateNames(true)}
+		if err := EncryptDir(src, enc, "pass", obfuscate...); err != nil {
+			t.Fatal(err)
+		}
+
+		// Neither the names nor the layout of the tree show
+		names := func() map[string]bool {
+			found := make(map[string]bool)
+			entries, err := os.ReadDir(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.IsDir() {
+					t.Fatalf("got directory %s, want a flat tree", e.Name())
+				}
+				for _, word := range []string{"secret", "plans", "deep", "txt"} {
+					if strings.Contains(e.Name(), word) {
+						t.Fatalf("got name %s, want nothing of %q", e.Name(), word)
+					}
+				}
+				found[e.Name()] = true
+			}
+			return found
+		}
+		before := names()
+
+		// Encrypting the tree again gives the same names
+		if err := EncryptDir(src, enc, "pass", obfuscate...); err != nil {
+			t.Fatal(err)
+		}
+		if after := names(); !reflect.DeepEqual(after, before) {
+			t.Fatalf("got %v, want %v", after, before)
+		}
+
+		if err := DecryptDir(enc, dec, "pass", testKDF); err != nil {
+			t.Fatal(err)
+		}
+		for path, want := range map[string]string{"secret.txt": "top", filepath.Join("plans", "deep.txt"): "deep"} {
+			if got, err := os.ReadFile(filepath.Join(dec, path)); err != nil || string(got) != want {
+				t.Fatalf("%s: got %q, %v, want %q", path, got, err, want)
+			}
+		}
+	}
+
+	// Collisions are resolved the same way every time
+	n := &namer{key: make([]byte, 32), taken: make(map[string]bool)}
+	first, second := n.name("a"), n.name("a")
+	n = &namer{key: make([]byte, 32), taken: make(map[string]bool)}
+	if first == second || n.name("a") != first || n.name("a") != second {
+		t.Fatal("collision not resolved deterministically")
+	}
+
+}
+
+func TestEncryptDirContext(t *testing.T) {
+
+	src, dst := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		writeTestFile(t, filepath.Join(src, name), "data "+name)
+	}
+
+	// Cancelled after the first file, the others aren't started
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := WithProgress(func(done, total int64) { cancel() })
+	if err := EncryptDirContext(ctx, src, dst, "pass", testKDF, progress); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"a.txt.3dfx", "a.txt.salt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got %q, want only the first file %q", names, want)
+	}
+	out := t.TempDir()
+	if err := DecryptFileStream("a.txt", dst+"/", out+"/", "pass", testKDF); err != nil {
+		t.Fatalf("got %v, want the first file complete", err)
+	}
+
+	// The file being encrypted when ctx is done is removed
+	if err := encryptFileStream(ctx, filepath.Join(src, "b.txt"), filepath.Join(dst, "b.txt"), "pass", []Option{testKDF}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "b.txt.3dfx")); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want no partial file", err)
+	}
+
+}
+
+// Function to read every file of a tree for a test
+//
+//   t   *testing.T - Test
+//   dir string     - Root of the tree
+func readTestTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestConcurrency(t *testing.T) {
+
+	src := t.TempDir()
+	for i := 0; i < 24; i++ {
+		writeTestFile(t, filepath.Join(src, fmt.Sprintf("d%d/f%02d.txt", i%3, i)), strings.Repeat(fmt.Sprint(i), 100*i))
+	}
+
+	// Workers give the same tree as a sequential run (run with -race to
+	// check they share nothing unguarded)
+	seq, par := t.TempDir(), t.TempDir()
+	if err := EncryptDir(src, seq, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptDir(src, par, "pass", testKDF, WithConcurrency(8)); err != nil {
+		t.Fatal(err)
+	}
+	for _, enc := range []string{seq, par} {
+		out := t.TempDir()
+		if err := DecryptDir(enc, out, "pass", testKDF, WithConcurrency(8)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readTestTree(t, out), readTestTree(t, src); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %d files, want the %d of the source", len(got), len(want))
+		}
+	}
+
+	// Errors come out in walk order whatever finishes first
+	for i := 0; i < 24; i += 5 {
+		writeTestFile(t, filepath.Join(par, fmt.Sprintf("d%d/f%02d.txt.3dfx", i%3, i)), "garbage")
+	}
+	var msgs []string
+	for _, n := range []int{1, 8} {
+		err := DecryptDir(par, t.TempDir(), "pass", testKDF, WithConcurrency(n))
+		var de *DirError
+		if !errors.As(err, &de) || len(de.Errs) != 5 {
+			t.Fatalf("concurrency %d: got %v, want a *DirError of 5 files", n, err)
+		}
+		msgs = append(msgs, de.Error())
+	}
+	if msgs[0] != msgs[1] {
+		t.Fatalf("got %q with workers, want %q", msgs[1], msgs[0])
+	}
+
+}
@@ -0,0 +1,197 @@
+package gocrypt
+
+import (
+	"fmt"
+	"io"
+)
+
+// Encrypter holds a passphrase and Options to run many operations with the
+// same settings.
+//
+// The Options are built once by New, so the key derivation cost and cipher
+// are configured in one place. Every method takes Options too, applied on
+// top of the ones given to New for that call only. An Encrypter is safe for
+// concurrent use.
+//
+// Keys derived from the passphrase are cached by salt, so operations
+// reusing a salt (ie. EncryptWithSalt, or decrypting what was encrypted
+// with the same Encrypter) skip the key derivation. The ciphers set up from
+// those keys are pooled and reused as well. Call ClearKeys to wipe them
+// once done.
+type Encrypter struct {
+	pass string
+	opts Options
+}
+
+// Function to create an Encrypter
+//
+// Variables to pass in:
+//
+//   pass string - Passphrase to use
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   *Encrypter - Encrypter
+//   error      - Error
+func New(pass string, opts ...Option) (*Encrypter, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("new encrypter: options: %w", err)
+	}
+
+	// Set directly so locked Options get it too
+	o.keys = newKeyCache()
+
+	// A locked copy, so neither the caller nor a call can change it later
+	return &Encrypter{pass: pass, opts: o.Lock()}, nil
+
+}
+
+// Function to get the Options of the Encrypter followed by those of a call
+//
+//   opts []Option - Options of the call
+func (e *Encrypter) with(opts []Option) []Option {
+
+	// The Options of the call apply to an unlocked copy
+	o := e.opts.clone()
+	o.locked = false
+
+	return append([]Option{WithOptions(o)}, opts...)
+
+}
+
+// Function to wipe the cached keys and drop the pooled ciphers
+//
+// Keys are derived again as needed afterwards.
+func (e *Encrypter) ClearKeys() {
+	e.opts.keys.clear()
+}
+
+// Function to get an unlocked copy of the Options in use
+//
+// Returns:
+//
+//   Options - Options in use
+func (e *Encrypter) Options() Options {
+	// The cached keys are only valid with the passphrase of e
+	o := e.opts.clone()
+	o.keys = nil
+	o.locked = false
+	return o
+}
+
+// Function to encrypt data (see Encrypt)
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   []byte - Salt
+//   error  - Error
+func (e *Encrypter) Encrypt(data []byte, opts ...Option) ([]byte, []byte, error) {
+	return Encrypt(data, e.pass, e.with(opts)...)
+}
+
+// Function to encrypt data with a given salt (see EncryptWithSalt)
+//
+// The key of the salt is only derived the first time.
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   salt []byte - Salt to use to create hash
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   error  - Error
+func (e *Encrypter) EncryptWithSalt(data, salt []byte, opts ...Option) ([]byte, error) {
+	return EncryptWithSalt(data, salt, e.pass, e.with(opts)...)
+}
+
+// Function to decrypt data (see Decrypt)
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be decrypted
+//   salt []byte - Salt to use to create hash
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func (e *Encrypter) Decrypt(data, salt []byte, opts ...Option) ([]byte, error) {
+	return Decrypt(data, salt, e.pass, e.with(opts)...)
+}
+
+// Function to encrypt an existing file (see EncryptFile)
+//
+// Variables to pass in:
+//
+//   file string - Name of the file
+//   from string - Specify path of file
+//   to   string - Specify destination path to output file
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func (e *Encrypter) EncryptFile(file, from, to string, opts ...Option) error {
+	return EncryptFile(file, from, to, e.pass, e.with(opts)...)
+}
+
+// Function to decrypt a file (see DecryptFile)
+//
+// Variables to pass in:
+//
+//   file string - Name of the file
+//   from string - Specify path of file
+//   to   string - Specify destination path to output file
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func (e *Encrypter) DecryptFile(file, from, to string, opts ...Option) error {
+	return DecryptFile(file, from, to, e.pass, e.with(opts)...)
+}
+
+// Function to encrypt a stream of data (see EncryptStream)
+//
+// Variables to pass in:
+//
+//   dst io.Writer - Writer to output the encrypted stream to
+//   src io.Reader - Reader to get the data to be encrypted from
+//   opts          - Options (optional)
+//
+// Returns:
+//
+//   Meta  - Salt and details of the plaintext
+//   error - Error
+func (e *Encrypter) EncryptStream(dst io.Writer, src io.Reader, opts ...Option) (Meta, error) {
+	return EncryptStream(dst, src, e.pass, e.with(opts)...)
+}
+
+// Function to decrypt a stream of data (see DecryptStream)
+//
+// Variables to pass in:
+//
+//   dst io.Writer - Writer to output the decrypted data to
+//   src io.Reader - Reader to get the encrypted stream from
+//   salt []byte   - Salt to use to create hash (nil if stored in the stream)
+//   opts          - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func (e *Encrypter) DecryptStream(dst io.Writer, src io.Reader, salt []byte, opts ...Option) error {
+	return DecryptStream(dst, src, salt, e.pass, e.with(opts)...)
+}
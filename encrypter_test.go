@@ -0,0 +1,76 @@
+package gocrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncrypter(t *testing.T) {
+
+	e, err := New("pass", testKDF, WithCipher(ChaCha20Poly1305))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ct, salt, err := e.Encrypt([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := e.Decrypt(ct, salt); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	if pt, err := Decrypt(ct, salt, "pass"); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// Options of a call apply on top of the ones of the Encrypter
+	var buf, out bytes.Buffer
+	meta, err := e.EncryptStream(&buf, bytes.NewReader([]byte("stream")), WithComment("call"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := Inspect(bytes.NewReader(buf.Bytes()))
+	if err != nil || info.Algorithm != ChaCha20Poly1305 || info.Comment != "call" {
+		t.Fatalf("got %+v, %v", info, err)
+	}
+	if err := e.DecryptStream(&out, &buf, meta.Salt); err != nil || out.String() != "stream" {
+		t.Fatalf("got %q, %v", out.String(), err)
+	}
+
+}
+
+func TestEncrypterSnapshot(t *testing.T) {
+
+	o, err := NewOptions(testKDF, WithComment("before"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New("pass", WithOptions(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.opts.Locked() {
+		t.Fatal("the Options of the Encrypter aren't locked")
+	}
+
+	// Neither changing the source nor the options of a call reach it
+	if err := o.Apply(WithComment("after")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := e.Encrypt([]byte("data"), WithComment("call")); err != nil {
+		t.Fatal(err)
+	}
+	if e.opts.Comment != "before" {
+		t.Fatalf("comment changed to %q", e.opts.Comment)
+	}
+
+	// The copy handed out can be changed
+	c := e.Options()
+	if err := c.Apply(WithComment("copy")); err != nil {
+		t.Fatal(err)
+	}
+	if e.opts.Comment != "before" {
+		t.Fatalf("comment changed to %q", e.opts.Comment)
+	}
+
+}
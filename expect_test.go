@@ -0,0 +1,37 @@
+package gocrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecryptStreamExpect(t *testing.T) {
+
+	data := make([]byte, 3*minChunkSize)
+	ct, salt := encryptTestStream(t, data, WithChunkSize(minChunkSize))
+
+	var out bytes.Buffer
+	if err := DecryptStreamExpect(&out, bytes.NewReader(ct), salt, "pass", int64(len(data))); err != nil || out.Len() != len(data) {
+		t.Fatalf("got %d bytes, %v", out.Len(), err)
+	}
+
+	// A complete, valid stream ending at a frame boundary short of the
+	// expected length
+	short, shortSalt := encryptTestStream(t, data[:2*minChunkSize], WithChunkSize(minChunkSize))
+	if err := DecryptStream(&out, bytes.NewReader(short), shortSalt, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptStreamExpect(&out, bytes.NewReader(short), shortSalt, "pass", int64(len(data))); err != ErrLengthMismatch {
+		t.Fatalf("got %v, want ErrLengthMismatch", err)
+	}
+
+	// A longer stream is stopped before going past the expected length
+	out.Reset()
+	if err := DecryptStreamExpect(&out, bytes.NewReader(ct), salt, "pass", minChunkSize+1); err != ErrLengthMismatch {
+		t.Fatalf("got %v, want ErrLengthMismatch", err)
+	}
+	if out.Len() > minChunkSize+1 {
+		t.Fatalf("wrote %d bytes, more than expected", out.Len())
+	}
+
+}
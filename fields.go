@@ -0,0 +1,122 @@
+package gocrypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Function to derive the subkey of a field from the master key
+//
+//   master []byte - Master key derived from the passphrase
+//   name   string - Name of the field
+func fieldKey(master []byte, name string) ([]byte, error) {
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("gocrypt field "+name)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+
+}
+
+// Function to encrypt each value of a map with its own key
+//
+// The passphrase is only run through scrypt once. Every field is then sealed
+// with a subkey derived from that master key and the field name (HKDF), and
+// the field name is authenticated with the value, so fields can be decrypted
+// individually and a value can't be swapped into another field.
+//
+// Variables to pass in:
+//
+//   m map[string][]byte - Fields to be encrypted
+//   pass string         - Passphrase to use for encryption
+//
+// Returns:
+//
+//   map[string][]byte - Encrypted fields
+//   []byte            - Salt
+//   error             - Error
+func EncryptFields(m map[string][]byte, pass string) (map[string][]byte, []byte, error) {
+
+	salt, master, err := createHash(nil, pass, defaultOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer Scrub(master)
+
+	out := make(map[string][]byte, len(m))
+	for name, value := range m {
+
+		key, err := fieldKey(master, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encrypt fields: key: %w", err)
+		}
+
+		gcm, err := buildAEAD(AES256GCM, key)
+		Scrub(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encrypt fields: GCM: %w", err)
+		}
+
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, nil, fmt.Errorf("encrypt fields: nonce: %w", err)
+		}
+		out[name] = gcm.Seal(nonce, nonce, value, []byte(name))
+
+	}
+
+	return out, salt, nil
+
+}
+
+// Function to decrypt a single field encrypted by EncryptFields
+//
+// Variables to pass in:
+//
+//   name string - Name of the field
+//   ct []byte   - Encrypted value of the field
+//   salt []byte - Salt returned by EncryptFields
+//   pass string - Passphrase to use for decryption
+//
+// Returns:
+//
+//   []byte - Decrypted value
+//   error  - Error
+func DecryptField(name string, ct, salt []byte, pass string) ([]byte, error) {
+
+	_, master, err := createHash(salt, pass, defaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer Scrub(master)
+
+	key, err := fieldKey(master, name)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field: key: %w", err)
+	}
+
+	gcm, err := buildAEAD(AES256GCM, key)
+	Scrub(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field: GCM: %w", err)
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ct) < nonceSize {
+		return nil, fmt.Errorf("%w data: ciphertext too short", ErrCorrupted)
+	}
+
+	plaintext, err := gcm.Open(nil, ct[:nonceSize], ct[nonceSize:], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field: GCM open: %w", ErrAuthFailed)
+	}
+
+	return plaintext, nil
+
+}
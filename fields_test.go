@@ -0,0 +1,32 @@
+package gocrypt
+
+import "testing"
+
+func TestEncryptFields(t *testing.T) {
+
+	fields := map[string][]byte{"name": []byte("Ada"), "email": []byte("ada@example.com")}
+	ct, salt, err := EncryptFields(fields, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ct) != len(fields) {
+		t.Fatalf("got %d fields, want %d", len(ct), len(fields))
+	}
+
+	// Each field decrypts on its own
+	for name, want := range fields {
+		pt, err := DecryptField(name, ct[name], salt, "pass")
+		if err != nil || string(pt) != string(want) {
+			t.Fatalf("%s: got %q, %v", name, pt, err)
+		}
+	}
+
+	// A value moved to another field doesn't
+	if _, err := DecryptField("name", ct["email"], salt, "pass"); err == nil {
+		t.Fatal("decrypted the email as the name")
+	}
+	if _, err := DecryptField("name", ct["name"], salt, "wrong"); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+
+}
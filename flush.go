@@ -0,0 +1,134 @@
+package gocrypt
+
+import (
+	"io"
+	"time"
+)
+
+// Size of the reads done by a timedChunkReader
+const timedReadSize = 32 * 1024
+
+// Piece of input read by a timedChunkReader
+type timedPiece struct {
+	b   []byte
+	err error
+}
+
+// Reader splitting its input in chunks like chunkReader, but also ending a
+// chunk once Options.FlushInterval has passed since its first byte
+//
+// The input is read from a goroutine so a slow reader can't hold a partial
+// chunk back. A chunk may end up empty only when it's the last one, but the
+// stream may end with an empty frame after a full one.
+type timedChunkReader struct {
+	pieces   chan timedPiece
+	done     chan struct{}
+	interval time.Duration
+	pending  []byte // Rest of the last piece read
+	eof      bool
+}
+
+// Function to start reading an input in the background
+//
+//   r        io.Reader     - Reader to get the data from
+//   interval time.Duration - Longest time data is held before being sealed
+func newTimedChunkReader(r io.Reader, interval time.Duration) *timedChunkReader {
+
+	t := &timedChunkReader{pieces: make(chan timedPiece), done: make(chan struct{}), interval: interval}
+
+	go func() {
+		empty := 0
+		for {
+			b := make([]byte, timedReadSize)
+			n, err := r.Read(b)
+			if n == 0 && err == nil {
+				if empty++; empty < maxEmptyReads {
+					continue
+				}
+				err = io.ErrNoProgress
+			}
+			empty = 0
+			if n > 0 {
+				select {
+				case t.pieces <- timedPiece{b: b[:n]}:
+				case <-t.done:
+					return
+				}
+			}
+			if err != nil {
+				select {
+				case t.pieces <- timedPiece{err: err}:
+				case <-t.done:
+				}
+				return
+			}
+		}
+	}()
+
+	return t
+
+}
+
+// Function to fill the next chunk
+//
+//   buf []byte - Chunk to fill
+func (t *timedChunkReader) next(buf []byte) (int, bool, error) {
+
+	n := 0
+	var timeout <-chan time.Time
+	for {
+
+		if len(t.pending) > 0 {
+			m := copy(buf[n:], t.pending)
+			t.pending = t.pending[m:]
+			if n == 0 && m > 0 {
+				timer := time.NewTimer(t.interval)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+			n += m
+			if n == len(buf) {
+				return n, false, nil
+			}
+		}
+		if t.eof {
+			return n, true, nil
+		}
+
+		select {
+		case p := <-t.pieces:
+			if p.err == io.EOF {
+				t.eof = true
+			} else if p.err != nil {
+				return n, false, p.err
+			}
+			t.pending = p.b
+		case <-timeout:
+			return n, false, nil
+		}
+
+	}
+
+}
+
+// Function to stop reading the input
+//
+// A read already under way can't be interrupted, the goroutine exits once
+// it returns.
+func (t *timedChunkReader) stop() {
+	close(t.done)
+}
+
+// Function to flush an output that buffers what is written to it, like a
+// bufio.Writer
+//
+//   w io.Writer - Writer to flush
+func flushOutput(w io.Writer) error {
+
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+
+}
@@ -0,0 +1,79 @@
+package gocrypt
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Buffer that can be written and read concurrently
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Function to get a copy of what was written so far
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// Function to count the frames of a stream that can be decrypted so far
+//
+//   data []byte - Stream written so far
+func countFrames(data []byte) int {
+
+	n := 0
+	DecryptEachFrame(bytes.NewReader(data), nil, "pass", func(frame []byte) error {
+		n++
+		return nil
+	})
+
+	return n
+
+}
+
+func TestFlushInterval(t *testing.T) {
+
+	pr, pw := io.Pipe()
+	dst := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := EncryptStream(dst, pr, "pass", testKDF, WithSaltPosition(SaltHeader), WithFlushInterval(10*time.Millisecond))
+		done <- err
+	}()
+
+	// Each event is sealed on its own while the source is still open, far
+	// below the chunk size
+	for i := 1; i <= 3; i++ {
+		if _, err := pw.Write([]byte("event\n")); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for countFrames(dst.Bytes()) < i {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d frames, want %d before the source is closed", countFrames(dst.Bytes()), i)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(dst.Bytes()), nil, "pass"); err != nil || out.String() != "event\nevent\nevent\n" {
+		t.Fatalf("got %q, %v", out.String(), err)
+	}
+
+}
@@ -2,4 +2,14 @@ module github.com/3dfosi/gocrypt
 
 go 1.18
 
-require golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90
+require (
+	github.com/klauspost/reedsolomon v1.11.3
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90
+	golang.org/x/term v0.4.0
+)
+
+require (
+	github.com/klauspost/cpuid/v2 v2.1.1 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+)
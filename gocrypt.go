@@ -4,20 +4,80 @@
 package gocrypt
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"bytes"
+	"context"
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
+	"time"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/scrypt"
 )
 
+// Error wrapped by the errors returned when data fails authentication on
+// decryption: the passphrase (or salt) is wrong or the data was modified,
+// which can't be told apart. Data encrypted with Options.Canary reports a
+// wrong passphrase as ErrWrongPassphrase instead.
+var ErrAuthFailed = errors.New("message authentication failed")
+
+// Error wrapped by the errors returned when data is malformed, ie. too
+// short or with an invalid header, rather than failing authentication
+var ErrCorrupted = errors.New("corrupted")
+
+// Default scrypt cost parameters, see KDFParams
+const (
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// Function deriving keys, replaceable to simulate failures
+var scryptKey = scrypt.Key
+
+// Delay before retrying a failed key derivation, doubled on every retry
+const kdfRetryDelay = 10 * time.Millisecond
+
+// Minimum size in bytes of a salt made by GenerateSalt
+const minSaltSize = 16
+
+// Function to generate a random salt
+//
+// Use it to make salts for EncryptWithSalt or Encrypter.EncryptWithSalt.
+// The salts Encrypt generates itself are Options.KDF.SaltLen bytes long
+// (16 by default, set it with WithKDFParams), and their length is recorded with them (in the header or as the size of
+// the .salt file), so nothing needs to be set to decrypt.
+//
+// Variables to pass in:
+//
+//   n int - Size of the salt in bytes (16 to 255)
+//
+// Returns:
+//
+//   []byte - Salt
+//   error  - Error
+func GenerateSalt(n int) ([]byte, error) {
+
+	if n < minSaltSize || n > 255 {
+		return nil, fmt.Errorf("generate salt: size must be between %d and 255 bytes, got %d", minSaltSize, n)
+	}
+
+	salt, err := genSalt(n)
+	if err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	return salt, nil
+
+}
+
 // Function to generate a random salt
 //
-//   nByte in = Byte size of salt
+//   nByte int - Byte size of salt
 func genSalt(nByte int) ([]byte, error) {
 	b := make([]byte, nByte)
 	_, err := io.ReadFull(rand.Reader, b)
@@ -30,21 +90,129 @@ func genSalt(nByte int) ([]byte, error) {
 
 // Function to create a hash with scrypt
 //
+// The key is returned as a slice so it can be wiped with Scrub once done
+// with, which callers must do.
+//
 //  salt []byte - Salt to create hash
 //  pass string - Passphrase
-func createHash(salt []byte, pass string) ([]byte, string, error) {
+//  o Options   - Options in use
+func createHash(salt []byte, pass string, o Options) ([]byte, []byte, error) {
+	return createHashContext(context.Background(), salt, pass, o)
+}
+
+// Function to create a hash with scrypt, giving up if ctx is done while
+// waiting for a derivation slot
+//
+// The key is always derived from pass, so a ciphertext can't be decrypted
+// with another passphrase. Only Options.LegacyKeyDerivation brings back the
+// constant input used by versions before the fix, to read old data.
+//
+//  ctx context.Context - Context
+//  salt []byte         - Salt to create hash
+//  pass string         - Passphrase
+//  o Options           - Options in use
+func createHashContext(ctx context.Context, salt []byte, pass string, o Options) ([]byte, []byte, error) {
 
 	if salt == nil {
-		salt, _ = genSalt(16)
+		var err error
+		if salt, err = genSalt(o.KDF.resolve().SaltLen); err != nil {
+			return nil, nil, fmt.Errorf("salt: %w", err)
+		}
+	}
+
+	// Parameters read from a header are checked before anything is
+	// allocated for them
+	if err := o.KDF.checkCost(o); err != nil {
+		return salt, nil, err
+	}
+
+	var cacheID string
+	if o.keys != nil {
+		cacheID = keyCacheID(salt, o)
+		if key := o.keys.get(cacheID); key != nil {
+			return salt, key, nil
+		}
+	}
+
+	release, err := acquireKDF(ctx, o)
+	if err != nil {
+		return salt, nil, fmt.Errorf("scrypt: %w", err)
 	}
 
-	dk, err := scrypt.Key([]byte("some password"), salt, 32768, 8, 1, 32)
+	// Versions before the fix always derived the key from this literal
+	// instead of the passphrase
+	input := []byte(pass)
+	if o.LegacyKeyDerivation {
+		input = []byte("some password")
+	}
+	// An abandoned derivation may still be reading the copy, but its key is
+	// thrown away anyway
+	defer Scrub(input)
+
+	var dk []byte
+	if o.MaxKDFDuration > 0 {
+		dk, err = deriveKeyWatched(ctx, input, salt, o, release)
+	} else {
+		dk, err = deriveKey(ctx, input, salt, o)
+		release()
+	}
 	if err != nil {
-		log.Println("Scrypt Error:", err)
-		return salt, string(dk), err
+		return salt, nil, fmt.Errorf("scrypt: %w", err)
+	}
+
+	if o.Purpose != "" {
+		pk, err := purposeKey(dk, o.Purpose)
+		Scrub(dk)
+		if err != nil {
+			return salt, nil, fmt.Errorf("purpose key: %w", err)
+		}
+		dk = pk
+	}
+
+	if len(o.pepper) > 0 {
+		pk, err := pepperKey(dk, o.pepper)
+		Scrub(dk)
+		if err != nil {
+			return salt, nil, fmt.Errorf("pepper key: %w", err)
+		}
+		dk = pk
+	}
+
+	if o.keys != nil {
+		o.keys.put(cacheID, dk)
+	}
+
+	return salt, dk, nil
+
+}
+
+// Function to run the key derivation, retrying failed scrypt derivations as
+// configured
+//
+//   ctx   context.Context - Context to stop waiting between retries on
+//   input []byte          - Passphrase to derive the key from
+//   salt  []byte          - Salt
+//   o     Options         - Options in use
+func deriveKey(ctx context.Context, input, salt []byte, o Options) ([]byte, error) {
+
+	p := o.KDF.resolve()
+	if p.Func == Argon2id {
+		return argon2.IDKey(input, salt, uint32(p.Time), uint32(p.Memory), uint8(p.Threads), uint32(p.KeyLen)), nil
 	}
 
-	return salt, string(dk), nil
+	delay := kdfRetryDelay
+	for attempt := 0; ; attempt++ {
+		dk, err := scryptKey(input, salt, p.N, p.R, p.P, p.KeyLen)
+		if err == nil || attempt >= o.KDFRetries {
+			return dk, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
 
 }
 
@@ -54,34 +222,112 @@ func createHash(salt []byte, pass string) ([]byte, string, error) {
 //
 //   data []byte - Data to be encrypted
 //   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   []byte - Salt
+//   error  - Error
+func Encrypt(data []byte, pass string, opts ...Option) ([]byte, []byte, error) {
+	return EncryptContext(context.Background(), data, pass, opts...)
+}
+
+// Function to encrypt data, giving up if ctx is done while waiting for a
+// key derivation slot (see SetMaxConcurrentDerivations)
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context
+//   data []byte         - Data to be encrypted
+//   pass string         - Passphrase to use for encryption
+//   opts                - Options (optional)
 //
 // Returns:
 //
 //   []byte - Encrypted Data
 //   []byte - Salt
 //   error  - Error
-func Encrypt(data []byte, pass string) ([]byte, []byte, error) {
+func EncryptContext(ctx context.Context, data []byte, pass string, opts ...Option) ([]byte, []byte, error) {
 
-	salt, hash, err := createHash(nil, pass)
+	o, err := newOptions(opts)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("encrypt: options: %w", err)
+	}
+
+	start := time.Now()
+	ciphertext, salt, err := encrypt(ctx, data, nil, pass, o)
+	o.observe(start, int64(len(data)), err)
+
+	return ciphertext, salt, err
+
+}
+
+// Function to encrypt data with the Options built
+//
+//   ctx  context.Context - Context
+//   data []byte          - Data to be encrypted
+//   salt []byte          - Salt to use to create hash (nil for a random one)
+//   pass string          - Passphrase to use for encryption
+//   o    Options         - Options in use
+func encrypt(ctx context.Context, data, salt []byte, pass string, o Options) ([]byte, []byte, error) {
+
+	if err := o.checkPassphrase(pass); err != nil {
+		return nil, nil, fmt.Errorf("encrypt: %w", err)
 	}
 
-	block, err := aes.NewCipher([]byte(hash))
+	salt, key, err := createHashContext(ctx, salt, pass, o)
 	if err != nil {
-		log.Println("Encrypt - Block Error:", err)
 		return nil, nil, err
 	}
+	defer Scrub(key)
+
+	// Data encrypted with its salt in the header, or a cipher, key
+	// derivation, compression, file metadata, NotBefore or a purpose set in
+	// Options, starts with a header recording them, so Decrypt knows how to
+	// get the key, which AEAD to use, whether to inflate and when it may
+	// decrypt.
+	// The header is authenticated with the data, along with the length of
+	// the plaintext so truncated data can be told apart from a wrong
+	// passphrase.
+	var header []byte
+	if o.SaltPosition == SaltHeader {
+		header = marshalHeader(Info{Version: FormatVersion, Algorithm: o.Cipher.effective(), KDF: o.KDF, Salt: salt, OneShot: true, Compressed: o.Compression, Metadata: o.metadata, Length: int64(len(data)), Canary: o.Canary, NotBefore: o.NotBefore, Purpose: o.Purpose})
+	} else if o.Cipher != DefaultAlgorithm || !o.KDF.isDefault() || o.Compression || o.metadata != nil || o.Canary || !o.NotBefore.IsZero() || o.Purpose != "" {
+		header = marshalHeader(Info{Version: FormatVersion, Algorithm: o.Cipher, KDF: o.KDF, OneShot: true, Compressed: o.Compression, Metadata: o.metadata, Length: int64(len(data)), Canary: o.Canary, NotBefore: o.NotBefore, Purpose: o.Purpose})
+	}
+
+	if o.Compression {
+		compressed, err := compress(data, o.CompressionLevel)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encrypt: compress: %w", err)
+		}
+		defer Scrub(compressed)
+		data = compressed
+	}
 
-	gcm, err := cipher.NewGCM(block)
+	gcm, release, err := newAEAD(o.Cipher.effective(), key, o.keys)
 	if err != nil {
-		log.Println("Encrypt - GCM Error:", err)
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("encrypt: AEAD: %w", err)
+	}
+	defer release()
+
+	// The canary goes along with the header, so the data authenticates it
+	if o.Canary {
+		canary, err := sealCanary(gcm, header)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encrypt: canary: %w", err)
+		}
+		header = append(header, canary...)
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
-	io.ReadFull(rand.Reader, nonce)
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("encrypt: nonce: %w", err)
+	}
+	out := make([]byte, 0, len(header)+len(nonce)+len(data)+gcm.Overhead())
+	out = append(append(out, header...), nonce...)
+	ciphertext := gcm.Seal(out, nonce, data, append(header[:len(header):len(header)], o.aad...))
 
 	return ciphertext, salt, nil
 }
@@ -93,70 +339,317 @@ func Encrypt(data []byte, pass string) ([]byte, []byte, error) {
 //   data []byte - Data to be encrypted
 //   salt []byte - Salt to use to create hash
 //   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func Decrypt(data []byte, salt []byte, pass string, opts ...Option) ([]byte, error) {
+	return DecryptContext(context.Background(), data, salt, pass, opts...)
+}
+
+// Function to decrypt data, giving up if ctx is done while waiting for a
+// key derivation slot (see SetMaxConcurrentDerivations)
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context
+//   data []byte         - Data to be decrypted
+//   salt []byte         - Salt to use to create hash
+//   pass string         - Passphrase to use for decryption
+//   opts                - Options (optional)
 //
 // Returns:
 //
 //   []byte - Decrypted Data
 //   error  - Error
-func Decrypt(data []byte, salt []byte, pass string) ([]byte, error) {
+func DecryptContext(ctx context.Context, data []byte, salt []byte, pass string, opts ...Option) ([]byte, error) {
 
-	_, hash, err := createHash([]byte(salt), pass)
+	o, err := newOptions(opts)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("decrypt: options: %w", err)
+	}
+
+	start := time.Now()
+	plaintext, err := decrypt(ctx, data, salt, pass, o)
+	if err == nil && o.TextMode {
+		plaintext, err = convertText(plaintext, o.LineEnding)
+		if err != nil {
+			err = fmt.Errorf("decrypt: text: %w", err)
+		}
+	}
+	o.observe(start, int64(len(plaintext)), err)
+
+	return plaintext, err
+
+}
+
+// Function to decrypt data with the Options built
+//
+//   ctx  context.Context - Context
+//   data []byte          - Data to be decrypted
+//   salt []byte          - Salt to use to create hash
+//   pass string          - Passphrase to use for decryption
+//   o    Options         - Options in use
+func decrypt(ctx context.Context, data []byte, salt []byte, pass string, o Options) ([]byte, error) {
+
+	// The parameters recorded in the header take precedence
+	info, header, body, herr := splitSealed(data)
+	kdf := o
+	if header != nil {
+		kdf.KDF = info.KDF
+	}
+	if salt == nil {
+		salt = info.Salt
+	}
+	if len(salt) == 0 && herr != nil {
+		return nil, fmt.Errorf("decrypt: header: %w", herr)
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("decrypt: no salt given or stored in the header (is a .salt file missing?)")
+	}
+
+	// The time is authenticated with the data, so changing it in the
+	// header only makes decryption fail
+	if !info.NotBefore.IsZero() && o.now().Before(info.NotBefore) {
+		return nil, ErrNotYetValid
+	}
+
+	// The key depends on the purpose anyway, checking it first gives a clear
+	// error instead of a failed authentication. Headerless data has nowhere
+	// to record one.
+	if header != nil && info.Purpose != o.Purpose {
+		return nil, ErrPurposeMismatch
+	}
+
+	// Checked before deriving the key so truncated data or the wrong
+	// cipher fails fast. Compressed data is sealed shorter than its length.
+	if n := int64(len(body)) - sealOverhead; info.Length > 0 && !info.Compressed && n < info.Length {
+		if n < 0 {
+			n = 0
+		}
+		return nil, fmt.Errorf("%w data: %d of %d bytes", ErrTruncated, n, info.Length)
+	}
+	if len(body) < sealOverhead {
+		return nil, fmt.Errorf("%w data: ciphertext too short", ErrCorrupted)
+	}
+	if err := checkAlgorithm(info.Algorithm, o.Cipher); err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	if err := checkKDF(info.KDF, o.KDF); err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
 	}
 
-	key := []byte(hash)
-	block, err := aes.NewCipher(key)
+	_, key, err := createHashContext(ctx, salt, pass, kdf)
 	if err != nil {
-		log.Println("Decrypt - Block Error:", err)
 		return nil, err
 	}
+	defer Scrub(key)
+
+	// A wrong passphrase fails here, before the data is opened
+	if info.Canary {
+		if err := checkSealedCanary(info.Algorithm, key, header, o.keys); err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+	}
+
+	plaintext, err := openSealed(info.Algorithm, key, body, append(header[:len(header):len(header)], o.aad...), o.keys)
+	if err != nil && header != nil && (o.Cipher == DefaultAlgorithm || o.Cipher == AES256GCM) {
+		// Headerless data whose nonce happens to look like a header
+		if legacy, lerr := openSealed(AES256GCM, key, data, o.aad, o.keys); lerr == nil {
+			plaintext, err, herr, info = legacy, nil, nil, Info{}
+		}
+	}
+	if err != nil && herr != nil {
+		// More likely a header this version doesn't understand than
+		// headerless data whose nonce starts like one
+		return nil, fmt.Errorf("decrypt: header: %w", herr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	if info.Compressed {
+		inflated, err := decompress(plaintext, o.MaxOutputSize)
+		Scrub(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+		plaintext = inflated
+	}
+	if o.MaxOutputSize > 0 && int64(len(plaintext)) > o.MaxOutputSize {
+		Scrub(plaintext)
+		return nil, ErrTooLarge
+	}
+
+	return plaintext, nil
+
+}
+
+// Function to split data encrypted by Encrypt into its header, if any, and
+// the nonce followed by the ciphertext
+//
+// Data without a header is AES-256-GCM with a key derived by scrypt. Data
+// starting with the magic bytes but no valid header is treated as
+// headerless, the error reading the header is returned along to report if
+// it doesn't decrypt that way either.
+//
+//   data []byte - Encrypted data
+func splitSealed(data []byte) (Info, []byte, []byte, error) {
+
+	var herr error
+	if bytes.HasPrefix(data, magic) {
+		info, raw, err := readHeader(bytes.NewReader(data))
+		if err == nil && info.OneShot {
+			// The canary is authenticated with the data as part of the
+			// header
+			n := len(raw)
+			if info.Canary {
+				if n += canarySize; n > len(data) {
+					n = len(data)
+				}
+			}
+			return info, data[:n], data[n:], nil
+		}
+		herr = err
+		if err == nil {
+			herr = errors.New("stream given to Decrypt, use DecryptStream")
+		}
+	}
+
+	return Info{Algorithm: AES256GCM, KDF: KDFParams{Func: Scrypt}}, nil, data, herr
+
+}
+
+// Function to check if data encrypted by Encrypt holds its salt, so a
+// missing salt file can be reported as such rather than as a failed
+// decryption
+//
+//   data []byte - Encrypted data
+func hasHeaderSalt(data []byte) bool {
+	info, _, _, _ := splitSealed(data)
+	return len(info.Salt) > 0
+}
+
+// Function to read the salt file of a file written by EncryptFile
+//
+// The salt in the header wins when there is one (nil is returned), as a
+// salt file left by an interrupted Rekey still holds the old salt.
+//
+//   path string - Path of the file without the .3dfx extension
+//   data []byte - Contents of the .3dfx file
+func readSaltFile(path string, data []byte) ([]byte, error) {
+
+	if hasHeaderSalt(data) {
+		return nil, nil
+	}
+
+	return ioutil.ReadFile(path + ".salt")
+
+}
+
+// Function to check the canary following the header of data encrypted by
+// Encrypt
+//
+//   alg    Algorithm - Algorithm the data was sealed with
+//   key    []byte    - Key derived from the passphrase
+//   header []byte    - Header followed by the canary, as split by splitSealed
+//   keys   *keyCache - Cache of the keys in use (nil if none)
+func checkSealedCanary(alg Algorithm, key, header []byte, keys *keyCache) error {
+
+	if len(header) < canarySize {
+		return fmt.Errorf("%w canary: invalid length", ErrCorrupted)
+	}
 
-	gcm, err := cipher.NewGCM(block)
+	aead, release, err := newAEAD(alg, key, keys)
 	if err != nil {
-		log.Println("Decrypt - GCM Error:", err)
-		return nil, err
+		return fmt.Errorf("AEAD: %w", err)
+	}
+	defer release()
+
+	n := len(header) - canarySize
+
+	return openCanary(aead, header[:n], header[n:])
+
+}
+
+// Function to open data sealed by encrypt
+//
+//   alg  Algorithm - Algorithm the data was sealed with
+//   key  []byte    - Key derived from the passphrase
+//   data []byte    - Nonce followed by the ciphertext
+//   ad   []byte    - Additional data authenticated with it
+//   keys *keyCache - Cache of the keys in use (nil if none)
+func openSealed(alg Algorithm, key, data, ad []byte, keys *keyCache) ([]byte, error) {
+
+	aead, release, err := newAEAD(alg, key, keys)
+	if err != nil {
+		return nil, fmt.Errorf("AEAD: %w", err)
 	}
+	defer release()
 
-	nonceSize := gcm.NonceSize()
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	nonceSize := aead.NonceSize()
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], ad)
 	if err != nil {
-		log.Println("Decrypt - GCM Open Error:", err)
-		return nil, err
+		return nil, fmt.Errorf("open: %w", ErrAuthFailed)
 	}
 
 	return plaintext, nil
 
 }
 
+// Function to add the option storing the salt in the header of the files
+// Encrypt writes
+//
+//   opts []Option - Options given by the caller
+func withFileHeader(opts []Option) []Option {
+	return append(opts[:len(opts):len(opts)], WithSaltPosition(SaltHeader))
+}
+
 // Function to encrypt data and output to a file
 //
+// When a destination is given the file is written there under the base
+// name of file, otherwise it's written to file as is. The file starts with
+// a header holding the salt, the cipher and the key derivation parameters,
+// so only the passphrase is needed to decrypt it.
+//
 // Variables to pass in:
 //
 //   file string - Name of the file (full path)
-//   to   string - Specify destination path of file
-//                 (must end with "/" ie. /opt/app/ instead of /opt/app)
+//   to   string - Specify destination path of file (optional)
+//   data []byte - Data to be encrypted
 //   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
 //
 // Returns:
 //
 //   []byte - Salt used to encrypt
 //   error  - Error
-func EncryptToFile(file string, to string, data []byte, passphrase string) ([]byte, error) {
+func EncryptToFile(file string, to string, data []byte, passphrase string, opts ...Option) ([]byte, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt to file: options: %w", err)
+	}
+
+	if to != "" {
+		file = filepath.Join(to, filepath.Base(file))
+	}
 
 	f, err := os.Create(file)
 	if err != nil {
-		log.Println("Encrypt to File - Create File Error:", err)
-		return nil, err
+		return nil, fmt.Errorf("encrypt to file: create file: %w", err)
 	}
 
 	defer f.Close()
-	ciphertext, salt, err := Encrypt(data, passphrase)
+	ciphertext, salt, err := Encrypt(data, passphrase, withFileHeader(opts)...)
 	if err != nil {
 		return nil, err
 	}
-	f.Write(ciphertext)
+
+	if err := writeOutput(f, ciphertext, o); err != nil {
+		return nil, fmt.Errorf("encrypt to file: write file: %w", err)
+	}
 
 	return salt, nil
 
@@ -167,7 +660,8 @@ func EncryptToFile(file string, to string, data []byte, passphrase string) ([]by
 // Variables to pass in:
 //
 //   file string - Name of the file (full path)
-//   salt []byte - Salt used to create hash
+//   salt []byte - Salt used to create hash (nil to use the one in the
+//                 header of the file)
 //   pass string - Passphrase to use for encryption
 //
 // Returns:
@@ -178,7 +672,10 @@ func DecryptFromFile(file string, salt []byte, passphrase string) ([]byte, error
 
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Println("Decrypt from File - Read File Error:", err)
+		return nil, fmt.Errorf("decrypt from file: read file: %w", err)
+	}
+	if salt == nil && !hasHeaderSalt(data) {
+		return nil, fmt.Errorf("decrypt from file: no salt given or stored in the header of %s", file)
 	}
 
 	decrypted, err := Decrypt(data, salt, passphrase)
@@ -192,6 +689,12 @@ func DecryptFromFile(file string, salt []byte, passphrase string) ([]byte, error
 
 // Function to encrypt an existing file.
 //
+// Files that are already encrypted by this package are refused with
+// ErrAlreadyEncrypted unless Options.AllowDoubleEncrypt is set. With
+// Options.PreserveMetadata the name, permissions, modification time and
+// size of the file are kept in the header. With Options.ShredSource the
+// file is overwritten and removed once it's encrypted.
+//
 // Variables to pass in:
 //
 //   file string - Name of the file
@@ -199,46 +702,136 @@ func DecryptFromFile(file string, salt []byte, passphrase string) ([]byte, error
 //   to   string - Specify destination path to output file
 //                 (must end with "/" ie. /opt/app/ instead of /opt/app)
 //   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
 //
 // Returns:
 //
 //   []byte - Salt used to encrypt
 //   error  - Error
-func EncryptFile(file string, from string, to string, passphrase string) error {
+func EncryptFile(file string, from string, to string, passphrase string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("encrypt file: options: %w", err)
+	}
+
+	// The output must be on disk before the source is gone
+	if o.ShredSource {
+		if err := checkShred(from + file); err != nil {
+			return fmt.Errorf("encrypt file: shred source: %w", err)
+		}
+		o.SyncOnClose = true
+	}
 
 	data, err := ioutil.ReadFile(from + file)
 	if err != nil {
-		log.Println("Encrypt File - Read File Error:", err)
-		return err
+		return fmt.Errorf("encrypt file: read file: %w", err)
+	}
+
+	if !o.AllowDoubleEncrypt && looksEncrypted(from+file, data) {
+		return ErrAlreadyEncrypted
 	}
 
 	toFile := file
 	if to != "" {
 		toFile = to + file
-		return err
+	}
+
+	encOpts := withFileHeader(opts)
+	if o.PreserveMetadata {
+		m, err := fileMetadata(from+file, len(data))
+		if err != nil {
+			return fmt.Errorf("encrypt file: metadata: %w", err)
+		}
+		encOpts = append(encOpts, withMetadata(m))
+	}
+	if o.BindToPath {
+		bind, err := withPathAAD(toFile + ".3dfx")
+		if err != nil {
+			return fmt.Errorf("encrypt file: bind to path: %w", err)
+		}
+		encOpts = append(encOpts, bind)
+	}
+
+	// Only the reference to the pepper is written to disk
+	var pepperRef []byte
+	if o.KeyWrapper != nil {
+		var pepper []byte
+		if pepper, pepperRef, err = newPepper(o.KeyWrapper); err != nil {
+			return fmt.Errorf("encrypt file: pepper: %w", err)
+		}
+		defer Scrub(pepper)
+		encOpts = append(encOpts, withPepper(pepper))
+		o.pepper = pepper
 	}
 
 	xf, err := os.Create(toFile + ".3dfx")
 	if err != nil {
-		log.Println("Encrypt File - Create Encrypted File Error:", err)
-		return err
+		return fmt.Errorf("encrypt file: create encrypted file: %w", err)
 	}
 
 	defer xf.Close()
-	cipherdata, salt, err := Encrypt(data, passphrase)
+	cipherdata, salt, err := Encrypt(data, passphrase, encOpts...)
 	if err != nil {
 		return err
 	}
-	xf.Write(cipherdata)
+
+	if err := writeOutput(xf, cipherdata, o); err != nil {
+		return fmt.Errorf("encrypt file: write encrypted file: %w", err)
+	}
 
 	sf, err := os.Create(toFile + ".salt")
 	if err != nil {
-		log.Println("Encrypt File - Create Salt File Error:", err)
-		return err
+		return fmt.Errorf("encrypt file: create salt file: %w", err)
 	}
 
 	defer sf.Close()
-	sf.Write(salt)
+	if _, err := sf.Write(salt); err != nil {
+		return fmt.Errorf("encrypt file: write salt file: %w", err)
+	}
+
+	if err := syncOutput(sf, o); err != nil {
+		return fmt.Errorf("encrypt file: sync salt file: %w", err)
+	}
+
+	if pepperRef != nil {
+		pf, err := os.Create(pepperPath(toFile + ".3dfx"))
+		if err != nil {
+			return fmt.Errorf("encrypt file: create pepper file: %w", err)
+		}
+		defer pf.Close()
+		if _, err := pf.Write(pepperRef); err != nil {
+			return fmt.Errorf("encrypt file: write pepper file: %w", err)
+		}
+		if err := syncOutput(pf, o); err != nil {
+			return fmt.Errorf("encrypt file: sync pepper file: %w", err)
+		}
+	}
+
+	if o.DetachedMAC {
+		mac, err := detachedMAC(cipherdata, salt, passphrase, o)
+		if err != nil {
+			return fmt.Errorf("encrypt file: mac: %w", err)
+		}
+		mf, err := os.Create(macPath(toFile + ".3dfx"))
+		if err != nil {
+			return fmt.Errorf("encrypt file: create mac file: %w", err)
+		}
+		defer mf.Close()
+		if _, err := mf.Write(mac); err != nil {
+			return fmt.Errorf("encrypt file: write mac file: %w", err)
+		}
+		if err := syncOutput(mf, o); err != nil {
+			return fmt.Errorf("encrypt file: sync mac file: %w", err)
+		}
+	}
+
+	// Only once everything above succeeded, so no data can be lost
+	if o.ShredSource {
+		if err := shredFile(from + file); err != nil {
+			return fmt.Errorf("encrypt file: shred source: %w", err)
+		}
+	}
 
 	return nil
 
@@ -246,6 +839,10 @@ func EncryptFile(file string, from string, to string, passphrase string) error {
 
 // Function to decrypt data from  a file and output to a new file
 //
+// With Options.PreserveMetadata, a file whose header keeps the metadata of
+// the original is written under its original name in the destination, with
+// its permissions and modification time.
+//
 // Variables to pass in:
 //
 //   file string - Name of the file
@@ -253,21 +850,94 @@ func EncryptFile(file string, from string, to string, passphrase string) error {
 //   to   string - Specify destination path to output file
 //                 (must end with "/" ie. /opt/app/ instead of /opt/app)
 //   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
 //
 // Returns:
 //
 //   []byte - Salt used to encrypt
 //   error  - Error
-func DecryptFile(file string, from string, to string, passphrase string) error {
+func DecryptFile(file string, from string, to string, passphrase string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("decrypt file: options: %w", err)
+	}
 
 	data, err := ioutil.ReadFile(from + file + ".3dfx")
 	if err != nil {
-		log.Println("Encrypt File - Read File Error:", err)
+		return fmt.Errorf("decrypt file: read file: %w", err)
+	}
+
+	// Files written since the salt is stored in their header don't need
+	// the salt file
+	salt, err := readSaltFile(from+file, data)
+	if err != nil {
+		return fmt.Errorf("decrypt file: read salt file: %w", err)
+	}
+
+	var mac []byte
+	if o.DetachedMAC {
+		mac, err = ioutil.ReadFile(macPath(from + file + ".3dfx"))
+		if err != nil {
+			return fmt.Errorf("decrypt file: read mac file: %w", err)
+		}
+	}
+
+	// Files encrypted with a KeyWrapper need their pepper back from it
+	pepperRef, perr := ioutil.ReadFile(pepperPath(from + file + ".3dfx"))
+	if perr != nil && !os.IsNotExist(perr) {
+		return fmt.Errorf("decrypt file: read pepper file: %w", perr)
+	}
+	if pepperRef != nil {
+		if o.KeyWrapper == nil {
+			return errors.New("decrypt file: file has a pepper but no KeyWrapper is set")
+		}
+		pepper, err := o.KeyWrapper.UnwrapKey(pepperRef)
+		if err != nil {
+			return fmt.Errorf("decrypt file: unwrap pepper: %w", err)
+		}
+		defer Scrub(pepper)
+		opts = append(opts[:len(opts):len(opts)], withPepper(pepper))
+		o.pepper = pepper
+	}
+
+	failed := 0
+	if o.MaxAttempts > 0 {
+		failed, err = readAttempts(from+file+".3dfx", data)
+		if err != nil {
+			return fmt.Errorf("decrypt file: read attempts: %w", err)
+		}
+		if failed >= o.MaxAttempts {
+			return ErrLockedOut
+		}
+		attemptDelay(failed, o.AttemptDelay)
 	}
 
-	salt, err := ioutil.ReadFile(from + file + ".salt")
+	// A wrong passphrase fails the MAC too, so it counts as an attempt.
+	// Other errors (ie. reading the file or deriving the key) don't.
+	var plaindata []byte
+	if o.DetachedMAC {
+		err = checkDetachedMAC(data, salt, mac, passphrase, o)
+	}
+	if err == nil && o.BindToPath {
+		var bind Option
+		if bind, err = withPathAAD(from + file + ".3dfx"); err == nil {
+			opts = append(opts[:len(opts):len(opts)], bind)
+		}
+	}
+	if err == nil {
+		plaindata, err = Decrypt(data, salt, passphrase, opts...)
+	}
 	if err != nil {
-		log.Println("Encrypt File - Read File Error:", err)
+		if o.MaxAttempts > 0 && (errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrMACMismatch)) {
+			if werr := writeAttempts(from+file+".3dfx", data, failed+1); werr != nil {
+				return fmt.Errorf("decrypt file: write attempts: %w", werr)
+			}
+		}
+		return err
+	}
+	if failed > 0 {
+		os.Remove(attemptsPath(from + file + ".3dfx"))
 	}
 
 	toFile := file
@@ -275,17 +945,38 @@ func DecryptFile(file string, from string, to string, passphrase string) error {
 		toFile = to + file
 	}
 
+	// The header is authenticated by now, so the name in it can be trusted
+	var meta *Metadata
+	if o.PreserveMetadata {
+		if info, _, _, _ := splitSealed(data); info.Metadata != nil {
+			meta = info.Metadata
+			toFile = filepath.Join(filepath.Dir(toFile), meta.Name)
+		}
+	}
+
 	xf, err := os.Create(toFile)
 	if err != nil {
-		log.Println("Encrypt File - Create Encrypted File Error:", err)
+		return fmt.Errorf("decrypt file: create file: %w", err)
 	}
 
 	defer xf.Close()
-	plaindata, err := Decrypt(data, salt, passphrase)
-	if err != nil {
-		return err
+	if o.SparseOutput {
+		if err := writeSparse(xf, plaindata); err != nil {
+			return fmt.Errorf("decrypt file: write file: %w", err)
+		}
+	} else if _, err := xf.Write(plaindata); err != nil {
+		return fmt.Errorf("decrypt file: write file: %w", err)
+	}
+
+	if err := syncOutput(xf, o); err != nil {
+		return fmt.Errorf("decrypt file: sync file: %w", err)
+	}
+
+	if meta != nil {
+		if err := restoreMetadata(toFile, meta); err != nil {
+			return fmt.Errorf("decrypt file: restore metadata: %w", err)
+		}
 	}
-	xf.Write(plaindata)
 
 	return nil
 
@@ -0,0 +1,202 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Cheap key derivation so tests don't spend their time in scrypt
+var testKDF = WithKDFParams(KDFParams{N: 1 << 10})
+
+func TestEncryptDecrypt(t *testing.T) {
+
+	data := []byte("hello world")
+	ct, salt, err := Encrypt(data, "pass", testKDF)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	pt, err := Decrypt(ct, salt, "pass", testKDF)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(pt, data) {
+		t.Fatalf("decrypted %q, want %q", pt, data)
+	}
+
+	if _, err := Decrypt(ct, salt, "wrong", testKDF); err == nil {
+		t.Fatal("decrypt with the wrong passphrase succeeded")
+	}
+
+}
+
+func TestPassphrasesNotInterchangeable(t *testing.T) {
+
+	salt, err := GenerateSalt(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The default key derivation, as well as cheaper parameters
+	for _, opts := range [][]Option{nil, {testKDF}} {
+
+		o, err := NewOptions(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, keyA, err := createHash(salt, "passphrase A", o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, keyB, err := createHash(salt, "passphrase B", o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(keyA, keyB) {
+			t.Fatal("two passphrases derived the same key")
+		}
+
+		ctA, err := EncryptWithSalt([]byte("secret A"), salt, "passphrase A", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctB, err := EncryptWithSalt([]byte("secret B"), salt, "passphrase B", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, c := range []struct {
+			ct   []byte
+			pass string
+			want string
+		}{
+			{ctA, "passphrase A", "secret A"},
+			{ctB, "passphrase B", "secret B"},
+			{ctA, "passphrase B", ""},
+			{ctB, "passphrase A", ""},
+			{ctA, "", ""},
+		} {
+			pt, err := Decrypt(c.ct, salt, c.pass, opts...)
+			if c.want == "" {
+				if !errors.Is(err, ErrAuthFailed) {
+					t.Fatalf("%q: got %q, %v, want ErrAuthFailed", c.pass, pt, err)
+				}
+			} else if err != nil || string(pt) != c.want {
+				t.Fatalf("%q: got %q, %v, want %q", c.pass, pt, err, c.want)
+			}
+		}
+
+	}
+
+}
+
+func TestDecryptShort(t *testing.T) {
+
+	salt, err := GenerateSalt(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shorter than a nonce, and one byte short of a nonce and a tag
+	for _, data := range [][]byte{nil, {1, 2, 3}, make([]byte, 12+16-1)} {
+		_, err := Decrypt(data, salt, "pass", testKDF)
+		if !errors.Is(err, ErrCorrupted) || !strings.Contains(err.Error(), "ciphertext too short") {
+			t.Fatalf("%d bytes: got %v, want ciphertext too short", len(data), err)
+		}
+	}
+
+}
+
+func TestDecryptFileMissing(t *testing.T) {
+
+	dir := t.TempDir() + "/"
+	if err := DecryptFile("missing", dir, dir, "pass"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing file: got %v, want fs.ErrNotExist", err)
+	}
+
+	// The salt file is named when the header has no salt
+	ct, salt, err := Encrypt([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"a.3dfx", ct, 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = DecryptFile("a", dir, dir, "pass")
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "a.salt") {
+		t.Fatalf("missing salt file: got %v", err)
+	}
+	if _, err := DecryptFromFile(dir+"a.3dfx", nil, "pass"); err == nil || !strings.Contains(err.Error(), "a.3dfx") {
+		t.Fatalf("missing salt: got %v", err)
+	}
+	if _, err := Decrypt(ct, nil, "pass"); err == nil || !strings.HasPrefix(err.Error(), "decrypt: ") {
+		t.Fatalf("missing salt: got %v", err)
+	}
+
+	if err := os.WriteFile(dir+"a.salt", salt, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile("a", dir, dir, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptFromFile(dir+"missing", nil, "pass"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing file: got %v, want fs.ErrNotExist", err)
+	}
+
+}
+
+func TestEncryptToFileTo(t *testing.T) {
+
+	src, dst := t.TempDir(), t.TempDir()
+	salt, err := EncryptToFile(filepath.Join(src, "a.3dfx"), dst, []byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "a.3dfx")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, want nothing written next to file", err)
+	}
+	pt, err := DecryptFromFile(filepath.Join(dst, "a.3dfx"), salt, "pass")
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// Without a destination the file is written as is
+	if _, err := EncryptToFile(filepath.Join(src, "b.3dfx"), "", []byte("data"), "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "b.3dfx")); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestEncryptFileTo(t *testing.T) {
+
+	src, dst := t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "data")
+	if err := EncryptFile("a.txt", src, dst, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{".3dfx", ".salt"} {
+		if _, err := os.Stat(dst + "a.txt" + ext); err != nil {
+			t.Fatalf("got %v, want %s written to the destination", err, ext)
+		}
+		if _, err := os.Stat(src + "a.txt" + ext); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("got %v, want no %s next to the source", err, ext)
+		}
+	}
+
+	out := t.TempDir() + "/"
+	if err := DecryptFile("a.txt", dst, out, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := os.ReadFile(out + "a.txt"); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+}
@@ -0,0 +1,62 @@
+package gocrypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// Header of testdata/golden.3dfx, written out field by field. The stream
+// was encrypted with testKDF, a chunk size of 1 KiB, derived nonces, the
+// salt in the header, a NotBefore time and a comment. Its plaintext is the
+// 2500 bytes i%251 and its passphrase "correct horse battery staple".
+const goldenHeader = "33444658" + "01" + "0044" + // magic, version, fields length
+	"07" + "0001" + "01" + // algorithm
+	"09" + "0004" + "00000400" + // chunk size
+	"0b" + "0004" + "0a080120" + // scrypt N=2^10, r=8, p=1, 32-byte key
+	"01" + "0010" + "adbcf11fbd05e87ef9343aa153869c68" + // salt
+	"04" + "0008" + "d8b1e54500df5dc3" + // nonce base
+	"05" + "0008" + "000000005f5e1000" + // not before
+	"0a" + "0006" + "676f6c64656e" // comment
+
+func TestGolden(t *testing.T) {
+
+	raw, err := ioutil.ReadFile("testdata/golden.3dfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hex.DecodeString(goldenHeader)
+	if !bytes.HasPrefix(raw, want) {
+		t.Fatalf("got header %x, want %s", raw[:len(want)], goldenHeader)
+	}
+
+	info, err := Inspect(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ChunkSize != 1024 || info.KDF.N != 1<<10 || len(info.Salt) != 16 ||
+		hex.EncodeToString(info.NonceBase) != "d8b1e54500df5dc3" ||
+		!info.NotBefore.Equal(time.Unix(1600000000, 0)) || info.Comment != "golden" {
+		t.Fatalf("got %+v", info)
+	}
+
+	// Writing the header back gives the same bytes
+	if got := marshalHeader(info); !bytes.Equal(got, want) {
+		t.Fatalf("got header %x, want %s", got, goldenHeader)
+	}
+
+	plaintext := make([]byte, 2500)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 251)
+	}
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(raw), nil, "correct horse battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("got %d bytes, want the %d bytes of the fixture", out.Len(), len(plaintext))
+	}
+
+}
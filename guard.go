@@ -0,0 +1,36 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Error returned when asked to encrypt a file that is already encrypted
+var ErrAlreadyEncrypted = errors.New("file is already encrypted")
+
+// Function to check if a file looks like the output of this package
+//
+// A file is considered encrypted when it starts with the magic bytes of a
+// stream, or when it's a .3dfx file with its .salt file next to it as
+// written by EncryptFile. One-shot ciphertexts have no header, so other
+// encrypted data can't be told apart from random bytes.
+//
+//   path string - Path of the file
+//   data []byte - Content of the file
+func looksEncrypted(path string, data []byte) bool {
+
+	if bytes.HasPrefix(data, magic) {
+		return true
+	}
+
+	if strings.HasSuffix(path, ".3dfx") {
+		if _, err := os.Stat(strings.TrimSuffix(path, ".3dfx") + ".salt"); err == nil {
+			return true
+		}
+	}
+
+	return false
+
+}
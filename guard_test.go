@@ -0,0 +1,41 @@
+package gocrypt
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDoubleEncrypt(t *testing.T) {
+
+	dir := t.TempDir() + "/"
+	out := t.TempDir() + "/"
+	writeTestFile(t, dir+"plain.txt", "data")
+	if err := EncryptFile("plain.txt", dir, dir, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+
+	// A .3dfx file next to its .salt file, and a stream with its magic bytes
+	stream, _ := encryptTestStream(t, []byte("data"))
+	writeTestFile(t, dir+"stream.bin", string(stream))
+	for _, name := range []string{"plain.txt.3dfx", "stream.bin"} {
+		if err := EncryptFile(name, dir, out, "pass", testKDF); !errors.Is(err, ErrAlreadyEncrypted) {
+			t.Fatalf("%s: got %v, want ErrAlreadyEncrypted", name, err)
+		}
+		if _, err := os.Stat(out + name + ".3dfx"); err == nil {
+			t.Fatalf("%s: output written though refused", name)
+		}
+	}
+
+	for _, name := range []string{"plain.txt.3dfx", "stream.bin"} {
+		if err := EncryptFile(name, dir, out, "pass", testKDF, WithAllowDoubleEncrypt(true)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		salt, _ := os.ReadFile(out + name + ".salt")
+		pt, err := DecryptFromFile(out+name+".3dfx", salt, "pass")
+		if want, _ := os.ReadFile(dir + name); err != nil || string(pt) != string(want) {
+			t.Fatalf("%s: got %d bytes, %v, want the encrypted file back", name, len(pt), err)
+		}
+	}
+
+}
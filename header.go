@@ -0,0 +1,488 @@
+package gocrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Version of the stream format written by this package
+const FormatVersion = 1
+
+// Magic bytes every encrypted stream starts with
+var magic = []byte("3DFX")
+
+// Error returned when data was written in a format version this package
+// can't decrypt, usually by a newer version of it
+var ErrUnsupportedVersion = errors.New("unsupported format version")
+
+// Size of the fixed part of a header (magic + version + fields length)
+const headerFixedSize = 4 + 1 + 2
+
+// Types of the fields that can follow the fixed part of a header
+const (
+	fieldSalt   byte = 1
+	fieldParity byte = 2
+	// Marks a stream whose salt is stored after the last frame
+	fieldSaltTrailer byte = 3
+	// Base the frame nonces are derived from
+	fieldNonceBase byte = 4
+	// Time before which the stream must not be decrypted
+	fieldNotBefore byte = 5
+	// Purpose the key was derived for
+	fieldPurpose byte = 6
+	// AEAD algorithm the frames are sealed with
+	fieldAlgorithm byte = 7
+	// Marks a stream whose frames authenticate the previous frame's tag
+	fieldHashChain byte = 8
+	// Size of the plaintext sealed into each frame
+	fieldChunkSize byte = 9
+	// Free-form label of the stream
+	fieldComment byte = 10
+	// scrypt parameters the key was derived with, when not the defaults
+	fieldKDF byte = 11
+	// Caller supplied identifier of the stream
+	fieldStreamID byte = 12
+	// Marks a stream whose header is followed by a canary
+	fieldCanary byte = 13
+	// Argon2id parameters the key was derived with
+	fieldArgon2 byte = 14
+	// Marks data sealed in one piece by Encrypt rather than a stream
+	fieldOneShot byte = 15
+	// Marks data compressed with gzip before it was sealed
+	fieldCompressed byte = 16
+	// Original path of a file whose name was obfuscated by EncryptDir,
+	// sealed with the key
+	fieldName byte = 17
+	// Name, permissions, modification time and size of the original file
+	fieldMetadata byte = 18
+	// Size of the plaintext, when known before encrypting
+	fieldLength byte = 19
+)
+
+// Maximum length of Options.Comment in bytes
+const maxCommentSize = 1024
+
+// Maximum length of Options.StreamID in bytes
+const maxStreamIDSize = 255
+
+// Maximum length of Options.Purpose in bytes
+const maxPurposeSize = 255
+
+// Size of the base derived frame nonces start from
+const nonceBaseSize = 8
+
+// Details of an encrypted stream read from its header
+type Info struct {
+	Version   int       // Format version of the stream
+	Algorithm Algorithm // AEAD algorithm the frames are sealed with
+	ChunkSize int       // Size of the plaintext sealed into each frame
+	Salt      []byte    // Salt, when stored in the header
+	Parity    Parity    // Reed-Solomon parity added to the frames, if any
+
+	// The salt is stored after the last frame
+	SaltTrailer bool
+
+	// Base the frame nonces are derived from, when they aren't stored in
+	// each frame
+	NonceBase []byte
+
+	// Time before which the stream must not be decrypted, if any
+	NotBefore time.Time
+
+	// Purpose the data was encrypted for, if any
+	Purpose string
+
+	// Each frame authenticates the tag of the previous one
+	HashChain bool
+
+	// Label of the stream, if any (authenticated but not encrypted)
+	Comment string
+
+	// scrypt parameters the key was derived with (SaltLen is left unset,
+	// the salt carries its own length)
+	KDF KDFParams
+
+	// Identifier of the stream given by the caller, if any
+	StreamID []byte
+
+	// The header is followed by a canary to detect a wrong passphrase
+	// before the frames
+	Canary bool
+
+	// The data was sealed in one piece by Encrypt rather than as a stream
+	// of frames
+	OneShot bool
+
+	// The plaintext was compressed with gzip before it was sealed
+	Compressed bool
+
+	// Size of the plaintext in bytes, if recorded (0 otherwise)
+	Length int64
+
+	// Details of the original file, if kept (authenticated but not
+	// encrypted), see Options.PreserveMetadata
+	Metadata *Metadata
+
+	// Original path of the file sealed with the key, see sealName
+	sealedName []byte
+}
+
+// Function to get the format versions this package can decrypt
+//
+// Returns:
+//
+//   []int - Supported format versions
+func SupportedFormatVersions() []int {
+	return []int{1}
+}
+
+// Function to check if a format version can be decrypted
+//
+//   version int - Format version
+func supportedFormatVersion(version int) bool {
+	for _, v := range SupportedFormatVersions() {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to build the header of a stream
+//
+// The header is made of the magic bytes, the format version and the length
+// of the fields that follow. Each field is its type, the length of its value
+// and its value:
+//
+//   magic   [4]byte  "3DFX"
+//   version uint8
+//   length  uint16   length of the fields
+//   fields  ...      [type uint8][length uint16][value]
+//
+// Every multi-byte value is written big-endian explicitly, never in the
+// byte order of the machine, so streams are portable across architectures.
+//
+//   info Info - Details to put in the header
+func marshalHeader(info Info) []byte {
+
+	var fields []byte
+	if info.Algorithm != DefaultAlgorithm {
+		fields = appendField(fields, fieldAlgorithm, []byte{byte(info.Algorithm)})
+	}
+	if info.ChunkSize != 0 && info.ChunkSize != chunkSize {
+		fields = appendField(fields, fieldChunkSize, appendUint32(nil, uint32(info.ChunkSize)))
+	}
+	if p := info.KDF.resolve(); p.Func == Argon2id {
+		v := appendUint32(appendUint32(nil, uint32(p.Time)), uint32(p.Memory))
+		fields = appendField(fields, fieldArgon2, append(v, byte(p.Threads), byte(p.KeyLen)))
+	} else if !info.KDF.isDefault() {
+		v := []byte{byte(bits.Len(uint(p.N)) - 1), byte(p.R), byte(p.P), byte(p.KeyLen)}
+		fields = appendField(fields, fieldKDF, v)
+	}
+	if len(info.Salt) > 0 {
+		fields = appendField(fields, fieldSalt, info.Salt)
+	}
+	if info.SaltTrailer {
+		fields = appendField(fields, fieldSaltTrailer, nil)
+	}
+	if len(info.NonceBase) > 0 {
+		fields = appendField(fields, fieldNonceBase, info.NonceBase)
+	}
+	if !info.NotBefore.IsZero() {
+		fields = appendField(fields, fieldNotBefore, appendUint64(nil, uint64(info.NotBefore.Unix())))
+	}
+	if info.HashChain {
+		fields = appendField(fields, fieldHashChain, nil)
+	}
+	if info.Canary {
+		fields = appendField(fields, fieldCanary, nil)
+	}
+	if info.OneShot {
+		fields = appendField(fields, fieldOneShot, nil)
+	}
+	if info.Compressed {
+		fields = appendField(fields, fieldCompressed, nil)
+	}
+	if len(info.sealedName) > 0 {
+		fields = appendField(fields, fieldName, info.sealedName)
+	}
+	if info.Length > 0 {
+		fields = appendField(fields, fieldLength, appendUint64(nil, uint64(info.Length)))
+	}
+	if info.Metadata != nil {
+		fields = appendField(fields, fieldMetadata, marshalMetadata(info.Metadata))
+	}
+	if info.Purpose != "" {
+		fields = appendField(fields, fieldPurpose, []byte(info.Purpose))
+	}
+	if len(info.StreamID) > 0 {
+		fields = appendField(fields, fieldStreamID, info.StreamID)
+	}
+	if info.Comment != "" {
+		fields = appendField(fields, fieldComment, []byte(info.Comment))
+	}
+	if info.Parity.enabled() {
+		fields = appendField(fields, fieldParity, []byte{byte(info.Parity.Data), byte(info.Parity.Shards)})
+	}
+
+	h := make([]byte, 0, headerFixedSize+len(fields))
+	h = append(h, magic...)
+	h = append(h, byte(info.Version))
+	h = appendUint16(h, uint16(len(fields)))
+
+	return append(h, fields...)
+
+}
+
+// Function to append a field to a header
+//
+//   h     []byte - Header to append to
+//   typ   byte   - Type of the field
+//   value []byte - Value of the field
+func appendField(h []byte, typ byte, value []byte) []byte {
+	h = append(h, typ)
+	h = appendUint16(h, uint16(len(value)))
+	return append(h, value...)
+}
+
+// Function to append a big-endian uint16 to a slice
+//
+//   b []byte - Slice to append to
+//   v uint16 - Value to append
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// Function to append a big-endian uint32 to a slice
+//
+//   b []byte - Slice to append to
+//   v uint32 - Value to append
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// Function to append a big-endian uint64 to a slice
+//
+//   b []byte - Slice to append to
+//   v uint64 - Value to append
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// Function to check a comment can be stored in a header
+//
+//   comment string - Comment to check
+func validateComment(comment string) error {
+
+	if len(comment) > maxCommentSize {
+		return fmt.Errorf("comment longer than %d bytes", maxCommentSize)
+	}
+	if !utf8.ValidString(comment) {
+		return errors.New("comment is not valid UTF-8")
+	}
+	for _, r := range comment {
+		if unicode.IsControl(r) {
+			return errors.New("comment contains control characters")
+		}
+	}
+
+	return nil
+
+}
+
+// Function to parse the fields of a header
+//
+//   info   *Info  - Details to fill in
+//   fields []byte - Fields following the fixed part of the header
+func parseFields(info *Info, fields []byte) error {
+
+	for len(fields) > 0 {
+
+		if len(fields) < 3 {
+			return fmt.Errorf("%w header: truncated field", ErrCorrupted)
+		}
+		typ, n := fields[0], int(binary.BigEndian.Uint16(fields[1:3]))
+		if len(fields) < 3+n {
+			return fmt.Errorf("%w header: truncated field", ErrCorrupted)
+		}
+		value := fields[3 : 3+n]
+		fields = fields[3+n:]
+
+		switch typ {
+		case fieldSalt:
+			info.Salt = append([]byte(nil), value...)
+		case fieldSaltTrailer:
+			info.SaltTrailer = true
+		case fieldNonceBase:
+			if len(value) != nonceBaseSize {
+				return fmt.Errorf("%w header: invalid nonce base field", ErrCorrupted)
+			}
+			info.NonceBase = append([]byte(nil), value...)
+		case fieldNotBefore:
+			if len(value) != 8 {
+				return fmt.Errorf("%w header: invalid not before field", ErrCorrupted)
+			}
+			info.NotBefore = time.Unix(int64(binary.BigEndian.Uint64(value)), 0)
+		case fieldAlgorithm:
+			if len(value) != 1 {
+				return fmt.Errorf("%w header: invalid algorithm field", ErrCorrupted)
+			}
+			info.Algorithm = Algorithm(value[0])
+		case fieldHashChain:
+			info.HashChain = true
+		case fieldCanary:
+			info.Canary = true
+		case fieldOneShot:
+			info.OneShot = true
+		case fieldCompressed:
+			info.Compressed = true
+		case fieldName:
+			if len(value) == 0 {
+				return fmt.Errorf("%w header: invalid name field", ErrCorrupted)
+			}
+			info.sealedName = append([]byte(nil), value...)
+		case fieldLength:
+			if len(value) != 8 || int64(binary.BigEndian.Uint64(value)) <= 0 {
+				return fmt.Errorf("%w header: invalid length field", ErrCorrupted)
+			}
+			info.Length = int64(binary.BigEndian.Uint64(value))
+		case fieldMetadata:
+			m, err := parseMetadata(value)
+			if err != nil {
+				return err
+			}
+			info.Metadata = m
+		case fieldChunkSize:
+			if len(value) != 4 {
+				return fmt.Errorf("%w header: invalid chunk size field", ErrCorrupted)
+			}
+			info.ChunkSize = int(binary.BigEndian.Uint32(value))
+			if info.ChunkSize < minChunkSize || info.ChunkSize > maxChunkSize {
+				return fmt.Errorf("%w header: invalid chunk size field", ErrCorrupted)
+			}
+		case fieldPurpose:
+			if len(value) == 0 || len(value) > maxPurposeSize {
+				return fmt.Errorf("%w header: invalid purpose field", ErrCorrupted)
+			}
+			info.Purpose = string(value)
+		case fieldKDF:
+			if len(value) != 4 || value[0] >= 32 {
+				return fmt.Errorf("%w header: invalid KDF field", ErrCorrupted)
+			}
+			info.KDF = KDFParams{Func: Scrypt, N: 1 << value[0], R: int(value[1]), P: int(value[2]), KeyLen: int(value[3])}
+			if err := info.KDF.validate(); err != nil {
+				return fmt.Errorf("%w header: invalid KDF field: %v", ErrCorrupted, err)
+			}
+		case fieldArgon2:
+			if len(value) != 10 {
+				return fmt.Errorf("%w header: invalid Argon2id field", ErrCorrupted)
+			}
+			info.KDF = KDFParams{Func: Argon2id, Time: int(binary.BigEndian.Uint32(value)), Memory: int(binary.BigEndian.Uint32(value[4:])), Threads: int(value[8]), KeyLen: int(value[9])}
+			if err := info.KDF.validate(); err != nil {
+				return fmt.Errorf("%w header: invalid Argon2id field: %v", ErrCorrupted, err)
+			}
+		case fieldStreamID:
+			if len(value) == 0 || len(value) > maxStreamIDSize {
+				return fmt.Errorf("%w header: invalid stream id field", ErrCorrupted)
+			}
+			info.StreamID = append([]byte(nil), value...)
+		case fieldComment:
+			if err := validateComment(string(value)); err != nil {
+				return fmt.Errorf("%w header: invalid comment field: %v", ErrCorrupted, err)
+			}
+			info.Comment = string(value)
+		case fieldParity:
+			if len(value) != 2 {
+				return fmt.Errorf("%w header: invalid parity field", ErrCorrupted)
+			}
+			info.Parity = Parity{Data: int(value[0]), Shards: int(value[1])}
+			if err := info.Parity.validate(); err != nil {
+				return fmt.Errorf("%w header: invalid parity field: %v", ErrCorrupted, err)
+			}
+		default:
+			return fmt.Errorf("%w header: unknown field %d", ErrCorrupted, typ)
+		}
+
+	}
+
+	return nil
+
+}
+
+// Function to read the header of a stream
+//
+// Returns the details found in the header along with its raw bytes, which
+// are authenticated with every frame.
+//
+//   r io.Reader - Reader positioned at the start of the stream
+func readHeader(r io.Reader) (Info, []byte, error) {
+
+	h := make([]byte, headerFixedSize)
+	if _, err := io.ReadFull(r, h); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("stream %w: incomplete header", ErrTruncated)
+		}
+		return Info{}, nil, err
+	}
+
+	if !bytes.Equal(h[:4], magic) {
+		return Info{}, nil, fmt.Errorf("%w header: not a gocrypt stream", ErrCorrupted)
+	}
+
+	// Streams without an algorithm, chunk size or KDF field are AES-256-GCM
+	// with 64 KiB chunks and keys derived with the default scrypt parameters
+	info := Info{Version: int(h[4]), Algorithm: AES256GCM, ChunkSize: chunkSize}
+	info.KDF = KDFParams{Func: Scrypt, N: scryptN, R: scryptR, P: scryptP, KeyLen: 32}
+	if !supportedFormatVersion(info.Version) {
+		return Info{}, nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, info.Version)
+	}
+
+	fields := make([]byte, binary.BigEndian.Uint16(h[5:]))
+	if _, err := io.ReadFull(r, fields); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("stream %w: incomplete header", ErrTruncated)
+		}
+		return Info{}, nil, err
+	}
+
+	if err := parseFields(&info, fields); err != nil {
+		return Info{}, nil, err
+	}
+
+	return info, append(h, fields...), nil
+
+}
+
+// Function to read the details of an encrypted stream without decrypting it
+//
+// Variables to pass in:
+//
+//   r io.Reader - Reader positioned at the start of the stream
+//
+// Returns:
+//
+//   Info  - Details of the stream
+//   error - Error
+func Inspect(r io.Reader) (Info, error) {
+
+	info, _, err := readHeader(r)
+	if err != nil {
+		return Info{}, fmt.Errorf("inspect: header: %w", err)
+	}
+
+	return info, nil
+
+}
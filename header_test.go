@@ -0,0 +1,116 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadHeaderCorrupted(t *testing.T) {
+
+	header := func(typ byte, value []byte) []byte {
+		fields := appendField(nil, typ, value)
+		h := append(append([]byte(nil), magic...), FormatVersion)
+		return append(appendUint16(h, uint16(len(fields))), fields...)
+	}
+
+	tests := map[string][]byte{
+		"not a gocrypt stream": []byte("not a header at all"),
+		"unknown field":        header(250, nil),
+		"invalid KDF":          header(fieldKDF, []byte{10, 64, 1, 32}),
+		"invalid Argon2id":     header(fieldArgon2, []byte{0, 0, 0, 100, 0, 0, 1, 0, 1, 32}),
+		"too many passes":      header(fieldArgon2, []byte{0, 0, 0, 17, 0, 1, 0, 0, 1, 32}),
+		"invalid comment":      header(fieldComment, []byte("bad\x00comment")),
+		"invalid parity":       header(fieldParity, []byte{0, 0}),
+		"empty purpose":        header(fieldPurpose, nil),
+		"long purpose":         header(fieldPurpose, bytes.Repeat([]byte("p"), maxPurposeSize+1)),
+	}
+	for name, h := range tests {
+		if _, _, err := readHeader(bytes.NewReader(h)); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("%s: got %v, want ErrCorrupted", name, err)
+		}
+	}
+
+}
+
+func TestDecryptErrors(t *testing.T) {
+
+	ct, salt, err := Encrypt([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Decrypt(ct, salt, "wrong"); !errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrCorrupted) {
+		t.Fatalf("wrong passphrase: got %v, want ErrAuthFailed", err)
+	}
+	if _, err := Decrypt(ct[:len(ct)-30], salt, "pass"); !errors.Is(err, ErrCorrupted) && !errors.Is(err, ErrTruncated) {
+		t.Fatalf("short data: got %v, want ErrCorrupted or ErrTruncated", err)
+	}
+	if _, err := Decrypt([]byte("tiny"), salt, "pass"); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("tiny data: got %v, want ErrCorrupted", err)
+	}
+
+}
+
+func TestInspectFormatVersion(t *testing.T) {
+
+	ct, _ := encryptTestStream(t, []byte("data"))
+	info, err := Inspect(bytes.NewReader(ct))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != FormatVersion {
+		t.Fatalf("got version %d, want %d", info.Version, FormatVersion)
+	}
+
+	path := t.TempDir() + "/f.3dfx"
+	if _, err := EncryptToFile(path, "", []byte("data"), "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if info, err = Inspect(f); err != nil || info.Version != FormatVersion || !info.OneShot {
+		t.Fatalf("got %+v, %v", info, err)
+	}
+
+	versions := SupportedFormatVersions()
+	if len(versions) == 0 || versions[len(versions)-1] != FormatVersion {
+		t.Fatalf("supported versions %v don't end with %d", versions, FormatVersion)
+	}
+
+}
+
+func TestComment(t *testing.T) {
+
+	const comment = "prod db dump 2024-06 — ünïcode"
+	stream, _ := encryptTestStream(t, []byte("data"), WithSaltPosition(SaltHeader), WithComment(comment))
+	info, err := Inspect(bytes.NewReader(stream))
+	if err != nil || info.Comment != comment {
+		t.Fatalf("got %q, %v, want %q", info.Comment, err, comment)
+	}
+
+	// The comment isn't encrypted but it is authenticated
+	tampered := bytes.Replace(stream, []byte("prod"), []byte("test"), 1)
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(tampered), nil, "pass"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	for _, invalid := range []string{strings.Repeat("a", maxCommentSize+1), "line\nbreak", "nul\x00", "\xff\xfe"} {
+		if _, err := NewOptions(WithComment(invalid)); err == nil {
+			t.Errorf("%.20q: accepted", invalid)
+		}
+		// Nor can it be set directly
+		if _, err := EncryptStream(&bytes.Buffer{}, strings.NewReader("data"), "pass", testKDF, WithOptions(Options{Comment: invalid})); err == nil {
+			t.Errorf("%.20q: accepted through WithOptions", invalid)
+		}
+	}
+	if _, err := NewOptions(WithComment(strings.Repeat("a", maxCommentSize))); err != nil {
+		t.Fatal(err)
+	}
+
+}
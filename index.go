@@ -0,0 +1,157 @@
+package gocrypt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Error returned when an id has no salt in a SaltIndex
+var ErrUnknownID = errors.New("unknown id")
+
+// Name of the bucket salts are stored in
+var saltBucket = []byte("salts")
+
+// SaltIndex keeps track of the salt of each encrypted blob in a local
+// bbolt database so callers only need to store the blob and its id.
+type SaltIndex struct {
+	db *bolt.DB
+}
+
+// Function to open (or create) a salt index
+//
+// Variables to pass in:
+//
+//   path string - Path of the index database file
+//
+// Returns:
+//
+//   *SaltIndex - Salt index
+//   error      - Error
+func OpenSaltIndex(path string) (*SaltIndex, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open salt index: open: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(saltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open salt index: create bucket: %w", err)
+	}
+
+	return &SaltIndex{db: db}, nil
+
+}
+
+// Function to close the salt index
+//
+// Returns:
+//
+//   error - Error
+func (i *SaltIndex) Close() error {
+	return i.db.Close()
+}
+
+// Function to encrypt data and store its salt in the index
+//
+// Variables to pass in:
+//
+//   id string   - Id of the blob
+//   data []byte - Data to be encrypted
+//   pass string - Passphrase to use for encryption
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   error  - Error
+func (i *SaltIndex) EncryptIndexed(id string, data []byte, pass string) ([]byte, error) {
+
+	ciphertext, salt, err := Encrypt(data, pass)
+	if err != nil {
+		return nil, err
+	}
+
+	err = i.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(saltBucket).Put([]byte(id), salt)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encrypt indexed: store salt: %w", err)
+	}
+
+	return ciphertext, nil
+
+}
+
+// Function to decrypt data using the salt stored in the index
+//
+// Variables to pass in:
+//
+//   id string   - Id of the blob
+//   data []byte - Data to be decrypted
+//   pass string - Passphrase to use for decryption
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func (i *SaltIndex) DecryptIndexed(id string, data []byte, pass string) ([]byte, error) {
+
+	salt, err := i.Salt(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decrypt(data, salt, pass)
+
+}
+
+// Function to get the salt stored for an id
+//
+// Variables to pass in:
+//
+//   id string - Id of the blob
+//
+// Returns:
+//
+//   []byte - Salt
+//   error  - Error (ErrUnknownID if the id isn't in the index)
+func (i *SaltIndex) Salt(id string) ([]byte, error) {
+
+	var salt []byte
+	err := i.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(saltBucket).Get([]byte(id))
+		if v == nil {
+			return ErrUnknownID
+		}
+		salt = append([]byte(nil), v...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decrypt indexed: get salt: %w", err)
+	}
+
+	return salt, nil
+
+}
+
+// Function to remove the salt stored for an id
+//
+// Variables to pass in:
+//
+//   id string - Id of the blob
+//
+// Returns:
+//
+//   error - Error
+func (i *SaltIndex) Delete(id string) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(saltBucket).Delete([]byte(id))
+	})
+}
@@ -0,0 +1,45 @@
+package gocrypt
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaltIndex(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "salts.db")
+	idx, err := OpenSaltIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := idx.EncryptIndexed("a", []byte("data"), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The salt is still there once the index is opened again
+	idx, err = OpenSaltIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	pt, err := idx.DecryptIndexed("a", ct, "pass")
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	if _, err := idx.DecryptIndexed("b", ct, "pass"); !errors.Is(err, ErrUnknownID) {
+		t.Fatalf("got %v, want ErrUnknownID", err)
+	}
+	if err := idx.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Salt("a"); !errors.Is(err, ErrUnknownID) {
+		t.Fatalf("got %v after Delete, want ErrUnknownID", err)
+	}
+
+}
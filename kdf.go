@@ -0,0 +1,447 @@
+package gocrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Error returned when data was encrypted with another key derivation
+// function than the one configured
+var ErrKDFMismatch = errors.New("key derivation function mismatch")
+
+// Key derivation function turning a passphrase into a key
+type KDFFunction byte
+
+const (
+	// Use the default function (scrypt) to encrypt and whatever the data
+	// was encrypted with to decrypt
+	DefaultKDF KDFFunction = 0
+	// scrypt, the default
+	Scrypt KDFFunction = 1
+	// Argon2id
+	Argon2id KDFFunction = 2
+)
+
+// Default Argon2id cost parameters (RFC 9106 second recommended option)
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+)
+
+// Maximum Argon2id passes, like scrypt's p it multiplies the time a
+// derivation takes with the memory allowed
+const maxArgon2Time = 16
+
+// Function to get the name of a key derivation function
+//
+// Returns:
+//
+//   string - Name of the function
+func (f KDFFunction) String() string {
+	switch f {
+	case DefaultKDF:
+		return "default"
+	case Scrypt:
+		return "scrypt"
+	case Argon2id:
+		return "Argon2id"
+	}
+	return fmt.Sprintf("unknown KDF %d", byte(f))
+}
+
+// Cost parameters of the key derivation
+//
+// Zero fields take the defaults, so the zero value derives keys exactly as
+// before the parameters could be set. Higher costs make every derivation
+// slower and hungrier for memory, both for users and for attackers guessing
+// passphrases. Keys of 16 or 24 bytes select AES-128 or AES-192 in GCM mode,
+// ChaCha20-Poly1305 needs 32. N, R and P only apply to scrypt, Time, Memory
+// and Threads only to Argon2id. Whatever the bounds below allow, a
+// derivation can't use more memory than Options.MaxKDFMemory (1 GiB by
+// default): scrypt needs 128*N*R bytes, Argon2id Memory KiB.
+type KDFParams struct {
+	Func    KDFFunction // Key derivation function (scrypt by default)
+	N       int         // CPU/memory cost, a power of 2 up to 2^24 (32768 by default)
+	R       int         // Block size, up to 32 (8 by default)
+	P       int         // Parallelization, up to 16 (1 by default)
+	Time    int         // Passes over the memory, up to 16 (3 by default)
+	Memory  int         // Memory in KiB, up to 4 GiB (64 MiB by default)
+	Threads int         // Lanes, up to 255 (4 by default)
+	KeyLen  int         // Length of the derived key: 16, 24 or 32 bytes (32 by default)
+	SaltLen int         // Length of the salts generated: 8 to 255 bytes (16 by default)
+}
+
+// Function to fill in the defaults of unset parameters
+func (p KDFParams) resolve() KDFParams {
+	if p.Func == DefaultKDF {
+		p.Func = Scrypt
+	}
+	if p.Func == Argon2id {
+		if p.Time == 0 {
+			p.Time = argon2Time
+		}
+		if p.Memory == 0 {
+			p.Memory = argon2Memory
+		}
+		if p.Threads == 0 {
+			p.Threads = argon2Threads
+		}
+	} else {
+		if p.N == 0 {
+			p.N = scryptN
+		}
+		if p.R == 0 {
+			p.R = scryptR
+		}
+		if p.P == 0 {
+			p.P = scryptP
+		}
+	}
+	if p.KeyLen == 0 {
+		p.KeyLen = 32
+	}
+	if p.SaltLen == 0 {
+		p.SaltLen = saltSize
+	}
+	return p
+}
+
+// Function to check the parameters are usable
+//
+// These are the bounds of what the parameters can be set to or read from a
+// header. The memory a derivation may actually use is capped separately by
+// Options.MaxKDFMemory, see checkCost.
+func (p KDFParams) validate() error {
+	p = p.resolve()
+	switch p.Func {
+	case Argon2id:
+		if p.Time < 1 || p.Time > maxArgon2Time || p.Threads < 1 || p.Threads > 255 {
+			return errors.New("invalid Argon2id parameters: need 1 <= time <= 16 and 1 <= threads <= 255")
+		}
+		if p.Memory < 8*p.Threads || p.Memory > 1<<22 {
+			return errors.New("invalid Argon2id parameters: memory must be between 8 KiB per thread and 4 GiB")
+		}
+		if p.KeyLen != 16 && p.KeyLen != 24 && p.KeyLen != 32 {
+			return errors.New("invalid Argon2id parameters: key length must be 16, 24 or 32")
+		}
+		if p.SaltLen < 8 || p.SaltLen > 255 {
+			return errors.New("invalid Argon2id parameters: salt length must be between 8 and 255")
+		}
+		return nil
+	case Scrypt:
+	default:
+		return fmt.Errorf("unsupported KDF %d", byte(p.Func))
+	}
+	if p.N < 2 || p.N > 1<<24 || p.N&(p.N-1) != 0 {
+		return errors.New("invalid scrypt parameters: N must be a power of 2 up to 2^24")
+	}
+	if p.R < 1 || p.R > 32 || p.P < 1 || p.P > 16 {
+		return errors.New("invalid scrypt parameters: need 1 <= r <= 32 and 1 <= p <= 16")
+	}
+	if p.KeyLen != 16 && p.KeyLen != 24 && p.KeyLen != 32 {
+		return errors.New("invalid scrypt parameters: key length must be 16, 24 or 32")
+	}
+	if p.SaltLen < 8 || p.SaltLen > 255 {
+		return errors.New("invalid scrypt parameters: salt length must be between 8 and 255")
+	}
+	return nil
+}
+
+// Error returned when a key derivation would use more memory than
+// Options.MaxKDFMemory allows
+var ErrKDFTooCostly = errors.New("key derivation parameters over the limit")
+
+// Memory a key derivation may use when Options.MaxKDFMemory isn't set
+const defaultMaxKDFMemory = 1 << 30
+
+// Function to get the memory the derivation needs in bytes
+func (p KDFParams) memory() int64 {
+	p = p.resolve()
+	if p.Func == Argon2id {
+		return int64(p.Memory) * 1024
+	}
+	return 128 * int64(p.N) * int64(p.R)
+}
+
+// Function to check the derivation stays within the limits set in Options,
+// before it runs
+//
+//   o Options - Options in use
+func (p KDFParams) checkCost(o Options) error {
+
+	limit := o.MaxKDFMemory
+	if limit == 0 {
+		limit = defaultMaxKDFMemory
+	}
+	if m := p.memory(); m > limit {
+		return fmt.Errorf("%w: needs %d MiB of memory, limit is %d MiB", ErrKDFTooCostly, m>>20, limit>>20)
+	}
+
+	return nil
+
+}
+
+// Function to check if the parameters derive keys like the defaults
+func (p KDFParams) isDefault() bool {
+	p = p.resolve()
+	return p.Func == Scrypt && p.N == scryptN && p.R == scryptR && p.P == scryptP && p.KeyLen == 32
+}
+
+// Function to check data derived with some parameters can be decrypted with
+// the ones configured
+//
+//   used       KDFParams - Parameters the data was encrypted with
+//   configured KDFParams - Parameters set in Options
+func checkKDF(used, configured KDFParams) error {
+
+	if configured.Func != DefaultKDF && configured.Func != used.resolve().Func {
+		return fmt.Errorf("%w: data uses %s, configured for %s", ErrKDFMismatch, used.resolve().Func, configured.Func)
+	}
+
+	return nil
+
+}
+
+// Error returned when a key derivation takes longer than
+// Options.MaxKDFDuration
+var ErrKDFTimeout = errors.New("key derivation timed out")
+
+// Semaphores bounding concurrent derivations, one per configured limit
+var kdfSemaphores = struct {
+	sync.Mutex
+	m map[int]chan struct{}
+}{
+	m: make(map[int]chan struct{}),
+}
+
+// Semaphore bounding concurrent derivations across the whole package, nil
+// when there is no limit
+var globalKDF = struct {
+	sync.Mutex
+	sem chan struct{}
+}{}
+
+// Function to set how many scrypt derivations can run at once across all
+// goroutines
+//
+// Derivations over the limit are queued until a slot frees up. Each
+// derivation needs tens of MiB (see EstimateMemory), so this keeps shared
+// hosts from running out of memory. It applies on top of
+// Options.MaxConcurrentKDF. Derivations already running keep their slot when
+// the limit is changed.
+//
+// Variables to pass in:
+//
+//   n int - Maximum number of derivations at once (0 means no limit)
+func SetMaxConcurrentDerivations(n int) {
+
+	globalKDF.Lock()
+	defer globalKDF.Unlock()
+
+	if n <= 0 {
+		globalKDF.sem = nil
+		return
+	}
+	globalKDF.sem = make(chan struct{}, n)
+
+}
+
+// Function to wait for a derivation slot when a limit is set, either with
+// SetMaxConcurrentDerivations or Options.MaxConcurrentKDF
+//
+// Returns a function to release the slots once the derivation is done, or
+// the context's error if it's done before a slot frees up.
+//
+//   ctx context.Context - Context to stop waiting on
+//   o   Options         - Options in use
+func acquireKDF(ctx context.Context, o Options) (func(), error) {
+
+	var sems []chan struct{}
+
+	globalKDF.Lock()
+	if globalKDF.sem != nil {
+		sems = append(sems, globalKDF.sem)
+	}
+	globalKDF.Unlock()
+
+	if o.MaxConcurrentKDF > 0 {
+		kdfSemaphores.Lock()
+		sem, ok := kdfSemaphores.m[o.MaxConcurrentKDF]
+		if !ok {
+			sem = make(chan struct{}, o.MaxConcurrentKDF)
+			kdfSemaphores.m[o.MaxConcurrentKDF] = sem
+		}
+		kdfSemaphores.Unlock()
+		sems = append(sems, sem)
+	}
+
+	release := func(held []chan struct{}) {
+		for _, sem := range held {
+			<-sem
+		}
+	}
+
+	for i, sem := range sems {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			release(sems[:i])
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() { release(sems) }, nil
+
+}
+
+// Function to estimate the peak memory a single operation uses
+//
+// The estimate is dominated by the key derivation (128 * N * r bytes for
+// scrypt, the memory parameter for Argon2id) plus the buffers used to
+// stream a frame. Use it with Options.MaxConcurrentKDF to size how
+// many operations a server can run at once.
+//
+// Variables to pass in:
+//
+//   opts Options - Options the operation will use
+//
+// Returns:
+//
+//   int64 - Estimated peak memory in bytes
+func EstimateMemory(opts Options) int64 {
+
+	p := opts.KDF.resolve()
+	kdf := int64(128*p.R*p.N + 128*p.R*p.P + 256*p.R)
+	if p.Func == Argon2id {
+		kdf = int64(p.Memory) * 1024
+	}
+	buffers := int64(2*opts.chunk() + 2*(frameHeaderSize+sealOverhead))
+
+	return kdf + buffers
+
+}
+
+// Measured time of one key derivation, see EstimateUnlockTime
+var unlockTime struct {
+	sync.Once
+	d time.Duration
+}
+
+// Measured time of one Argon2id pass over 1 MiB, see EstimateUnlockTime
+var argon2UnlockTime struct {
+	sync.Once
+	d time.Duration
+}
+
+// Function to estimate how long deriving a key takes on this machine
+//
+// The first call measures a derivation at a sixteenth of the default cost
+// and scales it up, later calls reuse the measure and scale it to the cost
+// set in Options.KDF. This is the minimum
+// time Encrypt or Decrypt takes, and what it costs an attacker per guess.
+//
+// Variables to pass in:
+//
+//   opts Options - Options the derivation will use
+//
+// Returns:
+//
+//   time.Duration - Estimated time of one derivation
+func EstimateUnlockTime(opts Options) time.Duration {
+
+	p := opts.KDF.resolve()
+	if p.Func == Argon2id {
+		argon2UnlockTime.Do(func() {
+			start := time.Now()
+			argon2.IDKey([]byte("gocrypt"), make([]byte, saltSize), 1, 16*1024, 1, 32)
+			argon2UnlockTime.d = time.Since(start) / 16
+		})
+		// Argon2id takes time proportional to time * memory, spread over
+		// the threads
+		scale := float64(p.Time) * float64(p.Memory) / 1024 / float64(p.Threads)
+		return time.Duration(float64(argon2UnlockTime.d) * scale)
+	}
+
+	unlockTime.Do(func() {
+		start := time.Now()
+		scrypt.Key([]byte("gocrypt"), make([]byte, saltSize), scryptN/16, scryptR, scryptP, 32)
+		unlockTime.d = 16 * time.Since(start)
+	})
+
+	// scrypt takes time proportional to N * r * p
+	scale := float64(p.N*p.R*p.P) / float64(scryptN*scryptR*scryptP)
+
+	return time.Duration(float64(unlockTime.d) * scale)
+
+}
+
+// Function to derive a key under a watchdog, giving up with ErrKDFTimeout
+// once Options.MaxKDFDuration has passed or with the context's error once
+// it's done
+//
+// scrypt can't be interrupted, so a derivation that is given up on keeps
+// running in the background until it finishes: its key is then scrubbed and
+// its slots released, so abandoned derivations still count towards the
+// concurrency limits and can't pile up past them.
+//
+//   ctx     context.Context - Context to stop waiting on
+//   input   []byte          - Passphrase to derive the key from
+//   salt    []byte          - Salt
+//   o       Options         - Options in use
+//   release func()          - Function releasing the derivation slots
+func deriveKeyWatched(ctx context.Context, input, salt []byte, o Options, release func()) ([]byte, error) {
+
+	type result struct {
+		dk  []byte
+		err error
+	}
+
+	var mu sync.Mutex
+	abandoned := false
+	done := make(chan result, 1)
+
+	go func() {
+		defer release()
+		dk, err := deriveKey(ctx, input, salt, o)
+		mu.Lock()
+		defer mu.Unlock()
+		if abandoned {
+			Scrub(dk)
+			return
+		}
+		done <- result{dk, err}
+	}()
+
+	timer := time.NewTimer(o.MaxKDFDuration)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case r := <-done:
+		return r.dk, r.err
+	case <-timer.C:
+		err = ErrKDFTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	select {
+	case r := <-done:
+		// Finished while giving up
+		return r.dk, r.err
+	default:
+		abandoned = true
+	}
+
+	return nil, err
+
+}
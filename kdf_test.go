@@ -0,0 +1,309 @@
+package gocrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEstimateMemory(t *testing.T) {
+
+	scrypt := EstimateMemory(Options{})
+	if want := int64(128 * scryptR * scryptN); scrypt < want || scrypt > want+1<<20 {
+		t.Fatalf("default estimate %d, want just over %d", scrypt, want)
+	}
+	if EstimateMemory(Options{KDF: KDFParams{N: 2 * scryptN}}) <= scrypt {
+		t.Fatal("doubling N didn't raise the estimate")
+	}
+
+	argon := EstimateMemory(Options{KDF: KDFParams{Func: Argon2id, Memory: 1 << 20}})
+	if want := int64(1 << 30); argon < want || argon > want+1<<20 {
+		t.Fatalf("Argon2id estimate %d, want just over %d", argon, want)
+	}
+
+}
+
+// Function to replace scryptKey with one recording how many derivations run
+// at once, for the length of a test
+//
+//   t *testing.T - Test
+func countDerivations(t *testing.T) *int {
+
+	var mu sync.Mutex
+	running, peak := 0, new(int)
+	orig := scryptKey
+	scryptKey = func(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+		mu.Lock()
+		running++
+		if running > *peak {
+			*peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return orig(password, salt, N, r, p, keyLen)
+	}
+	t.Cleanup(func() { scryptKey = orig })
+
+	return peak
+
+}
+
+// Function to run derivations from several goroutines at once
+//
+//   n    int      - Number of derivations
+//   opts []Option - Options
+func deriveConcurrently(n int, opts ...Option) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Encrypt([]byte("data"), "pass", append([]Option{testKDF}, opts...)...)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMaxConcurrentKDF(t *testing.T) {
+
+	peak := countDerivations(t)
+	deriveConcurrently(8, WithMaxConcurrentKDF(2))
+	if *peak < 1 || *peak > 2 {
+		t.Fatalf("%d derivations ran at once, want at most 2", *peak)
+	}
+
+}
+
+func TestSetMaxConcurrentDerivations(t *testing.T) {
+
+	peak := countDerivations(t)
+	SetMaxConcurrentDerivations(3)
+	defer SetMaxConcurrentDerivations(0)
+	deriveConcurrently(12)
+	if *peak < 1 || *peak > 3 {
+		t.Fatalf("%d derivations ran at once, want at most 3", *peak)
+	}
+
+}
+
+func TestDerivationQueueCancel(t *testing.T) {
+
+	SetMaxConcurrentDerivations(1)
+	defer SetMaxConcurrentDerivations(0)
+
+	// Hold the only slot so the next derivation is queued
+	release, err := acquireKDF(context.Background(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := EncryptContext(ctx, []byte("data"), "pass", testKDF); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// The queued derivation gave up without taking the slot
+	release()
+	if _, _, err := Encrypt([]byte("data"), "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+// Function to replace scryptKey with one failing a number of times before
+// succeeding, for the length of a test
+//
+//   t        *testing.T - Test
+//   failures int        - Number of calls to fail
+func failDerivations(t *testing.T, failures int) *int {
+
+	calls := new(int)
+	orig := scryptKey
+	scryptKey = func(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+		if *calls++; *calls <= failures {
+			return nil, fmt.Errorf("allocation failed (call %d)", *calls)
+		}
+		return orig(password, salt, N, r, p, keyLen)
+	}
+	t.Cleanup(func() { scryptKey = orig })
+
+	return calls
+
+}
+
+func TestKDFRetries(t *testing.T) {
+
+	calls := failDerivations(t, 2)
+	ciphertext, salt, err := Encrypt([]byte("data"), "pass", testKDF, WithKDFRetries(2))
+	if err != nil || *calls != 3 {
+		t.Fatalf("got %v after %d calls, want success after 3", err, *calls)
+	}
+	if pt, err := Decrypt(ciphertext, salt, "pass", testKDF); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// The last error is returned once the retries are used up
+	calls = failDerivations(t, 3)
+	_, _, err = Encrypt([]byte("data"), "pass", testKDF, WithKDFRetries(2))
+	if err == nil || !strings.Contains(err.Error(), "call 3") || *calls != 3 {
+		t.Fatalf("got %v after %d calls, want the error of call 3", err, *calls)
+	}
+
+	// Without retries the first failure is final
+	calls = failDerivations(t, 1)
+	if _, _, err := Encrypt([]byte("data"), "pass", testKDF); err == nil || *calls != 1 {
+		t.Fatalf("got %v after %d calls, want a failure after 1", err, *calls)
+	}
+
+	// Waiting between retries stops with the context
+	failDerivations(t, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+	if _, _, err := EncryptContext(ctx, []byte("data"), "pass", testKDF, WithKDFRetries(10)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+}
+
+func TestMaxKDFDuration(t *testing.T) {
+
+	// Stands in for a derivation with a huge N, finishing once unblocked
+	unblock := make(chan struct{})
+	finished := make(chan struct{})
+	orig := scryptKey
+	scryptKey = func(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+		<-unblock
+		defer close(finished)
+		return orig(password, salt, N, r, p, keyLen)
+	}
+	t.Cleanup(func() { scryptKey = orig })
+
+	SetMaxConcurrentDerivations(1)
+	defer SetMaxConcurrentDerivations(0)
+
+	start := time.Now()
+	_, _, err := Encrypt([]byte("data"), "pass", testKDF, WithMaxKDFDuration(10*time.Millisecond))
+	if !errors.Is(err, ErrKDFTimeout) {
+		t.Fatalf("got %v, want ErrKDFTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("gave up after %v", elapsed)
+	}
+
+	// The abandoned derivation keeps its slot until it finishes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := acquireKDF(ctx, Options{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the slot still held", err)
+	}
+	close(unblock)
+	<-finished
+	scryptKey = orig
+	release, err := acquireKDF(context.Background(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	// A derivation within the limit isn't affected
+	if _, _, err := Encrypt([]byte("data"), "pass", testKDF, WithMaxKDFDuration(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestMaxKDFMemory(t *testing.T) {
+
+	// Stands in for scrypt so nothing is allocated when the limit is raised
+	called := false
+	orig := scryptKey
+	scryptKey = func(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+		called = true
+		return nil, errors.New("not derived")
+	}
+	t.Cleanup(func() { scryptKey = orig })
+
+	// The largest parameters a header can hold need 64 GiB
+	largest := KDFParams{Func: Scrypt, N: 1 << 24, R: 32, P: 16}
+	if err := largest.validate(); err != nil {
+		t.Fatal(err)
+	}
+	salt := make([]byte, saltSize)
+	oneShot := append(marshalHeader(Info{Version: FormatVersion, OneShot: true, KDF: largest, Salt: salt}), make([]byte, 64)...)
+	stream := append(marshalHeader(Info{Version: FormatVersion, KDF: largest, Salt: salt}), make([]byte, 64)...)
+
+	if _, err := Decrypt(oneShot, nil, "pass"); !errors.Is(err, ErrKDFTooCostly) {
+		t.Fatalf("got %v, want ErrKDFTooCostly", err)
+	}
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(stream), nil, "pass"); !errors.Is(err, ErrKDFTooCostly) {
+		t.Fatalf("got %v, want ErrKDFTooCostly", err)
+	}
+	if called {
+		t.Fatal("derivation started over the limit")
+	}
+
+	// Nothing over the limit is encrypted either
+	if _, _, err := Encrypt([]byte("data"), "pass", WithKDFParams(KDFParams{N: 1 << 24, R: 8})); !errors.Is(err, ErrKDFTooCostly) {
+		t.Fatalf("got %v, want ErrKDFTooCostly", err)
+	}
+
+	// The limit can be raised
+	if _, err := Decrypt(oneShot, nil, "pass", WithMaxKDFMemory(64<<30)); errors.Is(err, ErrKDFTooCostly) || !called {
+		t.Fatalf("got %v, want the derivation to run", err)
+	}
+	if _, err := NewOptions(WithMaxKDFMemory(-1)); err == nil {
+		t.Fatal("got nil, want an error for a negative limit")
+	}
+
+}
+
+func TestMaxKDFMemoryArgon2(t *testing.T) {
+
+	// The largest parameters a header can hold need 4 GiB and 16 passes
+	largest := KDFParams{Func: Argon2id, Time: maxArgon2Time, Memory: 1 << 22, Threads: 255}
+	if err := largest.validate(); err != nil {
+		t.Fatal(err)
+	}
+	salt := make([]byte, saltSize)
+	oneShot := append(marshalHeader(Info{Version: FormatVersion, OneShot: true, KDF: largest, Salt: salt}), make([]byte, 64)...)
+	stream := append(marshalHeader(Info{Version: FormatVersion, KDF: largest, Salt: salt}), make([]byte, 64)...)
+
+	start := time.Now()
+	if _, err := Decrypt(oneShot, nil, "pass"); !errors.Is(err, ErrKDFTooCostly) {
+		t.Fatalf("got %v, want ErrKDFTooCostly", err)
+	}
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(stream), nil, "pass"); !errors.Is(err, ErrKDFTooCostly) {
+		t.Fatalf("got %v, want ErrKDFTooCostly", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("took %v to refuse, want no derivation", d)
+	}
+
+	// More passes can't be set or read from a header
+	if _, err := NewOptions(WithKDFParams(KDFParams{Func: Argon2id, Time: maxArgon2Time + 1})); err == nil {
+		t.Fatal("got nil, want an error for too many passes")
+	}
+
+	// Within the limits it derives as usual
+	argon := WithKDFParams(KDFParams{Func: Argon2id, Time: 1, Memory: 8 * 1024, Threads: 1})
+	ciphertext, salt, err := Encrypt([]byte("data"), "pass", argon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := Decrypt(ciphertext, salt, "pass", WithMaxKDFMemory(8<<20)); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	if _, err := Decrypt(ciphertext, salt, "pass", WithMaxKDFMemory(8<<20-1)); !errors.Is(err, ErrKDFTooCostly) {
+		t.Fatalf("got %v, want ErrKDFTooCostly", err)
+	}
+
+}
@@ -0,0 +1,169 @@
+package gocrypt
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Function to derive the key Encrypt and Decrypt would use for a salt and
+// passphrase
+//
+// Use it to decrypt many blobs sharing a salt without running scrypt for
+// each one: build the AEAD once (AES-256-GCM unless Options.Cipher says
+// otherwise) and pass it to DecryptWithAEAD. The key is as sensitive as the
+// passphrase, Scrub it once done.
+//
+// Variables to pass in:
+//
+//   salt []byte - Salt to use to create hash
+//   pass string - Passphrase
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Key
+//   error  - Error
+func ExportKey(salt []byte, pass string, opts ...Option) ([]byte, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("export key: options: %w", err)
+	}
+
+	if len(salt) == 0 {
+		return nil, errors.New("no salt given")
+	}
+
+	_, key, err := createHash(salt, pass, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+
+}
+
+// Function to encrypt data with an AEAD the caller built, skipping key
+// derivation
+//
+// A random nonce is generated for every call and stored in front of the
+// ciphertext, the same wire format as Encrypt, so the caller has no nonce
+// to manage. With a 96-bit random nonce, don't seal more than 2^32
+// messages with the same key.
+//
+// Variables to pass in:
+//
+//   data []byte      - Data to be encrypted
+//   aead cipher.AEAD - AEAD to seal with
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   error  - Error
+func EncryptWithAEAD(data []byte, aead cipher.AEAD) ([]byte, error) {
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("encrypt with AEAD: nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, data, nil), nil
+
+}
+
+// Function to decrypt data with an AEAD the caller built, skipping key
+// derivation
+//
+// The data is expected in the format EncryptWithAEAD outputs, the nonce
+// stored in front of the ciphertext. So does Encrypt unless a cipher or key
+// derivation is set in Options, which adds a header.
+//
+// Variables to pass in:
+//
+//   data []byte      - Data to be decrypted
+//   aead cipher.AEAD - AEAD to open with
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptWithAEAD(data []byte, aead cipher.AEAD) ([]byte, error) {
+
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize+aead.Overhead() {
+		return nil, fmt.Errorf("%w data: ciphertext too short", ErrCorrupted)
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with AEAD: open: %w", ErrAuthFailed)
+	}
+
+	return plaintext, nil
+
+}
+
+// Size in bytes of the keys EncryptWithKey and DecryptWithKey take
+const KeySize = 32
+
+// Function to encrypt data with a 32-byte key, skipping key derivation
+//
+// For keys that are already high entropy (ie. from a KMS or HKDF), so no
+// scrypt cost is paid. The data is sealed with AES-256-GCM in the format of
+// EncryptWithAEAD, the nonce in front of the ciphertext.
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   key  []byte - Key of exactly 32 bytes
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   error  - Error
+func EncryptWithKey(data, key []byte) ([]byte, error) {
+
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encrypt with key: key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	aead, release, err := newAEAD(AES256GCM, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt with key: AEAD: %w", err)
+	}
+	defer release()
+
+	return EncryptWithAEAD(data, aead)
+
+}
+
+// Function to decrypt data encrypted by EncryptWithKey
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be decrypted
+//   key  []byte - Key of exactly 32 bytes
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptWithKey(data, key []byte) ([]byte, error) {
+
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("decrypt with key: key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	aead, release, err := newAEAD(AES256GCM, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with key: AEAD: %w", err)
+	}
+	defer release()
+
+	return DecryptWithAEAD(data, aead)
+
+}
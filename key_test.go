@@ -0,0 +1,109 @@
+package gocrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestWithAEAD(t *testing.T) {
+
+	// Encrypt only writes the format of EncryptWithAEAD without a header,
+	// ie. with the default key derivation
+	ciphertext, salt, err := Encrypt([]byte("data"), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ExportKey(salt, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt, err := DecryptWithAEAD(ciphertext, aead)
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	sealed, err := EncryptWithAEAD([]byte("more data"), aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := Decrypt(sealed, salt, "pass"); err != nil || string(pt) != "more data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	again, _ := EncryptWithAEAD([]byte("more data"), aead)
+	if string(again[:aead.NonceSize()]) == string(sealed[:aead.NonceSize()]) {
+		t.Fatal("nonce reused")
+	}
+
+	other, err := ExportKey(salt, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ = aes.NewCipher(other)
+	wrong, _ := cipher.NewGCM(block)
+	if _, err := DecryptWithAEAD(ciphertext, wrong); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+	if _, err := DecryptWithAEAD(ciphertext[:aead.NonceSize()], aead); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("got %v, want ErrCorrupted", err)
+	}
+	if _, err := ExportKey(nil, "pass"); err == nil {
+		t.Fatal("exported a key without a salt")
+	}
+
+}
+
+func TestWithKey(t *testing.T) {
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := EncryptWithKey([]byte("data"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := DecryptWithKey(ciphertext, key); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	other := append([]byte(nil), key...)
+	other[0] ^= 1
+	if _, err := DecryptWithKey(ciphertext, other); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed for the wrong key", err)
+	}
+
+	for _, n := range []int{0, 16, 24, KeySize - 1, KeySize + 1, 64} {
+		if _, err := EncryptWithKey([]byte("data"), make([]byte, n)); err == nil {
+			t.Fatalf("encrypted with a %d-byte key", n)
+		}
+		if _, err := DecryptWithKey(ciphertext, make([]byte, n)); err == nil {
+			t.Fatalf("decrypted with a %d-byte key", n)
+		}
+	}
+
+	for i := range ciphertext {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[i] ^= 1
+		if _, err := DecryptWithKey(tampered, key); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("byte %d: got %v, want ErrAuthFailed", i, err)
+		}
+	}
+	for _, n := range []int{0, 12, 12 + 16 - 1} {
+		if _, err := DecryptWithKey(ciphertext[:n], key); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("%d bytes: got %v, want ErrCorrupted", n, err)
+		}
+	}
+
+}
@@ -0,0 +1,104 @@
+package gocrypt
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// Maximum number of keys held by a keyCache
+const keyCacheSize = 256
+
+// Cache of the keys derived from one passphrase, see Encrypter
+type keyCache struct {
+	mu    sync.Mutex
+	keys  map[string][]byte
+	order []string // Ids in the order they were added, oldest first
+
+	aeads     map[[sha256.Size]byte]*aeadEntry // AEAD pools of the keys, see newAEAD
+	aeadOrder [][sha256.Size]byte              // Pools in the order they were added
+}
+
+// Function to create a keyCache
+func newKeyCache() *keyCache {
+	return &keyCache{keys: make(map[string][]byte), aeads: make(map[[sha256.Size]byte]*aeadEntry)}
+}
+
+// Function to get the id of the key derived from a salt
+//
+// Everything changing the key besides the passphrase is part of the id.
+// The KDF parameters are resolved so the defaults set when encrypting and
+// the ones read back from a header give the same id.
+//
+//   salt []byte  - Salt the key is derived from
+//   o    Options - Options in use
+func keyCacheID(salt []byte, o Options) string {
+
+	// The salt length only matters when generating a salt, the salt itself
+	// is part of the id
+	kdf := o.KDF.resolve()
+	kdf.SaltLen = 0
+
+	return fmt.Sprintf("%x|%+v|%q|%t|%x", salt, kdf, o.Purpose, o.LegacyKeyDerivation, o.pepper)
+
+}
+
+// Function to get a copy of a cached key
+//
+//   id string - Id of the key
+func (c *keyCache) get(id string) []byte {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[id]
+	if !ok {
+		return nil
+	}
+
+	return append([]byte(nil), key...)
+
+}
+
+// Function to cache a copy of a key
+//
+// The oldest key is evicted and wiped once the cache is full.
+//
+//   id  string - Id of the key
+//   key []byte - Key
+func (c *keyCache) put(id string, key []byte) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.keys[id]; ok {
+		return
+	}
+	if len(c.order) >= keyCacheSize {
+		Scrub(c.keys[c.order[0]])
+		delete(c.keys, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.keys[id] = append([]byte(nil), key...)
+	c.order = append(c.order, id)
+
+}
+
+// Function to wipe and drop every cached key, along with the AEAD pools
+func (c *keyCache) clear() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range c.keys {
+		Scrub(key)
+	}
+	c.keys = make(map[string][]byte)
+	c.order = nil
+	for _, e := range c.aeads {
+		Scrub(e.key)
+	}
+	c.aeads = make(map[[sha256.Size]byte]*aeadEntry)
+	c.aeadOrder = nil
+
+}
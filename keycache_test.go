@@ -0,0 +1,128 @@
+package gocrypt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestEncrypterKeyCache(t *testing.T) {
+
+	e, err := New("pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ct, salt, err := e.Encrypt([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(e.opts.keys.keys); n != 1 {
+		t.Fatalf("%d keys cached after encrypting, want 1", n)
+	}
+
+	// The parameters read back from the header resolve to the same id
+	pt, err := e.Decrypt(ct, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, []byte("data")) {
+		t.Fatalf("decrypted %q", pt)
+	}
+	if n := len(e.opts.keys.keys); n != 1 {
+		t.Fatalf("%d keys cached after decrypting, want 1: decrypting missed the cache", n)
+	}
+
+	e.ClearKeys()
+	if n := len(e.opts.keys.keys); n != 0 {
+		t.Fatalf("%d keys left after ClearKeys", n)
+	}
+
+}
+
+func TestKeyCacheIDDefaults(t *testing.T) {
+
+	salt := []byte("0123456789abcdef")
+	set := Options{KDF: KDFParams{Func: Scrypt, N: scryptN, R: scryptR, P: scryptP, KeyLen: 32, SaltLen: 32}}
+	if keyCacheID(salt, Options{}) != keyCacheID(salt, set) {
+		t.Fatal("default and resolved KDF parameters give different ids")
+	}
+	if keyCacheID(salt, Options{}) == keyCacheID(salt, Options{Purpose: "other"}) {
+		t.Fatal("purpose isn't part of the id")
+	}
+
+}
+
+func TestEncrypterConcurrent(t *testing.T) {
+
+	e, err := New("pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("0123456789abcdef")
+
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func(i int) {
+			data := []byte(fmt.Sprintf("record %d", i))
+			ct, err := e.EncryptWithSalt(data, salt)
+			if err == nil {
+				var pt []byte
+				if pt, err = e.Decrypt(ct, salt); err == nil && !bytes.Equal(pt, data) {
+					err = fmt.Errorf("decrypted %q, want %q", pt, data)
+				}
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+}
+
+// Encrypts and decrypts 1,000 records with the same salt, with and without
+// the keys cached by an Encrypter
+func BenchmarkEncrypterRecords(b *testing.B) {
+
+	salt := []byte("0123456789abcdef")
+	records := make([][]byte, 1000)
+	for i := range records {
+		records[i] = []byte(fmt.Sprintf(`{"id":%d,"name":"record %d"}`, i, i))
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range records {
+				ct, err := EncryptWithSalt(r, salt, "pass", testKDF)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := Decrypt(ct, salt, "pass", testKDF); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		e, err := New("pass", testKDF)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < b.N; i++ {
+			for _, r := range records {
+				ct, err := e.EncryptWithSalt(r, salt)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := e.Decrypt(ct, salt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+}
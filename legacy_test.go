@@ -0,0 +1,69 @@
+package gocrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Function to encrypt data the way versions before the passphrase fix did
+//
+//   t    *testing.T - Test
+//   data []byte     - Data to encrypt
+func legacyEncrypt(t *testing.T, data []byte) ([]byte, []byte) {
+	t.Helper()
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := scrypt.Key([]byte("some password"), salt, 32768, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return gcm.Seal(nonce, nonce, data, nil), salt
+}
+
+func TestLegacyKeyDerivation(t *testing.T) {
+
+	ciphertext, salt := legacyEncrypt(t, []byte("old data"))
+
+	if _, err := Decrypt(ciphertext, salt, "pass"); err == nil {
+		t.Fatal("decrypted legacy data without LegacyKeyDerivation")
+	}
+	pt, err := Decrypt(ciphertext, salt, "pass", WithLegacyKeyDerivation(true))
+	if err != nil || string(pt) != "old data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// Once upgraded the passphrase matters
+	dir := t.TempDir() + "/"
+	writeTestFile(t, filepath.Join(dir, "old.3dfx"), string(ciphertext))
+	writeTestFile(t, filepath.Join(dir, "old.salt"), string(salt))
+	if err := UpgradeFile("old", dir, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	pt, err = DecryptFromFile(dir+"old.3dfx", salt, "pass")
+	if err != nil || string(pt) != "old data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	if _, err := DecryptFromFile(dir+"old.3dfx", salt, "wrong"); err == nil {
+		t.Fatal("decrypted upgraded data with the wrong passphrase")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("got %d files, want the .3dfx and .salt files only", len(entries))
+	}
+
+}
@@ -0,0 +1,76 @@
+package gocrypt
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Function to encrypt data into a Go byte slice literal
+//
+// The snippet declares varName as a []byte holding the output of
+// EncryptSealed, salt included, so it can be written to a generated source
+// file (ie. from go:generate) and decrypted at runtime with
+// DecryptGoLiteral.
+//
+// Variables to pass in:
+//
+//   data []byte    - Data to be encrypted
+//   pass string    - Passphrase to use for encryption
+//   varName string - Name of the variable to declare
+//   opts           - Options (optional)
+//
+// Returns:
+//
+//   string - Go source declaring the variable
+//   error  - Error
+func EncryptToGoLiteral(data []byte, pass, varName string, opts ...Option) (string, error) {
+
+	if !token.IsIdentifier(varName) {
+		return "", fmt.Errorf("%q is not a valid Go identifier", varName)
+	}
+
+	sealed, err := EncryptSealed(data, pass, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "var %s = []byte{\n", varName)
+	for i := 0; i < len(sealed); i += 12 {
+		end := i + 12
+		if end > len(sealed) {
+			end = len(sealed)
+		}
+		b.WriteString("\t")
+		for j, c := range sealed[i:end] {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "0x%02x,", c)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+
+	return b.String(), nil
+
+}
+
+// Function to decrypt the bytes embedded by EncryptToGoLiteral
+//
+// Variables to pass in:
+//
+//   data []byte - Bytes of the generated variable
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptGoLiteral(data []byte, pass string, opts ...Option) ([]byte, error) {
+
+	return DecryptSealed(data, pass, opts...)
+
+}
@@ -0,0 +1,47 @@
+package gocrypt
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+func TestGoLiteral(t *testing.T) {
+
+	src, err := EncryptToGoLiteral([]byte("data"), "pass", "secret", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "secret.go", "package x\n\n"+src, 0)
+	if err != nil {
+		t.Fatalf("got %v, want valid Go:\n%s", err, src)
+	}
+
+	// Collect the bytes of the declared variable as the compiler would
+	var embedded []byte
+	ast.Inspect(f, func(n ast.Node) bool {
+		if v, ok := n.(*ast.ValueSpec); ok && v.Names[0].Name != "secret" {
+			t.Fatalf("got variable %s, want secret", v.Names[0].Name)
+		}
+		if lit, ok := n.(*ast.BasicLit); ok {
+			b, err := strconv.ParseUint(lit.Value, 0, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			embedded = append(embedded, byte(b))
+		}
+		return true
+	})
+	if pt, err := DecryptGoLiteral(embedded, "pass", testKDF); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	for _, name := range []string{"", "1secret", "my-secret"} {
+		if _, err := EncryptToGoLiteral([]byte("data"), "pass", name, testKDF); err == nil {
+			t.Fatalf("%q: got nil, want an error", name)
+		}
+	}
+
+}
@@ -0,0 +1,94 @@
+package gocrypt
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+)
+
+// Functions identifying the machine, replaceable to simulate another one
+var (
+	hostname  = os.Hostname
+	machineID = readMachineID
+)
+
+// Function to derive a salt from the identity of the machine
+//
+// The salt is the start of a SHA-256 of the hostname and the machine id
+// (/etc/machine-id on Linux, the hostname alone elsewhere).
+func localSalt() ([]byte, error) {
+
+	host, err := hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := machineID()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte("gocrypt local\x00" + host + "\x00" + id))
+
+	return sum[:saltSize], nil
+
+}
+
+// Function to encrypt data for this machine only
+//
+// The salt is derived from the hostname and machine id instead of being
+// random, so there's nothing to store besides the ciphertext. The flip side
+// is that the data can't be decrypted on any other machine, or on this one
+// once its hostname or machine id changes, even with the right passphrase.
+// Every blob sealed on a machine with the same passphrase shares a key.
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   error  - Error
+func SealLocal(data []byte, pass string, opts ...Option) ([]byte, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("seal local: options: %w", err)
+	}
+
+	salt, err := localSalt()
+	if err != nil {
+		return nil, fmt.Errorf("seal local: machine identity: %w", err)
+	}
+
+	ciphertext, _, err := encrypt(context.Background(), data, salt, pass, o)
+	return ciphertext, err
+
+}
+
+// Function to decrypt data sealed by SealLocal on this machine
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be decrypted
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func OpenLocal(data []byte, pass string, opts ...Option) ([]byte, error) {
+
+	salt, err := localSalt()
+	if err != nil {
+		return nil, fmt.Errorf("open local: machine identity: %w", err)
+	}
+
+	return Decrypt(data, salt, pass, opts...)
+
+}
@@ -0,0 +1,57 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Function to pretend to run on a machine, for the length of a test
+//
+//   t    *testing.T - Test
+//   host string     - Hostname of the machine
+//   id   string     - Machine id of the machine
+func fakeMachine(t *testing.T, host, id string) {
+	origHost, origID := hostname, machineID
+	hostname = func() (string, error) { return host, nil }
+	machineID = func() (string, error) { return id, nil }
+	t.Cleanup(func() { hostname, machineID = origHost, origID })
+}
+
+func TestSealLocal(t *testing.T) {
+
+	fakeMachine(t, "host", "0123456789abcdef")
+	salt, err := localSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := localSalt(); len(salt) != saltSize || !bytes.Equal(again, salt) {
+		t.Fatalf("got salts %x and %x, want the same %d bytes", salt, again, saltSize)
+	}
+
+	sealed, err := SealLocal([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := OpenLocal(sealed, "pass", testKDF); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	if pt, err := Decrypt(sealed, salt, "pass", testKDF); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v with the machine's salt", pt, err)
+	}
+
+	// Another hostname or machine id is another machine
+	for _, m := range [][2]string{{"other", "0123456789abcdef"}, {"host", "fedcba9876543210"}} {
+		fakeMachine(t, m[0], m[1])
+		if _, err := OpenLocal(sealed, "pass", testKDF); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("%v: got %v, want ErrAuthFailed", m, err)
+		}
+	}
+
+	fakeMachine(t, "host", "")
+	machineID = func() (string, error) { return "", errors.New("no machine id") }
+	if _, err := SealLocal([]byte("data"), "pass", testKDF); err == nil {
+		t.Fatal("sealed without a machine id")
+	}
+
+}
@@ -0,0 +1,102 @@
+package gocrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Error returned when too many failed attempts were made to decrypt a file
+var ErrLockedOut = errors.New("too many failed attempts")
+
+// Size of an attempt counter file (count + HMAC-SHA256)
+const attemptsSize = 4 + sha256.Size
+
+// Delay between attempts stops doubling after this many failures
+const maxAttemptDelayShift = 16
+
+// Function to get the path of the attempt counter of a file
+//
+//   path string - Path of the encrypted file
+func attemptsPath(path string) string {
+	return path + ".attempts"
+}
+
+// Function to compute the checksum of an attempt counter
+//
+// The counter is bound to the contents of the file it belongs to so it
+// can't be copied from another file or changed by accident. The MAC key
+// only depends on the file, which is public, so this isn't authentication:
+// anyone who can write next to the file can forge or delete the counter.
+// It deters casual brute-forcing by an app's users, not an attacker with
+// write access to the disk.
+//
+//   data  []byte - Contents of the encrypted file
+//   count uint32 - Number of failed attempts
+func attemptsMAC(data []byte, count uint32) []byte {
+	id := sha256.Sum256(data)
+	mac := hmac.New(sha256.New, append([]byte("gocrypt attempts "), id[:]...))
+	mac.Write(appendUint32(nil, count))
+	return mac.Sum(nil)
+}
+
+// Function to read the number of failed attempts to decrypt a file
+//
+// A missing counter is 0 failed attempts. A counter whose checksum doesn't
+// match is reported as corrupted rather than counted, so a bad write
+// doesn't lock the file out for good: removing the .attempts file resets
+// it.
+//
+//   path string - Path of the encrypted file
+//   data []byte - Contents of the encrypted file
+func readAttempts(path string, data []byte) (int, error) {
+
+	b, err := ioutil.ReadFile(attemptsPath(path))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(b) != attemptsSize {
+		return 0, fmt.Errorf("%w attempts: invalid counter size", ErrCorrupted)
+	}
+	count := binary.BigEndian.Uint32(b[:4])
+	if !hmac.Equal(b[4:], attemptsMAC(data, count)) {
+		return 0, fmt.Errorf("%w attempts: counter checksum mismatch", ErrCorrupted)
+	}
+
+	return int(count), nil
+
+}
+
+// Function to store the number of failed attempts to decrypt a file
+//
+//   path  string - Path of the encrypted file
+//   data  []byte - Contents of the encrypted file
+//   count int    - Number of failed attempts
+func writeAttempts(path string, data []byte, count int) error {
+	b := appendUint32(nil, uint32(count))
+	return ioutil.WriteFile(attemptsPath(path), append(b, attemptsMAC(data, uint32(count))...), 0600)
+}
+
+// Function to wait before an attempt, doubling the delay after each failure
+//
+//   failed int           - Number of failed attempts so far
+//   delay  time.Duration - Delay after the first failure
+func attemptDelay(failed int, delay time.Duration) {
+	if failed == 0 || delay <= 0 {
+		return
+	}
+	shift := failed - 1
+	if shift > maxAttemptDelayShift {
+		shift = maxAttemptDelayShift
+	}
+	time.Sleep(delay << uint(shift))
+}
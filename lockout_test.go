@@ -0,0 +1,72 @@
+package gocrypt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockout(t *testing.T) {
+
+	dir := t.TempDir() + "/"
+	if err := os.WriteFile(dir+"f", []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFile("f", dir, dir, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir() + "/"
+	lockout := WithLockout(3, 0)
+
+	// A correct passphrase resets the counter
+	if err := DecryptFile("f", dir, out, "wrong", lockout); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+	if _, err := os.Stat(dir + "f.3dfx.attempts"); err != nil {
+		t.Fatalf("no counter after a failure: %v", err)
+	}
+	if err := DecryptFile("f", dir, out, "pass", lockout); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if _, err := os.Stat(dir + "f.3dfx.attempts"); !os.IsNotExist(err) {
+		t.Fatalf("counter left after a success: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := DecryptFile("f", dir, out, "wrong", lockout); err == nil || errors.Is(err, ErrLockedOut) {
+			t.Fatalf("attempt %d: got %v, want a failed decryption", i, err)
+		}
+	}
+	if err := DecryptFile("f", dir, out, "pass", lockout); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf("got %v, want ErrLockedOut", err)
+	}
+
+	// A counter that doesn't match its checksum is reported, and removing
+	// it resets the lockout
+	for _, counter := range [][]byte{make([]byte, attemptsSize), {1, 2, 3}} {
+		if err := os.WriteFile(filepath.Join(dir, "f.3dfx.attempts"), counter, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := DecryptFile("f", dir, out, "pass", lockout); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("got %v, want ErrCorrupted", err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, "f.3dfx.attempts")); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile("f", dir, out, "pass", lockout); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only failed authentications count
+	for i := 0; i < 3; i++ {
+		if err := DecryptFile("f", dir, out, "pass", lockout, WithCipher(ChaCha20Poly1305)); !errors.Is(err, ErrAlgorithmMismatch) {
+			t.Fatalf("got %v, want ErrAlgorithmMismatch", err)
+		}
+	}
+	if _, err := os.Stat(dir + "f.3dfx.attempts"); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want no counter", err)
+	}
+
+}
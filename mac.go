@@ -0,0 +1,84 @@
+package gocrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Error returned when the .mac file of a file doesn't match its ciphertext
+var ErrMACMismatch = errors.New("mac mismatch")
+
+// Function to get the path of the detached MAC of a file
+//
+//   path string - Path of the encrypted file
+func macPath(path string) string {
+	return path + ".mac"
+}
+
+// Function to compute the detached MAC of a ciphertext
+//
+// The HMAC key is a subkey of the key derived from the passphrase (HKDF),
+// so it's never the key the data is encrypted with. The salt and key
+// derivation recorded in the header of the ciphertext take precedence.
+//
+//   data []byte  - Ciphertext
+//   salt []byte  - Salt the ciphertext was encrypted with (nil if in the header)
+//   pass string  - Passphrase
+//   o    Options - Options in use
+func detachedMAC(data, salt []byte, pass string, o Options) ([]byte, error) {
+
+	if info, header, _, _ := splitSealed(data); header != nil {
+		o.KDF = info.KDF
+		if salt == nil {
+			salt = info.Salt
+		}
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("no salt given or stored in the header")
+	}
+
+	_, master, err := createHash(salt, pass, o)
+	if err != nil {
+		return nil, err
+	}
+	defer Scrub(master)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("gocrypt mac")), key); err != nil {
+		return nil, fmt.Errorf("mac key: %w", err)
+	}
+	defer Scrub(key)
+
+	h := hmac.New(sha256.New, key)
+	h.Write(salt)
+	h.Write(data)
+
+	return h.Sum(nil), nil
+
+}
+
+// Function to check the detached MAC of a ciphertext
+//
+//   data []byte  - Ciphertext
+//   salt []byte  - Salt the ciphertext was encrypted with (nil if in the header)
+//   mac  []byte  - Content of the .mac file
+//   pass string  - Passphrase
+//   o    Options - Options in use
+func checkDetachedMAC(data, salt, mac []byte, pass string, o Options) error {
+
+	want, err := detachedMAC(data, salt, pass, o)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, want) {
+		return ErrMACMismatch
+	}
+
+	return nil
+
+}
@@ -0,0 +1,46 @@
+package gocrypt
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestDetachedMAC(t *testing.T) {
+
+	src, dst, out := t.TempDir()+"/", t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "data")
+	mac := WithDetachedMAC(true)
+	if err := EncryptFile("a.txt", src, dst, "pass", testKDF, mac); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(macPath(dst + "a.txt.3dfx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, mac); err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := os.ReadFile(out + "a.txt"); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// The MAC is checked before the ciphertext is decrypted
+	ct, err := os.ReadFile(dst + "a.txt.3dfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct[len(ct)-1] ^= 1
+	writeTestFile(t, dst+"a.txt.3dfx", string(ct))
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, mac); !errors.Is(err, ErrMACMismatch) {
+		t.Fatalf("got %v, want ErrMACMismatch", err)
+	}
+
+	if err := os.Remove(macPath(dst + "a.txt.3dfx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, mac); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, want a missing mac file", err)
+	}
+
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package gocrypt
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+// Function to read the id of the machine set up by systemd or D-Bus
+func readMachineID() (string, error) {
+
+	var err error
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		var b []byte
+		if b, err = ioutil.ReadFile(path); err == nil {
+			return string(bytes.TrimSpace(b)), nil
+		}
+	}
+
+	return "", err
+
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package gocrypt
+
+// Function to read the id of the machine, only available on Linux so the
+// hostname alone identifies the machine elsewhere
+func readMachineID() (string, error) {
+	return "", nil
+}
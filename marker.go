@@ -0,0 +1,38 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Function to decrypt data that follows a marker in a plaintext preamble
+//
+// Some tools put a plaintext preamble (like a shebang or a signature line)
+// in front of the encrypted data. Everything after the first occurrence of
+// marker is treated as the encrypted data.
+//
+// Variables to pass in:
+//
+//   data []byte   - Preamble, marker and encrypted data
+//   marker []byte - Marker separating the preamble from the encrypted data
+//   salt []byte   - Salt to use to create hash
+//   pass string   - Passphrase to use for decryption
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptAfterMarker(data []byte, marker []byte, salt []byte, pass string) ([]byte, error) {
+
+	if len(marker) == 0 {
+		return nil, errors.New("empty marker")
+	}
+
+	i := bytes.Index(data, marker)
+	if i < 0 {
+		return nil, errors.New("marker not found")
+	}
+
+	return Decrypt(data[i+len(marker):], salt, pass)
+
+}
@@ -0,0 +1,27 @@
+package gocrypt
+
+import "testing"
+
+func TestDecryptAfterMarker(t *testing.T) {
+
+	ct, salt, err := Encrypt([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marker := []byte("\n--- gocrypt ---\n")
+	file := append([]byte("#!/usr/bin/env decrypt\nsome preamble"), marker...)
+	file = append(file, ct...)
+
+	pt, err := DecryptAfterMarker(file, marker, salt, "pass")
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	if _, err := DecryptAfterMarker(file, []byte("missing"), salt, "pass"); err == nil {
+		t.Fatal("decrypted without the marker")
+	}
+	if _, err := DecryptAfterMarker(file, nil, salt, "pass"); err == nil {
+		t.Fatal("decrypted with an empty marker")
+	}
+
+}
@@ -0,0 +1,106 @@
+package gocrypt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Size of the fixed part of the metadata field (mode + mtime + size)
+const metadataFixedSize = 4 + 8 + 8
+
+// Maximum length of the name kept in the metadata of a file
+const maxMetadataNameSize = 255
+
+// Details of the original file kept in the header by EncryptFile, see
+// Options.PreserveMetadata
+type Metadata struct {
+	Name    string      // Base name of the file
+	Mode    fs.FileMode // Permissions of the file
+	ModTime time.Time   // Modification time of the file
+	Size    int64       // Size of the plaintext in bytes
+}
+
+// Function to get the metadata of a file being encrypted
+//
+//   path string - Path of the file
+//   size int    - Size of the plaintext
+func fileMetadata(path string, size int) (*Metadata, error) {
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{Name: filepath.Base(path), Mode: fi.Mode().Perm(), ModTime: fi.ModTime(), Size: int64(size)}
+	if !validMetadataName(m.Name) {
+		return nil, fmt.Errorf("name %q can't be kept", m.Name)
+	}
+
+	return m, nil
+
+}
+
+// Function to check that a name is a plain base name
+//
+//   name string - Name to check
+func validMetadataName(name string) bool {
+	return name != "" && name != "." && name != ".." && len(name) <= maxMetadataNameSize &&
+		!strings.ContainsAny(name, `/\`) && !strings.ContainsRune(name, 0)
+}
+
+// Function to encode metadata as the value of its header field
+//
+//   m *Metadata - Metadata to encode
+func marshalMetadata(m *Metadata) []byte {
+
+	v := make([]byte, 0, metadataFixedSize+len(m.Name))
+	v = appendUint32(v, uint32(m.Mode.Perm()))
+	v = appendUint64(v, uint64(m.ModTime.UnixNano()))
+	v = appendUint64(v, uint64(m.Size))
+
+	return append(v, m.Name...)
+
+}
+
+// Function to decode the value of a metadata header field
+//
+//   value []byte - Value of the field
+func parseMetadata(value []byte) (*Metadata, error) {
+
+	if len(value) <= metadataFixedSize {
+		return nil, fmt.Errorf("%w header: invalid metadata field", ErrCorrupted)
+	}
+
+	m := &Metadata{
+		Mode:    fs.FileMode(binary.BigEndian.Uint32(value)).Perm(),
+		ModTime: time.Unix(0, int64(binary.BigEndian.Uint64(value[4:]))),
+		Size:    int64(binary.BigEndian.Uint64(value[12:])),
+		Name:    string(value[metadataFixedSize:]),
+	}
+	if m.Size < 0 || !validMetadataName(m.Name) {
+		return nil, fmt.Errorf("%w header: invalid metadata field", ErrCorrupted)
+	}
+
+	return m, nil
+
+}
+
+// Function to give a decrypted file the permissions and modification time
+// kept in its metadata
+//
+//   path string    - Path of the decrypted file
+//   m    *Metadata - Metadata of the file
+func restoreMetadata(path string, m *Metadata) error {
+
+	if err := os.Chmod(path, m.Mode.Perm()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, m.ModTime, m.ModTime)
+
+}
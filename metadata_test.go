@@ -0,0 +1,56 @@
+package gocrypt
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPreserveMetadata(t *testing.T) {
+
+	src, dst, out := t.TempDir()+"/", t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "data")
+	mtime := time.Date(2020, 5, 17, 10, 30, 0, 123456789, time.UTC)
+	if err := os.Chmod(src+"a.txt", 0604); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src+"a.txt", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptFile("a.txt", src, dst, "pass", testKDF, WithPreserveMetadata(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name comes from the header, not from the encrypted file
+	for _, ext := range []string{".3dfx", ".salt"} {
+		if err := os.Rename(dst+"a.txt"+ext, dst+"renamed"+ext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := DecryptFile("renamed", dst, out, "pass", testKDF, WithPreserveMetadata(true)); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(out + "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0604 {
+		t.Fatalf("got mode %v, want 0604", fi.Mode().Perm())
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("got mtime %v, want %v", fi.ModTime(), mtime)
+	}
+	if data, err := os.ReadFile(out + "a.txt"); err != nil || string(data) != "data" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+	// Without the option the file is written as named, with a fresh mtime
+	if err := DecryptFile("renamed", dst, out, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(out + "renamed"); err != nil || fi.ModTime().Equal(mtime) {
+		t.Fatalf("got %v, want a new file without the metadata", err)
+	}
+
+}
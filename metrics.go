@@ -0,0 +1,46 @@
+package gocrypt
+
+import "time"
+
+// Metrics receives measurements of encrypt and decrypt operations.
+//
+// Implement it to export them to Prometheus, expvar or anything else
+// without this package depending on them. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	ObserveBytes(n int64)            // Plaintext bytes encrypted or decrypted by an operation
+	ObserveDuration(d time.Duration) // Time an operation took
+	IncErrors()                      // An operation failed
+}
+
+// Metrics doing nothing, used when Options.Metrics isn't set
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveBytes(int64)            {}
+func (noopMetrics) ObserveDuration(time.Duration) {}
+func (noopMetrics) IncErrors()                    {}
+
+// Function to get the metrics to report to
+func (o Options) metrics() Metrics {
+	if o.Metrics == nil {
+		return noopMetrics{}
+	}
+	return o.Metrics
+}
+
+// Function to report the outcome of an operation
+//
+//   start time.Time - When the operation started
+//   n     int64     - Plaintext bytes processed
+//   err   error     - Error the operation returned
+func (o Options) observe(start time.Time, n int64, err error) {
+
+	m := o.metrics()
+	m.ObserveDuration(time.Since(start))
+	if err != nil {
+		m.IncErrors()
+		return
+	}
+	m.ObserveBytes(n)
+
+}
@@ -0,0 +1,71 @@
+package gocrypt
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Metrics recording what is reported
+type recordingMetrics struct {
+	mu        sync.Mutex
+	bytes     int64
+	durations int
+	errors    int
+}
+
+func (m *recordingMetrics) ObserveBytes(n int64) {
+	m.mu.Lock()
+	m.bytes += n
+	m.mu.Unlock()
+}
+
+func (m *recordingMetrics) ObserveDuration(d time.Duration) {
+	m.mu.Lock()
+	m.durations++
+	m.mu.Unlock()
+}
+
+func (m *recordingMetrics) IncErrors() {
+	m.mu.Lock()
+	m.errors++
+	m.mu.Unlock()
+}
+
+func TestMetrics(t *testing.T) {
+
+	m := &recordingMetrics{}
+	opts := []Option{testKDF, WithMetrics(m)}
+
+	ciphertext, salt, err := Encrypt(make([]byte, 10), "pass", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext, salt, "pass", opts...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext, salt, "wrong", opts...); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+
+	var buf, out bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(make([]byte, 3*chunkSize)), "pass", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptStream(&out, &buf, meta.Salt, "pass", opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed operations report an error but no bytes
+	if want := int64(10 + 10 + 2*3*chunkSize); m.bytes != want || m.durations != 5 || m.errors != 1 {
+		t.Fatalf("got %d bytes, %d durations, %d errors, want %d, 5, 1", m.bytes, m.durations, m.errors, want)
+	}
+
+	// Nothing to report to by default
+	if _, _, err := Encrypt([]byte("data"), "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+
+}
@@ -0,0 +1,150 @@
+package gocrypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Version byte of the minimal format, kept apart from the first byte of the
+// stream magic so the two can't be confused
+const minimalVersion byte = 0x81
+
+// Function to encrypt data in the minimal format
+//
+// The output is [version][nonce][ciphertext + tag], 29 bytes more than the
+// plaintext. Nothing else is stored: the salt is returned in Meta and every
+// parameter must be supplied again to decrypt. The whole plaintext is sealed
+// at once so it's limited to a single chunk (64 KiB).
+//
+//   dst  io.Writer - Writer to output the encrypted data to
+//   src  io.Reader - Reader to get the data to be encrypted from
+//   pass string    - Passphrase to use for encryption
+//   o    Options   - Options in use
+func encryptMinimal(dst io.Writer, src io.Reader, pass string, o Options) (Meta, error) {
+
+	if o.SaltPosition != SaltDetached {
+		return Meta{}, errors.New("minimal overhead mode can't store the salt")
+	}
+	if !o.NotBefore.IsZero() {
+		return Meta{}, errors.New("minimal overhead mode can't store NotBefore")
+	}
+
+	data, err := readMinimal(src, chunkSize)
+	if err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: read: %w", err)
+	}
+	defer Scrub(data)
+
+	if err := o.checkPassphrase(pass); err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: %w", err)
+	}
+
+	salt, key, err := createHash(nil, pass, o)
+	if err != nil {
+		return Meta{}, err
+	}
+
+	aead, release, err := newAEAD(o.Cipher, key, o.keys)
+	Scrub(key)
+	if err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: cipher: %w", err)
+	}
+	defer release()
+
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(data)+aead.Overhead())
+	out[0] = minimalVersion
+	nonce := out[1:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: nonce: %w", err)
+	}
+	out = aead.Seal(out, nonce, data, out[:1])
+
+	if _, err := dst.Write(out); err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: write: %w", err)
+	}
+	if err := syncOutput(dst, o); err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: sync: %w", err)
+	}
+
+	meta := Meta{Salt: salt, Size: int64(len(data))}
+	if o.Digest {
+		sum := sha256.Sum256(data)
+		meta.Digest = sum[:]
+	}
+
+	return meta, nil
+
+}
+
+// Function to decrypt data in the minimal format
+//
+//   src  io.Reader                - Reader to get the encrypted data from
+//   salt []byte                   - Salt to use to create hash
+//   pass string                   - Passphrase to use for decryption
+//   fn   func(frame []byte) error - Function to call with the plaintext
+//   o    Options                  - Options in use
+func decryptMinimal(src io.Reader, salt []byte, pass string, fn func(frame []byte) error, o Options) error {
+
+	if len(salt) == 0 {
+		return errors.New("no salt given")
+	}
+
+	data, err := readMinimal(src, 1+12+chunkSize+16)
+	if err != nil {
+		return fmt.Errorf("decrypt stream: read: %w", err)
+	}
+
+	_, key, err := createHash(salt, pass, o)
+	if err != nil {
+		return err
+	}
+
+	aead, release, err := newAEAD(o.Cipher, key, o.keys)
+	Scrub(key)
+	if err != nil {
+		return fmt.Errorf("decrypt stream: cipher: %w", err)
+	}
+	defer release()
+
+	if len(data) < 1+aead.NonceSize()+aead.Overhead() || len(data) > 1+aead.NonceSize()+chunkSize+aead.Overhead() {
+		return fmt.Errorf("%w data: invalid length", ErrCorrupted)
+	}
+	if data[0] != minimalVersion {
+		return errors.New("not in the minimal format")
+	}
+
+	nonce, ciphertext := data[1:1+aead.NonceSize()], data[1+aead.NonceSize():]
+	if o.MaxOutputSize > 0 && int64(len(ciphertext)-aead.Overhead()) > o.MaxOutputSize {
+		return ErrTooLarge
+	}
+	plaintext, err := aead.Open(ciphertext[:0], nonce, ciphertext, data[:1])
+	if err != nil {
+		return fmt.Errorf("decrypt stream: open: %w", ErrAuthFailed)
+	}
+	defer Scrub(plaintext)
+
+	return fn(plaintext)
+
+}
+
+// Function to read a whole payload of the minimal format
+//
+//   r     io.Reader - Reader to get the payload from
+//   limit int       - Maximum size of the payload
+func readMinimal(r io.Reader, limit int) ([]byte, error) {
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, ErrTooLarge
+	}
+
+	return data, nil
+
+}
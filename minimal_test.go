@@ -0,0 +1,52 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMinimalOverhead(t *testing.T) {
+
+	minimal := WithMinimalOverhead(true)
+	token := []byte("session-token")
+
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(token), "pass", testKDF, minimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overhead := buf.Len() - len(token); overhead != 29 {
+		t.Fatalf("got %d bytes of overhead, want 29", overhead)
+	}
+	if buf.Bytes()[0] != minimalVersion {
+		t.Fatalf("got version byte %#x, want %#x", buf.Bytes()[0], minimalVersion)
+	}
+	if int64(buf.Len()) != CiphertextSize(int64(len(token)), Options{MinimalOverhead: true}) {
+		t.Fatalf("got %d bytes, CiphertextSize disagrees", buf.Len())
+	}
+
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(buf.Bytes()), meta.Salt, "pass", testKDF, minimal); err != nil || !bytes.Equal(out.Bytes(), token) {
+		t.Fatalf("got %q, %v", out.Bytes(), err)
+	}
+
+	// Nothing is stored, so the parameters must be given again
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(buf.Bytes()), meta.Salt, "pass", minimal); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+	tampered := append([]byte(nil), buf.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(tampered), meta.Salt, "pass", testKDF, minimal); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	if _, err := EncryptStream(ioutil.Discard, bytes.NewReader(make([]byte, chunkSize+1)), "pass", testKDF, minimal); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+	if _, err := EncryptStream(ioutil.Discard, bytes.NewReader(token), "pass", testKDF, minimal, WithSaltPosition(SaltHeader)); err == nil {
+		t.Fatal("stored the salt in the minimal format")
+	}
+
+}
@@ -0,0 +1,285 @@
+package gocrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Size of the channel id at the start of each frame of a multiplexed stream
+const muxIDSize = 2
+
+// Error returned when using a multiplexed stream that was closed
+var errMuxClosed = errors.New("mux stream closed")
+
+// MuxWriter multiplexes several logical channels into one encrypted stream.
+//
+// The channel id is sealed with the data of each frame so it is
+// authenticated like the rest of the stream. Call Close once every channel
+// is done, the stream is truncated otherwise.
+type MuxWriter struct {
+	mu  sync.Mutex
+	fw  *frameWriter
+	err error
+}
+
+// Function to create a writer multiplexing channels into an encrypted stream
+//
+// The salt is stored in the stream header (or trailer if set in Options) so
+// only the passphrase is needed to demultiplex.
+//
+// Variables to pass in:
+//
+//   w io.Writer - Writer to output the encrypted stream to
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   *MuxWriter - Multiplexing writer
+//   error      - Error
+func NewMuxEncryptWriter(w io.Writer, pass string, opts ...Option) (*MuxWriter, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("new mux encrypt writer: options: %w", err)
+	}
+
+	saltPos := o.SaltPosition
+	if saltPos == SaltDetached {
+		saltPos = SaltHeader
+	}
+
+	fw, err := newFrameWriter(context.Background(), w, w, pass, saltPos, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MuxWriter{fw: fw}, nil
+
+}
+
+// Function to get the writer of a channel
+//
+// Every Write is sealed right away into one or more frames tagged with the
+// channel id, so writes to different channels can be interleaved freely.
+// Channel writers are safe for concurrent use.
+//
+// Variables to pass in:
+//
+//   id uint16 - Id of the channel
+//
+// Returns:
+//
+//   io.Writer - Writer of the channel
+func (m *MuxWriter) Channel(id uint16) io.Writer {
+	return muxChannelWriter{m: m, id: id}
+}
+
+// Function to end the stream
+//
+// Returns:
+//
+//   error - Error
+func (m *MuxWriter) Close() error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		if m.err == errMuxClosed {
+			return nil
+		}
+		return m.err
+	}
+
+	err := m.fw.writeFrame(nil, true)
+	m.fw.close()
+	m.err = errMuxClosed
+
+	return err
+
+}
+
+// Function to seal data for a channel
+//
+//   id uint16 - Id of the channel
+//   p  []byte - Data to seal
+func (m *MuxWriter) write(id uint16, p []byte) (int, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return 0, m.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		binary.BigEndian.PutUint16(m.fw.plain, id)
+		c := copy(m.fw.plain[muxIDSize:], p)
+		if err := m.fw.writeFrame(m.fw.plain[:muxIDSize+c], false); err != nil {
+			m.err = err
+			return n, err
+		}
+		n += c
+		p = p[c:]
+	}
+
+	return n, nil
+
+}
+
+// Writer of a single channel of a MuxWriter
+type muxChannelWriter struct {
+	m  *MuxWriter
+	id uint16
+}
+
+// Function to write data to the channel
+func (c muxChannelWriter) Write(p []byte) (int, error) {
+	return c.m.write(c.id, p)
+}
+
+// MuxReader demultiplexes a stream written by a MuxWriter.
+//
+// Frames are decrypted as channels are read and routed to the channel they
+// belong to. Data of channels that aren't being read is buffered in memory
+// until they are, so read every channel of the stream.
+type MuxReader struct {
+	mu   sync.Mutex
+	fr   *frameReader
+	bufs map[uint16]*bytes.Buffer
+	err  error
+}
+
+// Function to create a reader demultiplexing an encrypted stream
+//
+// Variables to pass in:
+//
+//   r io.Reader - Reader to get the encrypted stream from
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   *MuxReader - Demultiplexing reader
+//   error      - Error
+func NewMuxDecryptReader(r io.Reader, pass string, opts ...Option) (*MuxReader, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("new mux decrypt reader: options: %w", err)
+	}
+
+	fr, err := newFrameReader(context.Background(), r, r, nil, pass, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MuxReader{fr: fr, bufs: make(map[uint16]*bytes.Buffer)}, nil
+
+}
+
+// Function to get the reader of a channel
+//
+// The reader returns io.EOF once the whole stream has been read and the
+// channel's data consumed. Channel readers are safe for concurrent use.
+//
+// Variables to pass in:
+//
+//   id uint16 - Id of the channel
+//
+// Returns:
+//
+//   io.Reader - Reader of the channel
+func (m *MuxReader) Channel(id uint16) io.Reader {
+	return muxChannelReader{m: m, id: id}
+}
+
+// Function to release the resources of the reader
+//
+// Returns:
+//
+//   error - Error
+func (m *MuxReader) Close() error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stop(errMuxClosed)
+
+	return nil
+
+}
+
+// Function to stop decrypting frames
+//
+//   err error - Error to return from now on
+func (m *MuxReader) stop(err error) {
+	if m.fr != nil {
+		m.fr.close()
+		m.fr = nil
+	}
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// Function to read data of a channel
+//
+//   id uint16 - Id of the channel
+//   p  []byte - Buffer to read into
+func (m *MuxReader) read(id uint16, p []byte) (int, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+
+		if buf := m.bufs[id]; buf != nil && buf.Len() > 0 {
+			return buf.Read(p)
+		}
+		if m.err != nil {
+			return 0, m.err
+		}
+
+		frame, err := m.fr.next()
+		if err != nil {
+			m.stop(err)
+			continue
+		}
+		if len(frame) == 0 {
+			continue
+		}
+		if len(frame) < muxIDSize {
+			m.stop(fmt.Errorf("stream %w: invalid mux frame", ErrCorrupted))
+			continue
+		}
+
+		frameID := binary.BigEndian.Uint16(frame)
+		buf := m.bufs[frameID]
+		if buf == nil {
+			buf = new(bytes.Buffer)
+			m.bufs[frameID] = buf
+		}
+		buf.Write(frame[muxIDSize:])
+
+	}
+
+}
+
+// Reader of a single channel of a MuxReader
+type muxChannelReader struct {
+	m  *MuxReader
+	id uint16
+}
+
+// Function to read data from the channel
+func (c muxChannelReader) Read(p []byte) (int, error) {
+	return c.m.read(c.id, p)
+}
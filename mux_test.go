@@ -0,0 +1,70 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+
+	var buf bytes.Buffer
+	mw, err := NewMuxEncryptWriter(&buf, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Interleaved writes, one of them spanning several frames
+	big := bytes.Repeat([]byte("b"), 3*chunkSize+5)
+	one, two := mw.Channel(1), mw.Channel(2)
+	for _, w := range []struct {
+		ch   io.Writer
+		data []byte
+	}{
+		{one, []byte("hello ")},
+		{two, big[:10]},
+		{one, []byte("world")},
+		{two, big[10:]},
+	} {
+		if _, err := w.ch.Write(w.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := one.Write([]byte("late")); err == nil {
+		t.Fatal("wrote to a closed stream")
+	}
+
+	// Channel 2 is read first so channel 1 has to be buffered
+	mr, err := NewMuxDecryptReader(bytes.NewReader(buf.Bytes()), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(mr.Channel(2))
+	if err != nil || !bytes.Equal(got, big) {
+		t.Fatalf("channel 2: got %d bytes, %v, want %d", len(got), err, len(big))
+	}
+	got, err = ioutil.ReadAll(mr.Channel(1))
+	if err != nil || string(got) != "hello world" {
+		t.Fatalf("channel 1: got %q, %v", got, err)
+	}
+	if got, err := ioutil.ReadAll(mr.Channel(3)); err != nil || len(got) != 0 {
+		t.Fatalf("channel 3: got %q, %v", got, err)
+	}
+	mr.Close()
+
+	tampered := append([]byte(nil), buf.Bytes()...)
+	tampered[len(tampered)-40] ^= 1
+	mr, err = NewMuxDecryptReader(bytes.NewReader(tampered), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(mr.Channel(2)); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+}
@@ -0,0 +1,76 @@
+package gocrypt
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestDeriveNonce(t *testing.T) {
+
+	base := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	seen := make(map[string]bool)
+	for counter := uint64(0); counter < 1000; counter++ {
+		nonce, err := deriveNonce(make([]byte, 12), base, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(nonce, base) {
+			t.Fatalf("got %x, want the base %x first", nonce, base)
+		}
+		if seen[string(nonce)] {
+			t.Fatalf("nonce %x repeated at frame %d", nonce, counter)
+		}
+		seen[string(nonce)] = true
+	}
+
+	// The counter can't wrap around to reuse a nonce
+	if _, err := deriveNonce(make([]byte, 12), base, math.MaxUint32); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deriveNonce(make([]byte, 12), base, math.MaxUint32+1); err == nil {
+		t.Fatal("derived a nonce past the last frame")
+	}
+
+}
+
+func TestDerivedNonces(t *testing.T) {
+
+	data := make([]byte, 4*minChunkSize+7)
+	opts := []Option{WithChunkSize(minChunkSize), WithSaltPosition(SaltHeader)}
+	random, _ := encryptTestStream(t, data, opts...)
+	derived, _ := encryptTestStream(t, data, append(opts, WithDerivedNonces(true))...)
+	other, _ := encryptTestStream(t, data, append(opts, WithDerivedNonces(true))...)
+
+	// Each of the 5 frames saves its 12-byte nonce, the header gains the
+	// base as a field
+	if saved := len(random) - len(derived); saved != 5*12-(3+nonceBaseSize) {
+		t.Fatalf("saved %d bytes", saved)
+	}
+
+	info, err := Inspect(bytes.NewReader(derived))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherInfo, err := Inspect(bytes.NewReader(other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.NonceBase) != nonceBaseSize || bytes.Equal(info.NonceBase, otherInfo.NonceBase) {
+		t.Fatalf("got bases %x and %x, want distinct %d-byte bases", info.NonceBase, otherInfo.NonceBase, nonceBaseSize)
+	}
+
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(derived), nil, "pass"); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("got %d bytes, %v", out.Len(), err)
+	}
+
+	// The base is authenticated, changing it breaks every frame
+	tampered := append([]byte(nil), derived...)
+	i := bytes.Index(tampered, info.NonceBase)
+	tampered[i] ^= 1
+	if err := DecryptStream(&out, bytes.NewReader(tampered), nil, "pass"); err == nil {
+		t.Fatal("decrypted with a tampered nonce base")
+	}
+
+}
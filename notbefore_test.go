@@ -0,0 +1,76 @@
+package gocrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestNotBefore(t *testing.T) {
+
+	at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	ct, salt := encryptTestStream(t, []byte("embargoed"), WithNotBefore(at))
+	clock := func(now time.Time) Option {
+		return WithClock(func() time.Time { return now })
+	}
+
+	info, err := Inspect(bytes.NewReader(ct))
+	if err != nil || !info.NotBefore.Equal(at) {
+		t.Fatalf("got %v, %v, want %v", info.NotBefore, err, at)
+	}
+
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(ct), salt, "pass", clock(at.Add(-time.Second))); err != ErrNotYetValid {
+		t.Fatalf("got %v, want ErrNotYetValid", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("wrote %d bytes before activation", out.Len())
+	}
+
+	for _, now := range []time.Time{at, at.Add(time.Hour)} {
+		out.Reset()
+		if err := DecryptStream(&out, bytes.NewReader(ct), salt, "pass", clock(now)); err != nil || out.String() != "embargoed" {
+			t.Fatalf("%v: got %q, %v", now, out.String(), err)
+		}
+	}
+
+	// Moving the time earlier breaks authentication
+	field := append([]byte{fieldNotBefore, 0, 8}, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(field[3:], uint64(at.Unix()))
+	i := bytes.Index(ct, field)
+	if i < 0 {
+		t.Fatal("no NotBefore field in the header")
+	}
+	tampered := append([]byte(nil), ct...)
+	binary.BigEndian.PutUint64(tampered[i+3:], uint64(at.Add(-24*time.Hour).Unix()))
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(tampered), salt, "pass", clock(at.Add(-time.Hour))); err == nil {
+		t.Fatal("decrypted with a tampered activation time")
+	}
+
+	// Data encrypted by Encrypt is held back the same way
+	for _, pos := range []SaltPosition{SaltDetached, SaltHeader} {
+		ciphertext, salt, err := Encrypt([]byte("embargoed"), "pass", testKDF, WithSaltPosition(pos), WithNotBefore(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Decrypt(ciphertext, salt, "pass", clock(at.Add(-time.Second))); err != ErrNotYetValid {
+			t.Fatalf("got %v, want ErrNotYetValid", err)
+		}
+		if pt, err := Decrypt(ciphertext, salt, "pass", clock(at)); err != nil || string(pt) != "embargoed" {
+			t.Fatalf("got %q, %v", pt, err)
+		}
+		tampered := append([]byte(nil), ciphertext...)
+		binary.BigEndian.PutUint64(tampered[bytes.Index(tampered, field)+3:], uint64(at.Add(-24*time.Hour).Unix()))
+		if _, err := Decrypt(tampered, salt, "pass", clock(at.Add(-time.Hour))); err == nil {
+			t.Fatal("decrypted with a tampered activation time")
+		}
+	}
+
+	// The minimal format has nowhere to keep it
+	if _, err := EncryptStream(ioutil.Discard, bytes.NewReader([]byte("embargoed")), "pass", testKDF, WithMinimalOverhead(true), WithNotBefore(at)); err == nil {
+		t.Fatal("got nil, want an error for NotBefore in the minimal format")
+	}
+
+}
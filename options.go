@@ -0,0 +1,1013 @@
+package gocrypt
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Error returned when trying to change locked Options
+var ErrOptionsLocked = errors.New("options are locked")
+
+// Options to customize how data is encrypted and decrypted.
+//
+// Options built with NewOptions start from the defaults. Options can be
+// frozen with Lock so a configuration shared across a program can't be
+// changed through Apply once it's in use.
+type Options struct {
+	// Expected size of the plaintext in bytes. When set, output files are
+	// preallocated up front to avoid fragmentation and trimmed to the actual
+	// size once written, so the hint does not need to be exact.
+	ExpectedSize int64
+
+	// Compute a SHA-256 digest of the plaintext while encrypting a stream so
+	// it can be used as a content address without reading the data twice.
+	Digest bool
+
+	// Pool of *[]byte buffers to use for streaming. Servers running many
+	// streams at once can share one pool to avoid allocating buffers for every
+	// stream. Buffers are wiped before being put back.
+	BufferPool *sync.Pool
+
+	// Flush written files to disk (fsync) before closing them, so the data is
+	// durable once a function returns successfully. Enabled by default. Has
+	// no effect when writing to something that isn't a file.
+	SyncOnClose bool
+
+	// Maximum number of scrypt derivations allowed to run at once (0 means
+	// no limit). Each derivation needs tens of MiB (see EstimateMemory), so
+	// bounding them keeps a busy server from running out of memory. Calls
+	// configured with the same limit share the same slots.
+	MaxConcurrentKDF int
+
+	// Maximum number of frames to decrypt from a stream (0 means no limit).
+	// Decryption fails with ErrTooLarge as soon as a stream goes over it,
+	// which bounds the work done on untrusted input.
+	MaxFrames int
+
+	// Reed-Solomon parity to add to each encrypted frame of a stream, so a
+	// limited number of corrupted shards can be recovered (for archives on
+	// media prone to bit rot). Disabled when Shards is 0. The settings are
+	// stored in the header so nothing is needed to decrypt.
+	Parity Parity
+
+	// Where EncryptStream stores the salt. By default it's only returned in
+	// Meta for the caller to keep, SaltHeader and SaltTrailer store it in the
+	// stream so only the passphrase is needed to decrypt. Decrypting a stream
+	// with a trailing salt needs an io.ReadSeeker. Encrypt supports
+	// SaltHeader too, which EncryptFile and EncryptToFile always use.
+	SaltPosition SaltPosition
+
+	// Derive the nonce of each frame of a stream from a random 64-bit base
+	// stored in the header and the frame counter instead of storing a random
+	// nonce in every frame, which saves 12 bytes per frame. The base must be
+	// unique per key: it is random and a new salt (and so a new key) is used
+	// for every stream, so only reusing a key outside of this package can
+	// break that. Limited to 2^32 frames (256 TiB) per stream.
+	DerivedNonces bool
+
+	// Number of failed attempts DecryptFile allows before refusing to try
+	// again with ErrLockedOut (0 disables the lockout). Failures are counted
+	// in an .attempts file next to the encrypted file, which is removed on
+	// success. The counter is checksummed and bound to the file, but not
+	// with a secret: it slows down guessing through an app, it doesn't stop
+	// anyone who can write next to the file from resetting or forging it.
+	// Only failed authentications count. A counter that doesn't match its
+	// checksum makes DecryptFile fail with ErrCorrupted until the .attempts
+	// file is removed, which is also how to reset a lockout.
+	MaxAttempts int
+
+	// Delay DecryptFile waits before an attempt following a failure, doubled
+	// after every further failure.
+	AttemptDelay time.Duration
+
+	// Time before which data must not be decrypted (the zero time means no
+	// restriction), for time-locked secrets such as embargoed content. It
+	// is stored in the header, with a precision of a second, and
+	// authenticated with the data. Decryption fails with ErrNotYetValid
+	// before then. Data encrypted by Encrypt gets a header to hold it, the
+	// minimal format has none so MinimalOverhead refuses it.
+	NotBefore time.Time
+
+	// Function returning the current time, time.Now when nil. Lets tests
+	// control the clock NotBefore is checked against.
+	Clock func() time.Time
+
+	// Derive keys the way versions before the passphrase fix did, ignoring
+	// the passphrase, so data encrypted by them can still be decrypted. Use
+	// UpgradeFile to re-encrypt such files with the passphrase.
+	//
+	// Deprecated: only use it to read and migrate old data, anything
+	// encrypted with it is protected by a publicly known key.
+	LegacyKeyDerivation bool
+
+	// Leave holes in files written by DecryptFile where the plaintext has
+	// runs of zeros (of at least 4 KiB) instead of writing them, so disk
+	// images and VM files stay sparse. The file reads back the same, it only
+	// takes less space on filesystems supporting holes.
+	SparseOutput bool
+
+	// Purpose of the key (e.g. "session" or "backup"), folded into key
+	// derivation (HKDF info) so data encrypted for one purpose can't be
+	// decrypted for another even with the same passphrase and salt. It's
+	// stored in the authenticated header (Encrypt adds one for it) and
+	// decryption fails with ErrPurposeMismatch when it doesn't match.
+	Purpose string
+
+	// Encrypt streams in the minimal format for tiny payloads (like session
+	// tokens): a version byte, the nonce and the sealed data, 29 bytes of
+	// overhead instead of 40 or more. Nothing is stored in a header, so the
+	// salt is always detached, the payload is limited to 64 KiB and the same
+	// Options (purpose, key derivation) must be given to decrypt. Header
+	// based options like Parity or DerivedNonces are ignored, NotBefore is
+	// refused.
+	MinimalOverhead bool
+
+	// AEAD algorithm to encrypt with, AES-256-GCM by default. Streams, and
+	// data encrypted by Encrypt when it's set, store it in their header, so
+	// it doesn't need to be set to decrypt, but when it is, decrypting data
+	// encrypted with another algorithm fails with ErrAlgorithmMismatch
+	// before trying to authenticate anything.
+	Cipher Algorithm
+
+	// Chain the frames of a stream: each frame authenticates the tag of the
+	// previous one, so changing or truncating any frame invalidates every
+	// frame after it. Meta.ChainHead can be checkpointed externally to make
+	// a log tamper-evident.
+	HashChain bool
+
+	// Number of times to retry a failed key derivation before giving up and
+	// returning the last error, waiting 10ms before the first retry and
+	// twice as long before each next one. Derivations only fail on transient
+	// conditions like memory pressure, so retries are off by default.
+	KDFRetries int
+
+	// Metrics to report bytes processed, durations and errors of Encrypt,
+	// Decrypt and the stream functions to. Nothing is reported when nil.
+	Metrics Metrics
+
+	// Maximum time a key derivation may take before giving up with
+	// ErrKDFTimeout, so misconfigured parameters or an overloaded host can't
+	// hang a request handler. scrypt itself can't be interrupted: an
+	// abandoned derivation finishes in the background, holding its memory
+	// and concurrency slot until then. No limit when 0.
+	MaxKDFDuration time.Duration
+
+	// Maximum memory a key derivation may use in bytes, 1 GiB when 0. The
+	// parameters used to decrypt are read from the data, so this keeps a
+	// crafted header from exhausting memory. Derivations over it fail with
+	// ErrKDFTooCostly before they start, encryption included, so data the
+	// same Options can't decrypt isn't made.
+	MaxKDFMemory int64
+
+	// Size of the plaintext sealed into each frame of a stream, between
+	// 1 KiB and 16 MiB, 64 KiB by default. It's stored in the header so it
+	// doesn't need to be set to decrypt. Larger chunks cost less overhead,
+	// smaller ones use less memory per stream. Ignored with MinimalOverhead.
+	ChunkSize int
+
+	// Label stored in the header of a stream, like "prod db dump 2024-06",
+	// and returned by Inspect. It isn't encrypted but it's authenticated
+	// with every frame, so it can't be changed without decryption failing.
+	// At most 1 KiB of UTF-8 without control characters.
+	Comment string
+
+	// Let EncryptFile encrypt a file that is already encrypted by this
+	// package, which it otherwise refuses with ErrAlreadyEncrypted
+	AllowDoubleEncrypt bool
+
+	// Key derivation function (scrypt or Argon2id) and its cost parameters,
+	// scrypt with the default costs when zero. Streams, and data encrypted
+	// by Encrypt when they're set, record them in their header, so they
+	// don't need to be set to decrypt, but when KDF.Func is, decrypting data
+	// derived with another function fails with ErrKDFMismatch.
+	KDF KDFParams
+
+	// Treat decrypted data as text: a UTF-8 byte order mark is stripped,
+	// UTF-16 text with a byte order mark is converted to UTF-8 and line
+	// endings are normalized to LineEnding. Applies to Decrypt, DecryptFile
+	// and DecryptStream. Off by default so binary data is left untouched.
+	TextMode bool
+
+	// Line ending style TextMode normalizes to, the platform's by default
+	LineEnding LineEnding
+
+	// Identifier of the stream, like a UUID, stored in its header and
+	// returned by Inspect to correlate a ciphertext with the operation that
+	// wrote it. The header is authenticated with every frame, so frames
+	// spliced in from a stream with another id fail to decrypt. At most 255
+	// bytes.
+	StreamID []byte
+
+	// Store a small authenticated canary right after the header of a
+	// stream, or of data encrypted by Encrypt. Decryption checks it before
+	// reading any frame (or opening the data) and fails with
+	// ErrWrongPassphrase, which is also an ErrAuthFailed, so a wrong
+	// passphrase is told apart from a corrupted payload without going
+	// through it. The data stays authenticated as without it. Adds 33 bytes
+	// to the output.
+	Canary bool
+
+	// Have EncryptFile write a .mac file next to the .3dfx file holding an
+	// HMAC-SHA256 of the ciphertext, keyed by a subkey of the passphrase,
+	// for systems keeping integrity tags apart. DecryptFile checks it before
+	// decrypting and fails with ErrMACMismatch if it doesn't match. Costs
+	// one more key derivation.
+	DetachedMAC bool
+
+	// Bind files written by EncryptFile to their path: the absolute path of
+	// the .3dfx file is authenticated with the data (not stored in it) and
+	// DecryptFile authenticates the path it reads from. Swapping encrypted
+	// files between locations then fails to decrypt, but so does moving or
+	// renaming a file, which must be moved back to be decrypted.
+	BindToPath bool
+
+	// Longest time EncryptStream holds data read from its source before
+	// sealing it: once it has passed since the first byte of a chunk, the
+	// chunk is sealed into a frame even if it isn't full and the output is
+	// flushed if it has a Flush() error method (like a bufio.Writer). Bounds
+	// the latency of slow sources like telemetry. Disabled when 0.
+	FlushInterval time.Duration
+
+	// Maximum size in bytes of the plaintext to decrypt (0 means no limit).
+	// Streams are checked frame by frame and decryption fails with
+	// ErrTooLarge before the frame that would go over it is written, so
+	// output fed to a decompressor is bounded. Decrypt checks it too.
+	MaxOutputSize int64
+
+	// Compress the plaintext with gzip before sealing it, which saves space
+	// on compressible data since ciphertext can't be compressed. It's
+	// recorded in the header so Decrypt inflates it with nothing to set.
+	// Only supported by Encrypt (and the functions built on it), not by
+	// streams.
+	Compression bool
+
+	// Level Compression uses, from 1 (fastest) to 9 (smallest output), 0
+	// for gzip's default. Since 0 means the default, gzip's NoCompression
+	// level can't be selected: leave Compression off instead. The level
+	// isn't needed to decompress so it isn't recorded.
+	CompressionLevel int
+
+	// Make EncryptDir write every file to the top of the destination under
+	// an opaque name derived from its path (HMAC, base32) instead of
+	// recreating the tree, so names and layout aren't revealed. The path is
+	// sealed in the header of each file and DecryptDir restores the tree
+	// from it. Empty directories aren't kept.
+	ObfuscateNames bool
+
+	// Wrapper storing a random pepper for each file written by EncryptFile,
+	// mixed into the key of the file. Only a reference to the pepper is
+	// written next to the file (.pepper), so the disk alone isn't enough to
+	// decrypt it even with the passphrase. DecryptFile fetches the pepper of
+	// a file that has one, and fails if the wrapper isn't set or can't
+	// return it.
+	KeyWrapper KeyWrapper
+
+	// Number of files EncryptDir and DecryptDir process at once (0 or 1
+	// means one at a time). Errors are still reported in the order the
+	// files are walked.
+	Concurrency int
+
+	// Keep the name, permissions, modification time and size of the file
+	// in the header when EncryptFile encrypts it (authenticated with the
+	// data but not encrypted). When decrypting a file that has them,
+	// DecryptFile writes it under its original name with its permissions
+	// and modification time.
+	PreserveMetadata bool
+
+	// Function called with the number of bytes processed so far and the
+	// total (-1 if unknown): after each frame by the stream functions
+	// (EncryptStream, DecryptStream and the ones built on them) and after
+	// each file by EncryptDir and DecryptDir, with the size of the files.
+	// It's called on the goroutine doing the work, which may not be the
+	// caller's for directories, but never concurrently. It must return
+	// quickly as the work waits for it.
+	Progress func(done, total int64)
+
+	// Overwrite the file given to EncryptFile with random bytes and remove
+	// it once the encrypted file and everything next to it are written and
+	// synced (SyncOnClose is forced on). Nothing is shredded if any step
+	// fails. This only overwrites the blocks the file uses now: on
+	// copy-on-write or log-structured filesystems (btrfs, ZFS, APFS), on
+	// SSDs and flash (wear levelling) and with snapshots or backups, old
+	// copies of the data can survive. Use full disk encryption for those.
+	// Other hard links to the file see it overwritten too.
+	ShredSource bool
+
+	// Function checking the passphrase before anything is encrypted with
+	// it (ie. DefaultPasswordPolicy or MinLengthPolicy), encryption fails
+	// with the error it returns. Decryption never checks it, so data
+	// encrypted before a policy was set can still be read. No policy by
+	// default.
+	PasswordPolicy func(pass string) error
+
+	// Original path of a file sealed in its header, set by EncryptDir
+	name string
+
+	// Pepper mixed into the key, set by EncryptFile and DecryptFile
+	pepper []byte
+
+	// Metadata of the file kept in the header, set by EncryptFile
+	metadata *Metadata
+
+	// Size of the plaintext of a stream known up front, set by
+	// EncryptFileStream
+	length int64
+
+	// Cache of the keys derived from the passphrase, set by Encrypter
+	keys *keyCache
+
+	// Additional data authenticated by Encrypt and Decrypt, set internally
+	aad []byte
+
+	locked bool
+}
+
+// Function to get the current time from the clock in use
+func (o Options) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock()
+	}
+	return time.Now()
+}
+
+// Function to get the size of the plaintext sealed into each frame
+func (o Options) chunk() int {
+	if o.ChunkSize != 0 {
+		return o.ChunkSize
+	}
+	return chunkSize
+}
+
+// Function to set an option
+type Option func(*Options) error
+
+// Function to build Options from a list of Option
+//
+// Variables to pass in:
+//
+//   opts - Options to apply
+//
+// Returns:
+//
+//   Options - Options built
+//   error   - Error
+func NewOptions(opts ...Option) (Options, error) {
+	return newOptions(opts)
+}
+
+// Function to build Options from a list of Option
+//
+//   opts []Option - Options to apply
+func newOptions(opts []Option) (Options, error) {
+
+	o := defaultOptions()
+	if err := o.Apply(opts...); err != nil {
+		return Options{}, err
+	}
+
+	return o, nil
+
+}
+
+// Function to get the default Options
+func defaultOptions() Options {
+	return Options{
+		SyncOnClose: true,
+	}
+}
+
+// Function to change Options by applying a list of Option
+//
+// Returns ErrOptionsLocked without changing anything if the Options are
+// locked. Note that assigning to the fields directly bypasses this check.
+//
+// Variables to pass in:
+//
+//   opts - Options to apply
+//
+// Returns:
+//
+//   error - Error
+func (o *Options) Apply(opts ...Option) error {
+
+	if o.locked {
+		return ErrOptionsLocked
+	}
+
+	for _, opt := range opts {
+		if o.locked {
+			return ErrOptionsLocked
+		}
+		if err := opt(o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Function to get a locked copy of the Options
+//
+// The copy shares nothing with the original, so later changes to the
+// original don't affect it.
+//
+// Returns:
+//
+//   Options - Locked copy
+func (o Options) Lock() Options {
+	c := o.clone()
+	c.locked = true
+	return c
+}
+
+// Function to check if the Options are locked
+//
+// Returns:
+//
+//   bool - true if locked
+func (o Options) Locked() bool {
+	return o.locked
+}
+
+// Function to copy Options
+func (o Options) clone() Options {
+	if o.StreamID != nil {
+		o.StreamID = append([]byte(nil), o.StreamID...)
+	}
+	if o.pepper != nil {
+		o.pepper = append([]byte(nil), o.pepper...)
+	}
+	if o.metadata != nil {
+		m := *o.metadata
+		o.metadata = &m
+	}
+	return o
+}
+
+// Function to use a whole set of Options at once
+//
+// Options applied after it change a copy of o, unless o is locked in which
+// case they fail with ErrOptionsLocked. Build o with NewOptions to keep the
+// defaults for anything not set.
+//
+//   opts Options - Options to use
+func WithOptions(opts Options) Option {
+	return func(o *Options) error {
+		*o = opts.clone()
+		return nil
+	}
+}
+
+// Function to set the expected plaintext size used to preallocate output files
+//
+//   n int64 - Expected size of the plaintext in bytes
+func WithExpectedSize(n int64) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("expected size must not be negative")
+		}
+		o.ExpectedSize = n
+		return nil
+	}
+}
+
+// Function to compute a SHA-256 digest of the plaintext while encrypting a stream
+//
+//   on bool - true to compute the digest
+func WithDigest(on bool) Option {
+	return func(o *Options) error {
+		o.Digest = on
+		return nil
+	}
+}
+
+// Function to set the pool of buffers used for streaming
+//
+//   pool *sync.Pool - Pool of *[]byte buffers
+func WithBufferPool(pool *sync.Pool) Option {
+	return func(o *Options) error {
+		o.BufferPool = pool
+		return nil
+	}
+}
+
+// Function to set whether written files are flushed to disk before closing
+//
+//   on bool - true to sync files before closing them
+func WithSyncOnClose(on bool) Option {
+	return func(o *Options) error {
+		o.SyncOnClose = on
+		return nil
+	}
+}
+
+// Function to set the maximum number of scrypt derivations running at once
+//
+//   n int - Maximum number of derivations (0 means no limit)
+func WithMaxConcurrentKDF(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("max concurrent KDF must not be negative")
+		}
+		o.MaxConcurrentKDF = n
+		return nil
+	}
+}
+
+// Function to set the maximum number of frames to decrypt from a stream
+//
+//   n int - Maximum number of frames (0 means no limit)
+func WithMaxFrames(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("max frames must not be negative")
+		}
+		o.MaxFrames = n
+		return nil
+	}
+}
+
+// Function to add Reed-Solomon parity to the frames of a stream
+//
+//   data   int - Number of data shards each frame is split into
+//   shards int - Total number of shards per frame (data + parity)
+func WithParity(data, shards int) Option {
+	return func(o *Options) error {
+		p := Parity{Data: data, Shards: shards}
+		if err := p.validate(); err != nil {
+			return err
+		}
+		o.Parity = p
+		return nil
+	}
+}
+
+// Function to set where EncryptStream stores the salt
+//
+//   pos SaltPosition - Where to store the salt
+func WithSaltPosition(pos SaltPosition) Option {
+	return func(o *Options) error {
+		if pos < SaltDetached || pos > SaltTrailer {
+			return errors.New("invalid salt position")
+		}
+		o.SaltPosition = pos
+		return nil
+	}
+}
+
+// Function to derive frame nonces from a base stored in the header
+//
+//   on bool - Whether to derive the frame nonces
+func WithDerivedNonces(on bool) Option {
+	return func(o *Options) error {
+		o.DerivedNonces = on
+		return nil
+	}
+}
+
+// Function to lock DecryptFile out after too many failed attempts
+//
+//   attempts int           - Number of failed attempts allowed
+//   delay    time.Duration - Delay after the first failure, doubled after each
+func WithLockout(attempts int, delay time.Duration) Option {
+	return func(o *Options) error {
+		if attempts < 0 || delay < 0 {
+			return errors.New("lockout attempts and delay must not be negative")
+		}
+		o.MaxAttempts = attempts
+		o.AttemptDelay = delay
+		return nil
+	}
+}
+
+// Function to set the time before which a stream must not be decrypted
+//
+//   t time.Time - Activation time
+func WithNotBefore(t time.Time) Option {
+	return func(o *Options) error {
+		o.NotBefore = t
+		return nil
+	}
+}
+
+// Function to set the clock used to check NotBefore
+//
+//   clock func() time.Time - Function returning the current time
+func WithClock(clock func() time.Time) Option {
+	return func(o *Options) error {
+		o.Clock = clock
+		return nil
+	}
+}
+
+// Function to derive keys the way versions before the passphrase fix did
+//
+// Deprecated: only use it to read and migrate old data.
+//
+//   on bool - Whether to use the legacy key derivation
+func WithLegacyKeyDerivation(on bool) Option {
+	return func(o *Options) error {
+		o.LegacyKeyDerivation = on
+		return nil
+	}
+}
+
+// Function to leave holes in decrypted files where the plaintext is zeros
+//
+//   on bool - Whether to write sparse files
+func WithSparseOutput(on bool) Option {
+	return func(o *Options) error {
+		o.SparseOutput = on
+		return nil
+	}
+}
+
+// Function to set the purpose keys are derived for
+//
+//   purpose string - Purpose of the key
+func WithPurpose(purpose string) Option {
+	return func(o *Options) error {
+		if len(purpose) > maxPurposeSize {
+			return errors.New("purpose too long")
+		}
+		o.Purpose = purpose
+		return nil
+	}
+}
+
+// Function to encrypt streams in the minimal format
+//
+//   on bool - Whether to use the minimal format
+func WithMinimalOverhead(on bool) Option {
+	return func(o *Options) error {
+		o.MinimalOverhead = on
+		return nil
+	}
+}
+
+// Function to set the AEAD algorithm to encrypt with
+//
+//   alg Algorithm - Algorithm to use
+func WithCipher(alg Algorithm) Option {
+	return func(o *Options) error {
+		if alg != DefaultAlgorithm && !alg.valid() {
+			return fmt.Errorf("unsupported algorithm %d", byte(alg))
+		}
+		o.Cipher = alg
+		return nil
+	}
+}
+
+// Function to chain each frame of a stream to the previous one
+//
+//   on bool - Whether to hash chain the frames
+func WithHashChain(on bool) Option {
+	return func(o *Options) error {
+		o.HashChain = on
+		return nil
+	}
+}
+
+// Function to retry failed key derivations
+//
+//   n int - Number of retries
+func WithKDFRetries(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("KDF retries must not be negative")
+		}
+		o.KDFRetries = n
+		return nil
+	}
+}
+
+// Function to set the Metrics operations report to
+//
+//   m Metrics - Metrics to report to
+func WithMetrics(m Metrics) Option {
+	return func(o *Options) error {
+		o.Metrics = m
+		return nil
+	}
+}
+
+// Function to limit how long a key derivation may take
+//
+//   d time.Duration - Maximum duration of a derivation
+func WithMaxKDFDuration(d time.Duration) Option {
+	return func(o *Options) error {
+		if d < 0 {
+			return errors.New("max KDF duration must not be negative")
+		}
+		o.MaxKDFDuration = d
+		return nil
+	}
+}
+
+// Function to limit the memory a key derivation may use
+//
+//   n int64 - Maximum memory in bytes (0 for the default of 1 GiB)
+func WithMaxKDFMemory(n int64) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("max KDF memory must not be negative")
+		}
+		o.MaxKDFMemory = n
+		return nil
+	}
+}
+
+// Function to set the size of the plaintext sealed into each frame
+//
+//   n int - Chunk size in bytes
+func WithChunkSize(n int) Option {
+	return func(o *Options) error {
+		if n < minChunkSize || n > maxChunkSize {
+			return fmt.Errorf("chunk size must be between %d and %d bytes", minChunkSize, maxChunkSize)
+		}
+		o.ChunkSize = n
+		return nil
+	}
+}
+
+// Function to label a stream
+//
+//   comment string - Comment to store in the header
+func WithComment(comment string) Option {
+	return func(o *Options) error {
+		if err := validateComment(comment); err != nil {
+			return err
+		}
+		o.Comment = comment
+		return nil
+	}
+}
+
+// Function to allow encrypting files that are already encrypted
+//
+//   allow bool - Whether to allow double encryption
+func WithAllowDoubleEncrypt(allow bool) Option {
+	return func(o *Options) error {
+		o.AllowDoubleEncrypt = allow
+		return nil
+	}
+}
+
+// Function to set the cost parameters of the key derivation
+//
+//   p KDFParams - Parameters, zero fields take the defaults
+func WithKDFParams(p KDFParams) Option {
+	return func(o *Options) error {
+		if err := p.validate(); err != nil {
+			return err
+		}
+		o.KDF = p
+		return nil
+	}
+}
+
+// Function to treat decrypted data as text
+//
+//   on     bool       - Whether to use text mode
+//   ending LineEnding - Line ending style to normalize to
+func WithTextMode(on bool, ending LineEnding) Option {
+	return func(o *Options) error {
+		if ending < LineEndingNative || ending > LineEndingKeep {
+			return errors.New("invalid line ending style")
+		}
+		o.TextMode = on
+		o.LineEnding = ending
+		return nil
+	}
+}
+
+// Function to identify a stream
+//
+//   id []byte - Identifier to store in the header
+func WithStreamID(id []byte) Option {
+	return func(o *Options) error {
+		if len(id) > maxStreamIDSize {
+			return fmt.Errorf("stream id longer than %d bytes", maxStreamIDSize)
+		}
+		o.StreamID = append([]byte(nil), id...)
+		return nil
+	}
+}
+
+// Function to store a canary after the header of streams
+//
+//   on bool - Whether to store a canary
+func WithCanary(on bool) Option {
+	return func(o *Options) error {
+		o.Canary = on
+		return nil
+	}
+}
+
+// Function to write and check a detached MAC with EncryptFile and
+// DecryptFile
+//
+//   on bool - Whether to use a detached MAC
+func WithDetachedMAC(on bool) Option {
+	return func(o *Options) error {
+		o.DetachedMAC = on
+		return nil
+	}
+}
+
+// Function to bind files to their path with EncryptFile and DecryptFile
+//
+//   on bool - Whether to bind files to their path
+func WithBindToPath(on bool) Option {
+	return func(o *Options) error {
+		o.BindToPath = on
+		return nil
+	}
+}
+
+// Function to authenticate the path of a file with its data
+//
+//   path string - Path of the encrypted file
+func withPathAAD(path string) (Option, error) {
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(o *Options) error {
+		o.aad = []byte("gocrypt path " + abs)
+		return nil
+	}, nil
+
+}
+
+// Function to seal partial chunks of slow streams after an interval
+//
+//   d time.Duration - Longest time to hold data, 0 to disable
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *Options) error {
+		if d < 0 {
+			return errors.New("flush interval must not be negative")
+		}
+		o.FlushInterval = d
+		return nil
+	}
+}
+
+// Function to set the maximum size of the plaintext to decrypt
+//
+//   n int64 - Maximum size in bytes (0 means no limit)
+func WithMaxOutputSize(n int64) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("max output size must not be negative")
+		}
+		o.MaxOutputSize = n
+		return nil
+	}
+}
+
+// Function to compress the plaintext with gzip before encrypting it
+//
+//   on bool - Whether to compress
+func WithCompression(on bool) Option {
+	return func(o *Options) error {
+		o.Compression = on
+		return nil
+	}
+}
+
+// Function to set the level the plaintext is compressed at
+//
+//   level int - Level from 1 (fastest) to 9 (smallest output), 0 for the default
+func WithCompressionLevel(level int) Option {
+	return func(o *Options) error {
+		if level < 0 || level > gzip.BestCompression {
+			return fmt.Errorf("compression level must be between %d and %d (0 for the default)", gzip.BestSpeed, gzip.BestCompression)
+		}
+		o.CompressionLevel = level
+		return nil
+	}
+}
+
+// Function to obfuscate the names of the files written by EncryptDir
+//
+//   on bool - Whether to obfuscate the names
+func WithObfuscateNames(on bool) Option {
+	return func(o *Options) error {
+		o.ObfuscateNames = on
+		return nil
+	}
+}
+
+// Function to seal the original path of a file in its header
+//
+//   name string - Path of the file, relative to the directory encrypted
+func withName(name string) Option {
+	return func(o *Options) error {
+		o.name = name
+		return nil
+	}
+}
+
+// Function to store a pepper for each file with a KeyWrapper
+//
+//   kw KeyWrapper - Wrapper to store the peppers with (nil to disable)
+func WithKeyWrapper(kw KeyWrapper) Option {
+	return func(o *Options) error {
+		o.KeyWrapper = kw
+		return nil
+	}
+}
+
+// Function to mix a pepper into the key
+//
+//   pepper []byte - Pepper of the file
+func withPepper(pepper []byte) Option {
+	return func(o *Options) error {
+		o.pepper = pepper
+		return nil
+	}
+}
+
+// Function to set the number of files EncryptDir and DecryptDir process at
+// once
+//
+//   n int - Number of files (0 or 1 means one at a time)
+func WithConcurrency(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("concurrency must not be negative")
+		}
+		o.Concurrency = n
+		return nil
+	}
+}
+
+// Function to keep the metadata of files encrypted by EncryptFile and
+// restore it when they are decrypted by DecryptFile
+//
+//   on bool - Whether to keep and restore the metadata
+func WithPreserveMetadata(on bool) Option {
+	return func(o *Options) error {
+		o.PreserveMetadata = on
+		return nil
+	}
+}
+
+// Function to keep the metadata of a file in the header
+//
+//   m *Metadata - Metadata of the file
+func withMetadata(m *Metadata) Option {
+	return func(o *Options) error {
+		o.metadata = m
+		return nil
+	}
+}
+
+// Function to record the size of the plaintext of a stream in its header
+//
+//   n int64 - Size of the plaintext in bytes
+func withLength(n int64) Option {
+	return func(o *Options) error {
+		o.length = n
+		return nil
+	}
+}
+
+// Function to report progress to a callback
+//
+//   fn func(done, total int64) - Callback (nil to disable)
+func WithProgress(fn func(done, total int64)) Option {
+	return func(o *Options) error {
+		o.Progress = fn
+		return nil
+	}
+}
+
+// Function to overwrite and remove the source of EncryptFile once it's
+// encrypted
+//
+//   on bool - Whether to shred the source
+func WithShredSource(on bool) Option {
+	return func(o *Options) error {
+		o.ShredSource = on
+		return nil
+	}
+}
+
+// Function to set the policy passphrases must pass to encrypt
+//
+//   policy func(pass string) error - Policy (nil to accept any passphrase)
+func WithPasswordPolicy(policy func(pass string) error) Option {
+	return func(o *Options) error {
+		o.PasswordPolicy = policy
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package gocrypt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOptionsLock(t *testing.T) {
+
+	o, err := NewOptions(WithComment("before"), WithStreamID([]byte("id")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := o.Lock()
+	if !locked.Locked() || o.Locked() {
+		t.Fatal("Lock didn't return a locked copy")
+	}
+
+	if err := locked.Apply(WithComment("after")); !errors.Is(err, ErrOptionsLocked) {
+		t.Fatalf("got %v, want ErrOptionsLocked", err)
+	}
+	if _, err := NewOptions(WithOptions(locked), WithComment("after")); !errors.Is(err, ErrOptionsLocked) {
+		t.Fatalf("got %v, want ErrOptionsLocked", err)
+	}
+
+	// Changing the source doesn't reach the frozen copy
+	if err := o.Apply(WithComment("after")); err != nil {
+		t.Fatal(err)
+	}
+	o.StreamID[0] = 'X'
+	if locked.Comment != "before" || string(locked.StreamID) != "id" {
+		t.Fatalf("locked copy changed: %q, %q", locked.Comment, locked.StreamID)
+	}
+
+}
@@ -0,0 +1,259 @@
+package gocrypt
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Error returned when a file isn't in a pack
+var ErrNotInPack = errors.New("file not in pack")
+
+// Name of the entry listing the files of a pack, always the first one
+const packIndexName = ".gocrypt-index"
+
+// Details of a pack written by EncryptPacks
+type PackInfo struct {
+	Path  string   // Path of the pack
+	Files []string // Names of the files in the pack, in order
+	Size  int64    // Size of the files in bytes
+}
+
+// Function to encrypt many small files into a few packs of about the same
+// size
+//
+// Files are taken in order and added to the current pack until the next
+// one would take it over targetPackBytes, then a new pack is started. A
+// file larger than the target gets a pack of its own. Each pack is a tar
+// archive encrypted as a stream with its salt in the header, so it can be
+// decrypted on its own. Its first entry is an index of the files in it.
+// Files are stored under their base name, which must be unique. On error
+// the packs written so far are removed.
+//
+// Variables to pass in:
+//
+//   paths []string        - Paths of the files to pack
+//   outDir string         - Directory to write the packs to
+//   targetPackBytes int64 - Size to aim for for each pack
+//   pass string           - Passphrase to use for encryption
+//   opts                  - Options (optional)
+//
+// Returns:
+//
+//   []PackInfo - Packs written and the files they hold
+//   error      - Error
+func EncryptPacks(paths []string, outDir string, targetPackBytes int64, pass string, opts ...Option) ([]PackInfo, error) {
+
+	if targetPackBytes <= 0 {
+		return nil, errors.New("encrypt packs: target pack size must be positive")
+	}
+
+	type packFile struct {
+		path string
+		size int64
+	}
+
+	var packs []PackInfo
+	var files [][]packFile
+	var current int64
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+
+		name := filepath.Base(path)
+		if name == packIndexName || strings.ContainsAny(name, "\n\r") || seen[name] {
+			return nil, fmt.Errorf("encrypt packs: invalid or duplicate file name %q", name)
+		}
+		seen[name] = true
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt packs: stat: %w", err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("encrypt packs: %s is not a regular file", path)
+		}
+
+		// Each tar entry is a 512 byte header and the data padded to 512
+		entry := 512 + (fi.Size()+511)/512*512
+		if len(packs) == 0 || (current > 0 && current+entry > targetPackBytes) {
+			packs = append(packs, PackInfo{Path: filepath.Join(outDir, fmt.Sprintf("pack-%04d.3dfx", len(packs)))})
+			files = append(files, nil)
+			current = 0
+		}
+
+		p := &packs[len(packs)-1]
+		p.Files = append(p.Files, name)
+		p.Size += fi.Size()
+		files[len(files)-1] = append(files[len(files)-1], packFile{path, fi.Size()})
+		current += entry
+
+	}
+
+	// The packs are only returned all together, so none are left on error
+	remove := func(n int) {
+		for _, p := range packs[:n] {
+			os.Remove(p.Path)
+		}
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithSaltPosition(SaltHeader))
+	for i, p := range packs {
+
+		f, err := os.Create(p.Path)
+		if err != nil {
+			remove(i)
+			return nil, fmt.Errorf("encrypt packs: create file: %w", err)
+		}
+
+		pr, pw := io.Pipe()
+		go func(files []packFile) {
+			tw := tar.NewWriter(pw)
+			var index strings.Builder
+			for _, pf := range files {
+				fmt.Fprintf(&index, "%d %s\n", pf.size, filepath.Base(pf.path))
+			}
+			err := tw.WriteHeader(&tar.Header{Name: packIndexName, Mode: 0600, Size: int64(index.Len())})
+			if err == nil {
+				_, err = io.WriteString(tw, index.String())
+			}
+			for _, pf := range files {
+				if err != nil {
+					break
+				}
+				err = writePackEntry(tw, pf.path, pf.size)
+			}
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}(files[i])
+
+		_, err = EncryptStream(f, pr, pass, opts...)
+		// Unblock the tar writer if encryption stopped early
+		pr.CloseWithError(err)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			remove(i + 1)
+			return nil, fmt.Errorf("encrypt packs: encrypt: %w", err)
+		}
+
+	}
+
+	return packs, nil
+
+}
+
+// Function to write a file as an entry of a pack
+//
+//   tw   *tar.Writer - Writer to add the entry to
+//   path string      - Path of the file
+//   size int64       - Size of the file when it was planned
+func writePackEntry(tw *tar.Writer, path string, size int64) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := tw.WriteHeader(&tar.Header{Name: filepath.Base(path), Mode: 0600, Size: size}); err != nil {
+		return err
+	}
+
+	// A file changing size since it was planned fails instead of silently
+	// leaving the pack inconsistent with its index
+	if _, err := io.CopyN(tw, f, size); err != nil {
+		return err
+	}
+	if n, _ := f.Read(make([]byte, 1)); n != 0 {
+		return fmt.Errorf("%s grew while being packed", path)
+	}
+
+	return nil
+
+}
+
+// Function to extract a file from a pack written by EncryptPacks
+//
+// The pack is decrypted up to the end of the file only. The index is read
+// first, so a file that isn't in the pack is reported without decrypting
+// the rest.
+//
+// Variables to pass in:
+//
+//   packPath string - Path of the pack
+//   name string     - Name of the file in the pack
+//   pass string     - Passphrase to use for decryption
+//   opts            - Options (optional)
+//
+// Returns:
+//
+//   []byte - Content of the file
+//   error  - Error (ErrNotInPack if the file isn't in the pack)
+func ExtractFromPack(packPath, name, pass string, opts ...Option) ([]byte, error) {
+
+	f, err := os.Open(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("extract from pack: open file: %w", err)
+	}
+	defer f.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(DecryptStream(pw, f, nil, pass, opts...))
+	}()
+	// Stop the decryption once done with it
+	defer pr.Close()
+
+	tr := tar.NewReader(pr)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("extract from pack: index: %w", err)
+	}
+	if hdr.Name != packIndexName {
+		return nil, errors.New("extract from pack: not a pack: missing index")
+	}
+
+	found := false
+	sc := bufio.NewScanner(tr)
+	for sc.Scan() {
+		if _, file, ok := strings.Cut(sc.Text(), " "); ok && file == name {
+			found = true
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("extract from pack: index: %w", err)
+	}
+	if !found {
+		return nil, ErrNotInPack
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrNotInPack
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extract from pack: read: %w", err)
+		}
+		if hdr.Name != name {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("extract from pack: read: %w", err)
+		}
+		return data, nil
+	}
+
+}
@@ -0,0 +1,122 @@
+package gocrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPacks(t *testing.T) {
+
+	const target = 50000
+	src, out := t.TempDir(), t.TempDir()
+
+	var paths []string
+	want := make(map[string][]byte)
+	for i := 0; i < 20; i++ {
+		size := 1000 * (i + 1)
+		if i == 10 {
+			size = 2 * target
+		}
+		data := make([]byte, size)
+		rand.Read(data)
+		name := fmt.Sprintf("file%02d", i)
+		writeTestFile(t, filepath.Join(src, name), string(data))
+		paths = append(paths, filepath.Join(src, name))
+		want[name] = data
+	}
+
+	packs, err := EncryptPacks(paths, out, target, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range packs {
+		// Only a file larger than the target goes over it, on its own
+		var entries int64
+		for _, name := range p.Files {
+			entries += 512 + (int64(len(want[name]))+511)/512*512
+		}
+		if entries > target && len(p.Files) > 1 {
+			t.Fatalf("%s: %d bytes of entries in %d files, over the target", p.Path, entries, len(p.Files))
+		}
+		for _, name := range p.Files {
+			names = append(names, name)
+			got, err := ExtractFromPack(p.Path, name, "pass")
+			if err != nil || !bytes.Equal(got, want[name]) {
+				t.Fatalf("%s in %s: got %d bytes, %v, want %d", name, p.Path, len(got), err, len(want[name]))
+			}
+		}
+	}
+	if len(names) != len(paths) || len(packs) < 5 {
+		t.Fatalf("got %d files in %d packs", len(names), len(packs))
+	}
+	for i, name := range names {
+		if name != filepath.Base(paths[i]) {
+			t.Fatalf("got %s at %d, want the files in order", name, i)
+		}
+	}
+
+	if _, err := ExtractFromPack(packs[0].Path, "missing", "pass"); !errors.Is(err, ErrNotInPack) {
+		t.Fatalf("got %v, want ErrNotInPack", err)
+	}
+	if _, err := ExtractFromPack(packs[0].Path, packs[0].Files[0], "wrong"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+	if _, err := EncryptPacks(append(paths, paths[0]), out, target, "pass", testKDF); err == nil {
+		t.Fatal("packed a file name twice")
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != len(packs) {
+		t.Fatalf("got %d files in the output, want %d packs", len(entries), len(packs))
+	}
+
+}
+
+func TestPacksFailure(t *testing.T) {
+
+	src, out := t.TempDir(), t.TempDir()
+	var paths []string
+	for i := 0; i < 4; i++ {
+		writeTestFile(t, filepath.Join(src, fmt.Sprint(i)), strings.Repeat("x", 1000))
+		paths = append(paths, filepath.Join(src, fmt.Sprint(i)))
+	}
+
+	// The second pack fails, the first one is removed along with it
+	calls := 0
+	policy := WithPasswordPolicy(func(string) error {
+		if calls++; calls > 1 {
+			return errors.New("refused")
+		}
+		return nil
+	})
+	_, err := EncryptPacks(paths, out, 2000, "pass", testKDF, policy)
+	if err == nil || !strings.HasPrefix(err.Error(), "encrypt packs: ") {
+		t.Fatalf("got %v, want an encrypt packs error", err)
+	}
+	if calls != 2 {
+		t.Fatalf("got %d packs started, want 2", calls)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Fatalf("got %d files left in the output, want none", len(entries))
+	}
+
+	// Errors reading the pack carry the prefix too
+	var buf bytes.Buffer
+	if _, err := EncryptStream(&buf, strings.NewReader("not a tar archive"), "pass", testKDF, WithSaltPosition(SaltHeader)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "bad.3dfx"), buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExtractFromPack(filepath.Join(out, "bad.3dfx"), "0", "pass")
+	if err == nil || !strings.HasPrefix(err.Error(), "extract from pack: ") {
+		t.Fatalf("got %v, want an extract from pack error", err)
+	}
+
+}
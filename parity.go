@@ -0,0 +1,194 @@
+package gocrypt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Number of copies of the frame length kept in front of each parity block
+const parityLengthCopies = 3
+
+// Reed-Solomon parity settings for a stream
+//
+// Each encrypted frame is split into Data shards and Shards-Data parity
+// shards are added, so up to Shards-Data corrupted or lost shards per frame
+// can be recovered before decryption.
+type Parity struct {
+	Data   int // Number of data shards each frame is split into
+	Shards int // Total number of shards per frame (data + parity)
+}
+
+// Function to check if parity is enabled
+func (p Parity) enabled() bool {
+	return p.Shards > 0
+}
+
+// Function to validate parity settings
+func (p Parity) validate() error {
+	if p.Data < 1 || p.Shards <= p.Data || p.Shards > 255 {
+		return errors.New("invalid parity: need 1 <= Data < Shards <= 255")
+	}
+	return nil
+}
+
+// Writer adding parity to each frame written to it.
+//
+// Every call to Write must be given exactly one whole frame.
+type parityWriter struct {
+	w   io.Writer
+	p   Parity
+	enc reedsolomon.Encoder
+}
+
+// Function to create a parityWriter
+//
+//   w io.Writer - Writer to output the parity blocks to
+//   p Parity    - Parity settings
+func newParityWriter(w io.Writer, p Parity) (*parityWriter, error) {
+
+	enc, err := reedsolomon.New(p.Data, p.Shards-p.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parityWriter{w: w, p: p, enc: enc}, nil
+
+}
+
+// Function to get the size of the parity block of a frame
+//
+//   frame int64 - Size of the frame, its header included
+func (p Parity) blockSize(frame int64) int64 {
+	shard := (frame + int64(p.Data) - 1) / int64(p.Data)
+	return 8*parityLengthCopies + int64(p.Shards)*(4+shard)
+}
+
+// Function to write a frame as a parity block.
+//
+// A block is the frame length (with its CRC-32, repeated) followed by every
+// shard with its CRC-32, so corrupted shards can be told apart.
+func (pw *parityWriter) Write(frame []byte) (int, error) {
+
+	shards, err := pw.enc.Split(frame[:len(frame):len(frame)])
+	if err != nil {
+		return 0, err
+	}
+	if err := pw.enc.Encode(shards); err != nil {
+		return 0, err
+	}
+
+	block := make([]byte, 0, 8*parityLengthCopies+len(shards)*(4+len(shards[0])))
+	for i := 0; i < parityLengthCopies; i++ {
+		block = appendUint32(block, uint32(len(frame)))
+		block = appendUint32(block, crc32.ChecksumIEEE(block[len(block)-4:]))
+	}
+	for _, shard := range shards {
+		block = appendUint32(block, crc32.ChecksumIEEE(shard))
+		block = append(block, shard...)
+	}
+
+	if _, err := pw.w.Write(block); err != nil {
+		return 0, err
+	}
+
+	return len(frame), nil
+
+}
+
+// Reader recovering frames from parity blocks
+type parityReader struct {
+	r        io.Reader
+	p        Parity
+	enc      reedsolomon.Encoder
+	maxFrame int
+	buf      []byte
+}
+
+// Function to create a parityReader
+//
+//   r        io.Reader - Reader to get the parity blocks from
+//   p        Parity    - Parity settings
+//   maxFrame int       - Maximum size of a frame
+func newParityReader(r io.Reader, p Parity, maxFrame int) (*parityReader, error) {
+
+	enc, err := reedsolomon.New(p.Data, p.Shards-p.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parityReader{r: r, p: p, enc: enc, maxFrame: maxFrame}, nil
+
+}
+
+// Function to read recovered frame bytes
+func (pr *parityReader) Read(b []byte) (int, error) {
+
+	if len(pr.buf) == 0 {
+		if err := pr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, pr.buf)
+	pr.buf = pr.buf[n:]
+
+	return n, nil
+
+}
+
+// Function to read the next parity block and recover its frame
+func (pr *parityReader) next() error {
+
+	lengths := make([]byte, 8*parityLengthCopies)
+	if _, err := io.ReadFull(pr.r, lengths); err != nil {
+		return err
+	}
+
+	// Use the first copy of the frame length that isn't corrupted
+	size := -1
+	for i := 0; i < parityLengthCopies; i++ {
+		l := lengths[8*i : 8*i+8]
+		if crc32.ChecksumIEEE(l[:4]) == binary.BigEndian.Uint32(l[4:]) {
+			size = int(binary.BigEndian.Uint32(l[:4]))
+			break
+		}
+	}
+	if size < 0 || size > pr.maxFrame {
+		return errors.New("parity: frame length unrecoverable")
+	}
+
+	shardSize := (size + pr.p.Data - 1) / pr.p.Data
+	block := make([]byte, pr.p.Shards*(4+shardSize))
+	if _, err := io.ReadFull(pr.r, block); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	shards := make([][]byte, pr.p.Shards)
+	for i := range shards {
+		s := block[i*(4+shardSize) : (i+1)*(4+shardSize)]
+		if crc32.ChecksumIEEE(s[4:]) == binary.BigEndian.Uint32(s[:4]) {
+			shards[i] = s[4:]
+		}
+	}
+
+	if err := pr.enc.ReconstructData(shards); err != nil {
+		return fmt.Errorf("parity: too many %w shards to recover frame", ErrCorrupted)
+	}
+
+	frame := make([]byte, 0, size)
+	for _, shard := range shards[:pr.p.Data] {
+		frame = append(frame, shard...)
+	}
+	pr.buf = frame[:size]
+
+	return nil
+
+}
@@ -0,0 +1,52 @@
+package gocrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestParity(t *testing.T) {
+
+	data := bytes.Repeat([]byte("parity!"), 3000)
+	p := Parity{Data: 4, Shards: 6}
+	ct, salt := encryptTestStream(t, data, WithParity(p.Data, p.Shards))
+
+	// Shards of the first frame, after the header and the copies of its
+	// length
+	_, raw, err := readHeader(bytes.NewReader(ct))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := len(raw)
+	size := int(binary.BigEndian.Uint32(ct[block:]))
+	shardSize := (size + p.Data - 1) / p.Data
+	shard := func(i int) int {
+		return block + 8*parityLengthCopies + i*(4+shardSize) + 10
+	}
+
+	// Up to Shards-Data corrupted shards are recovered
+	b := append([]byte(nil), ct...)
+	b[shard(0)] ^= 0xff
+	b[shard(3)] ^= 0xff
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(b), salt, "pass"); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("2 corrupted shards not recovered: %v", err)
+	}
+
+	// One more fails cleanly
+	b[shard(5)] ^= 0xff
+	out.Reset()
+	if err := DecryptStream(&out, bytes.NewReader(b), salt, "pass"); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("got %v, want ErrCorrupted", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("wrote %d bytes of an unrecoverable frame", out.Len())
+	}
+
+	if _, err := NewOptions(WithParity(4, 4)); err == nil {
+		t.Fatal("parity without parity shards accepted")
+	}
+
+}
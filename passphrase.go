@@ -0,0 +1,154 @@
+package gocrypt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Error returned by the built-in passphrase policies when a passphrase is
+// rejected, see Options.PasswordPolicy
+var ErrWeakPassphrase = errors.New("weak passphrase")
+
+// Minimum number of characters required by DefaultPasswordPolicy
+const defaultMinPassphraseLength = 12
+
+// Function to build a passphrase policy requiring a minimum length
+//
+// The length is counted in characters, not bytes. An empty passphrase is
+// always rejected, even with n set to 0.
+//
+// Variables to pass in:
+//
+//   n int - Minimum number of characters
+//
+// Returns:
+//
+//   func(pass string) error - Policy to give to WithPasswordPolicy
+func MinLengthPolicy(n int) func(pass string) error {
+	return func(pass string) error {
+		if pass == "" {
+			return fmt.Errorf("%w: empty", ErrWeakPassphrase)
+		}
+		if utf8.RuneCountInString(pass) < n {
+			return fmt.Errorf("%w: shorter than %d characters", ErrWeakPassphrase, n)
+		}
+		return nil
+	}
+}
+
+// Function to check a passphrase against the built-in policy: not empty,
+// at least 12 characters and not only whitespace
+//
+// Variables to pass in:
+//
+//   pass string - Passphrase to check
+//
+// Returns:
+//
+//   error - Error (wrapping ErrWeakPassphrase if rejected)
+func DefaultPasswordPolicy(pass string) error {
+
+	if err := MinLengthPolicy(defaultMinPassphraseLength)(pass); err != nil {
+		return err
+	}
+	if strings.TrimSpace(pass) == "" {
+		return fmt.Errorf("%w: only whitespace", ErrWeakPassphrase)
+	}
+
+	return nil
+
+}
+
+// Function to check a passphrase against the policy set in Options, if any
+//
+//   pass string - Passphrase to encrypt with
+func (o Options) checkPassphrase(pass string) error {
+
+	if o.PasswordPolicy == nil {
+		return nil
+	}
+	if err := o.PasswordPolicy(pass); err != nil {
+		return fmt.Errorf("passphrase policy: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to read a passphrase from stdin
+//
+// When stdin is a terminal the prompt is written to stderr and the
+// passphrase is read without echo. Otherwise (stdin is a pipe or a file) a
+// single line is read without prompting, so the same tool works both
+// interactively and in pipelines. Only the passphrase line is consumed,
+// anything after it is left on stdin.
+//
+// Variables to pass in:
+//
+//   prompt string - Prompt to show when stdin is a terminal
+//
+// Returns:
+//
+//   string - Passphrase
+//   error  - Error
+func ReadPassphrase(prompt string) (string, error) {
+	return readPassphrase(os.Stdin, os.Stderr, prompt)
+}
+
+// Function to read a passphrase from a file, prompting if it's a terminal
+//
+//   in     *os.File  - File to read from
+//   out    io.Writer - Writer to show the prompt on
+//   prompt string    - Prompt to show
+func readPassphrase(in *os.File, out io.Writer, prompt string) (string, error) {
+
+	fd := int(in.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(out, prompt)
+		pass, err := term.ReadPassword(fd)
+		fmt.Fprintln(out)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase: terminal: %w", err)
+		}
+		return string(pass), nil
+	}
+
+	return readLine(in)
+
+}
+
+// Function to read a single line without reading past it
+//
+//   r io.Reader - Reader to read from
+func readLine(r io.Reader) (string, error) {
+
+	var line strings.Builder
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line.WriteByte(b[0])
+		}
+		if err == io.EOF {
+			if line.Len() == 0 {
+				return "", errors.New("no passphrase on input")
+			}
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read passphrase: read: %w", err)
+		}
+	}
+
+	return strings.TrimSuffix(line.String(), "\r"), nil
+
+}
@@ -0,0 +1,104 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// The terminal path can't run without a TTY: it prompts on out and reads
+// without echo through golang.org/x/term.
+func TestReadPassphrasePipe(t *testing.T) {
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		io.WriteString(w, "first pass\r\nrest of input\n")
+		w.Close()
+	}()
+
+	var prompt bytes.Buffer
+	pass, err := readPassphrase(r, &prompt, "Passphrase: ")
+	if err != nil || pass != "first pass" {
+		t.Fatalf("got %q, %v", pass, err)
+	}
+	if prompt.Len() != 0 {
+		t.Fatalf("prompted %q on a pipe", prompt.String())
+	}
+
+	// Only the passphrase line is consumed
+	rest, err := io.ReadAll(r)
+	if err != nil || string(rest) != "rest of input\n" {
+		t.Fatalf("left %q, %v", rest, err)
+	}
+
+}
+
+func TestReadPassphraseEmpty(t *testing.T) {
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.Close()
+
+	if _, err := readPassphrase(r, io.Discard, ""); err == nil {
+		t.Fatal("read a passphrase from empty input")
+	}
+
+}
+
+func TestPasswordPolicy(t *testing.T) {
+
+	policy := MinLengthPolicy(3)
+	for pass, ok := range map[string]bool{"": false, "ab": false, "abc": true, "ééé": true, "éé": false} {
+		if err := policy(pass); (err == nil) != ok {
+			t.Fatalf("%q: got %v", pass, err)
+		}
+	}
+	if err := MinLengthPolicy(0)(""); !errors.Is(err, ErrWeakPassphrase) {
+		t.Fatalf("got %v, want an empty passphrase rejected", err)
+	}
+	for pass, ok := range map[string]bool{"short": false, "            ": false, "long enough pass": true} {
+		if err := DefaultPasswordPolicy(pass); (err == nil) != ok {
+			t.Fatalf("%q: got %v", pass, err)
+		}
+	}
+
+	// A weak passphrase is refused before anything is encrypted
+	strict := WithPasswordPolicy(DefaultPasswordPolicy)
+	if _, _, err := Encrypt([]byte("data"), "weak", testKDF, strict); !errors.Is(err, ErrWeakPassphrase) {
+		t.Fatalf("got %v, want ErrWeakPassphrase", err)
+	}
+	var buf bytes.Buffer
+	if _, err := EncryptStream(&buf, bytes.NewReader([]byte("data")), "weak", testKDF, strict); !errors.Is(err, ErrWeakPassphrase) {
+		t.Fatalf("got %v, want ErrWeakPassphrase", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("got %d bytes written for a weak passphrase", buf.Len())
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, src+"/a.txt", "data")
+	if err := EncryptDir(src, dst, "weak", testKDF, strict); !errors.Is(err, ErrWeakPassphrase) {
+		t.Fatalf("got %v, want ErrWeakPassphrase", err)
+	}
+	if entries, _ := os.ReadDir(dst); len(entries) != 0 {
+		t.Fatalf("got %d entries written for a weak passphrase", len(entries))
+	}
+
+	// Decryption doesn't check it, data from before the policy still opens
+	ct, salt, err := Encrypt([]byte("data"), "weak", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := Decrypt(ct, salt, "weak", testKDF, strict); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+}
@@ -0,0 +1,66 @@
+package gocrypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Wrapper keeping secrets outside of the disk, like a KMS or a secret
+// manager, see Options.KeyWrapper
+type KeyWrapper interface {
+	// Store a key and return a reference to it. The reference is stored
+	// next to the encrypted file, so it must be useless without the
+	// wrapper (ie. a KMS ciphertext or the name of a secret).
+	WrapKey(key []byte) ([]byte, error)
+
+	// Get back the key a reference returned by WrapKey points to
+	UnwrapKey(ref []byte) ([]byte, error)
+}
+
+// Size in bytes of the pepper of a file
+const pepperSize = 32
+
+// Function to get the path of the pepper reference of a file
+//
+//   path string - Path of the encrypted file
+func pepperPath(path string) string {
+	return path + ".pepper"
+}
+
+// Function to generate a pepper and wrap it
+//
+//   kw KeyWrapper - Wrapper to store the pepper with
+func newPepper(kw KeyWrapper) ([]byte, []byte, error) {
+
+	pepper := make([]byte, pepperSize)
+	if _, err := io.ReadFull(rand.Reader, pepper); err != nil {
+		return nil, nil, err
+	}
+
+	ref, err := kw.WrapKey(pepper)
+	if err != nil {
+		Scrub(pepper)
+		return nil, nil, err
+	}
+
+	return pepper, ref, nil
+
+}
+
+// Function to mix a pepper into the key derived from the passphrase
+//
+//   master []byte - Key derived from the passphrase
+//   pepper []byte - Pepper of the file
+func pepperKey(master, pepper []byte) ([]byte, error) {
+
+	key := make([]byte, len(master))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, pepper, []byte("gocrypt pepper")), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+
+}
@@ -0,0 +1,91 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// KeyWrapper keeping the keys it wraps in memory, like a KMS would
+type fakeKMS struct {
+	keys map[string][]byte
+	down bool
+}
+
+func (k *fakeKMS) WrapKey(key []byte) ([]byte, error) {
+	ref := fmt.Sprintf("key-%d", len(k.keys))
+	k.keys[ref] = append([]byte(nil), key...)
+	return []byte(ref), nil
+}
+
+func (k *fakeKMS) UnwrapKey(ref []byte) ([]byte, error) {
+	if k.down {
+		return nil, errors.New("kms unavailable")
+	}
+	key, ok := k.keys[string(ref)]
+	if !ok {
+		return nil, errors.New("unknown key")
+	}
+	return append([]byte(nil), key...), nil
+}
+
+func TestPepper(t *testing.T) {
+
+	src, dst, out := t.TempDir()+"/", t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "data")
+	kms := &fakeKMS{keys: make(map[string][]byte)}
+	if err := EncryptFile("a.txt", src, dst, "pass", testKDF, WithKeyWrapper(kms)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the reference is on disk, the pepper stays in the KMS
+	ref, err := os.ReadFile(pepperPath(dst + "a.txt.3dfx"))
+	if err != nil || string(ref) != "key-0" {
+		t.Fatalf("got %q, %v, want the reference", ref, err)
+	}
+	pepper := kms.keys["key-0"]
+	for _, ext := range []string{".3dfx", ".salt", ".3dfx.pepper"} {
+		data, err := os.ReadFile(dst + "a.txt" + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, pepper) {
+			t.Fatalf("pepper found in %s", ext)
+		}
+	}
+
+	// The passphrase and salt alone aren't enough
+	ct, err := os.ReadFile(dst + "a.txt.3dfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt, err := os.ReadFile(dst + "a.txt.salt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ct, salt, "pass", testKDF); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF); err == nil {
+		t.Fatal("got nil, want an error without a KeyWrapper")
+	}
+
+	kms.down = true
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, WithKeyWrapper(kms)); err == nil {
+		t.Fatal("got nil, want an error with the KMS unavailable")
+	}
+	if _, err := os.Stat(out + "a.txt"); err == nil {
+		t.Fatal("file written without the pepper")
+	}
+
+	kms.down = false
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, WithKeyWrapper(kms)); err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := os.ReadFile(out + "a.txt"); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+}
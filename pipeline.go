@@ -0,0 +1,128 @@
+package gocrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Writer encrypting what is written to it as a stream, see NewEncryptWriter
+type encryptWriter struct {
+	fw     *frameWriter
+	n      int // Bytes buffered in fw.plain
+	err    error
+	closed bool
+}
+
+// Function to encrypt data as it's written
+//
+// The data is sealed in frames like EncryptStream with the salt stored in
+// the stream (SaltHeader unless Options.SaltPosition says SaltTrailer), so
+// the output is decrypted with NewDecryptReader or DecryptStream with only
+// the passphrase. Up to one chunk is held back until the next write or
+// Close, which seals the final frame: the stream isn't complete until Close
+// returns without an error. Closing doesn't close w.
+//
+// Variables to pass in:
+//
+//   w io.Writer - Writer to output the encrypted stream to
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   io.WriteCloser - Writer to write the plaintext to
+//   error          - Error
+func NewEncryptWriter(w io.Writer, pass string, opts ...Option) (io.WriteCloser, error) {
+
+	o, err := newOptions(append([]Option{WithSaltPosition(SaltHeader)}, opts...))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt writer: options: %w", err)
+	}
+	if o.SaltPosition == SaltDetached || o.MinimalOverhead {
+		return nil, errors.New("encrypt writer: the salt must be stored in the stream")
+	}
+
+	fw, err := newFrameWriter(context.Background(), w, w, pass, o.SaltPosition, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{fw: fw}, nil
+
+}
+
+// Function to encrypt data
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+
+	if ew.closed {
+		return 0, errors.New("encrypt writer: write after close")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if ew.err != nil {
+			return written, ew.err
+		}
+		// A full chunk is only sealed once more data shows it isn't the
+		// last one
+		if ew.n == len(ew.fw.plain) {
+			ew.err = ew.fw.writeFrame(ew.fw.plain, false)
+			ew.n = 0
+			continue
+		}
+		m := copy(ew.fw.plain[ew.n:], p)
+		ew.n += m
+		p = p[m:]
+		written += m
+	}
+
+	return written, nil
+
+}
+
+// Function to seal the final frame and release the writer
+func (ew *encryptWriter) Close() error {
+
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	defer ew.fw.close()
+
+	if ew.err != nil {
+		return ew.err
+	}
+
+	return ew.fw.writeFrame(ew.fw.plain[:ew.n], true)
+
+}
+
+// Function to decrypt a stream as it's read
+//
+// The stream must hold its salt, like the output of NewEncryptWriter. Data
+// is only returned once the frame holding it has been authenticated, and a
+// truncated stream fails instead of returning io.EOF. The reader is a
+// *Decryptor.
+//
+// Variables to pass in:
+//
+//   r io.Reader - Reader to get the encrypted stream from
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   io.Reader - Reader to read the plaintext from
+//   error     - Error
+func NewDecryptReader(r io.Reader, pass string, opts ...Option) (io.Reader, error) {
+
+	d, err := NewDecryptor(r, nil, pass, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+
+}
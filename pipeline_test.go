@@ -0,0 +1,127 @@
+package gocrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// Function to write data to a NewEncryptWriter in uneven pieces
+func writePieces(t *testing.T, w io.Writer, data []byte) {
+	t.Helper()
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		if m, err := w.Write(data[:n]); err != nil || m != n {
+			t.Fatalf("wrote %d of %d bytes: %v", m, n, err)
+		}
+		data = data[n:]
+	}
+}
+
+func TestEncryptWriter(t *testing.T) {
+
+	data := make([]byte, 3*minChunkSize+7)
+	rand.Read(data)
+
+	for _, size := range []int{0, 10, minChunkSize, len(data)} {
+		var buf bytes.Buffer
+		w, err := NewEncryptWriter(&buf, "pass", testKDF, WithChunkSize(minChunkSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePieces(t, w, data[:size])
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("got %v, want closing twice to do nothing", err)
+		}
+		if _, err := w.Write([]byte("more")); err == nil {
+			t.Fatal("got nil, want an error writing after Close")
+		}
+
+		r, err := NewDecryptReader(bytes.NewReader(buf.Bytes()), "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data[:size]) {
+			t.Fatalf("size %d: got %d bytes, %v", size, len(got), err)
+		}
+
+		// The salt is in the stream, DecryptStream needs nothing else
+		var out bytes.Buffer
+		if err := DecryptStream(&out, bytes.NewReader(buf.Bytes()), nil, "pass"); err != nil || !bytes.Equal(out.Bytes(), data[:size]) {
+			t.Fatalf("size %d: got %d bytes, %v", size, out.Len(), err)
+		}
+	}
+
+	if _, err := NewEncryptWriter(io.Discard, "pass", WithSaltPosition(SaltDetached)); err == nil {
+		t.Fatal("got nil, want a detached salt refused")
+	}
+
+}
+
+func TestEncryptWriterNotClosed(t *testing.T) {
+
+	data := make([]byte, 3*minChunkSize+7)
+	rand.Read(data)
+
+	// Without Close the final frame is never written, and only the chunks
+	// sealed so far can be read back before the stream fails
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, "pass", testKDF, WithChunkSize(minChunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePieces(t, w, data)
+
+	r, err := NewDecryptReader(bytes.NewReader(buf.Bytes()), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated", err)
+	}
+	if len(got) != 3*minChunkSize || !bytes.Equal(got, data[:len(got)]) {
+		t.Fatalf("got %d bytes, want the %d sealed", len(got), 3*minChunkSize)
+	}
+
+}
+
+func TestDecryptReaderTruncated(t *testing.T) {
+
+	data := make([]byte, 3*minChunkSize+7)
+	rand.Read(data)
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, "pass", testKDF, WithChunkSize(minChunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePieces(t, w, data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ct := buf.Bytes()
+	for _, cut := range []int{1, 16, frameHeaderSize + 7 + 16, minChunkSize / 2, minChunkSize + 100} {
+		r, err := NewDecryptReader(bytes.NewReader(ct[:len(ct)-cut]), "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if !errors.Is(err, ErrTruncated) {
+			t.Fatalf("cut %d: got %v, want ErrTruncated", cut, err)
+		}
+		if !bytes.Equal(got, data[:len(got)]) {
+			t.Fatalf("cut %d: got data that wasn't written", cut)
+		}
+	}
+
+}
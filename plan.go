@@ -0,0 +1,105 @@
+package gocrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Preview of encrypting a directory, see PlanEncryptDir
+type DirPlan struct {
+	Files         int           // Number of files to encrypt
+	InputBytes    int64         // Total size of the files
+	OutputBytes   int64         // Estimated total size of the encrypted output
+	EstimatedTime time.Duration // Estimated time to encrypt everything
+}
+
+// Measured encryption throughput in bytes per second, see sealThroughput
+var throughput struct {
+	sync.Once
+	bps float64
+}
+
+// Function to measure how fast data is sealed on this machine
+func sealThroughput() float64 {
+
+	throughput.Do(func() {
+		block, _ := aes.NewCipher(make([]byte, 32))
+		gcm, _ := cipher.NewGCM(block)
+		chunk := make([]byte, chunkSize)
+		out := make([]byte, 0, chunkSize+gcm.Overhead())
+		nonce := make([]byte, gcm.NonceSize())
+
+		const rounds = 16
+		start := time.Now()
+		for i := 0; i < rounds; i++ {
+			gcm.Seal(out, nonce, chunk, nil)
+		}
+		elapsed := time.Since(start)
+		if elapsed <= 0 {
+			elapsed = time.Nanosecond
+		}
+		throughput.bps = float64(rounds*chunkSize) / elapsed.Seconds()
+	})
+
+	return throughput.bps
+
+}
+
+// Function to preview encrypting every file in a directory without
+// encrypting anything
+//
+// The tree is walked to count the files and their size, the output size is
+// computed with CiphertextSize (plus a .salt file per file when the salt is
+// detached) and the time is estimated from one key derivation per file
+// (EstimateUnlockTime) plus the measured encryption throughput.
+//
+// Variables to pass in:
+//
+//   srcDir string - Directory to encrypt (walked recursively)
+//   opts Options  - Options the files will be encrypted with
+//
+// Returns:
+//
+//   DirPlan - Preview of the work
+//   error   - Error
+func PlanEncryptDir(srcDir string, opts Options) (DirPlan, error) {
+
+	var plan DirPlan
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		plan.Files++
+		plan.InputBytes += fi.Size()
+
+		// EncryptDir records the size of each file in its header
+		o := opts
+		o.length = fi.Size()
+		plan.OutputBytes += CiphertextSize(fi.Size(), o)
+		if opts.SaltPosition == SaltDetached {
+			plan.OutputBytes += int64(opts.KDF.resolve().SaltLen)
+		}
+		return nil
+	})
+	if err != nil {
+		return DirPlan{}, fmt.Errorf("plan encrypt dir: walk: %w", err)
+	}
+
+	plan.EstimatedTime = time.Duration(plan.Files)*EstimateUnlockTime(opts) +
+		time.Duration(float64(plan.InputBytes)/sealThroughput()*float64(time.Second))
+
+	return plan, nil
+
+}
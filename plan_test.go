@@ -0,0 +1,82 @@
+package gocrypt
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanEncryptDir(t *testing.T) {
+
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "top.txt"), "top")
+	writeTestFile(t, filepath.Join(src, "a", "b", "deep.bin"), strings.Repeat("d", 3*chunkSize+1))
+	writeTestFile(t, filepath.Join(src, "a", "empty.txt"), "")
+
+	for _, pos := range []SaltPosition{SaltDetached, SaltHeader} {
+
+		opts := []Option{testKDF, WithSaltPosition(pos)}
+		o, err := NewOptions(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := PlanEncryptDir(src, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.Files != 3 || plan.InputBytes != int64(3+3*chunkSize+1) || plan.EstimatedTime <= 0 {
+			t.Fatalf("got %+v", plan)
+		}
+
+		// Nothing was written to the tree
+		var entries int
+		filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			entries++
+			return err
+		})
+		if entries != 6 {
+			t.Fatalf("got %d entries in the tree, want 6", entries)
+		}
+
+		// The output estimate is the size of each file encrypted as a stream
+		// with its length recorded
+		var want int64
+		for _, data := range []string{"top", strings.Repeat("d", 3*chunkSize+1), ""} {
+			ct, _ := encryptTestStream(t, []byte(data), WithSaltPosition(pos), withLength(int64(len(data))))
+			want += int64(len(ct))
+			if pos == SaltDetached {
+				want += saltSize
+			}
+		}
+		if plan.OutputBytes != want {
+			t.Fatalf("salt %v: got %d bytes, want %d", pos, plan.OutputBytes, want)
+		}
+
+		// and what EncryptDir actually writes
+		enc := t.TempDir()
+		if err := EncryptDir(src, enc, "pass", opts...); err != nil {
+			t.Fatal(err)
+		}
+		var written int64
+		filepath.WalkDir(enc, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				fi, err := d.Info()
+				if err != nil {
+					t.Fatal(err)
+				}
+				written += fi.Size()
+			}
+			return err
+		})
+		if plan.OutputBytes != written {
+			t.Fatalf("salt %v: got %d bytes, EncryptDir wrote %d", pos, plan.OutputBytes, written)
+		}
+
+	}
+
+	if _, err := PlanEncryptDir(filepath.Join(src, "missing"), Options{}); err == nil {
+		t.Fatal("planned a missing directory")
+	}
+
+}
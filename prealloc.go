@@ -0,0 +1,60 @@
+package gocrypt
+
+import (
+	"io"
+	"os"
+)
+
+// Size added to the plaintext by Encrypt (nonce + GCM tag)
+const sealOverhead = 12 + 16
+
+// Function flushing a file to disk, replaceable to watch the order of syncs
+var syncFile = func(s interface{ Sync() error }) error { return s.Sync() }
+
+// Function to write encrypted data to a file, preallocating the file first
+// when an expected size is set
+//
+//   f    *os.File - File to write to
+//   data []byte   - Data to write
+//   o    Options  - Options in use
+func writeOutput(f *os.File, data []byte, o Options) error {
+
+	if o.ExpectedSize > 0 {
+		if err := preallocate(f, o.ExpectedSize+sealOverhead); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.Write(data)
+	if err != nil {
+		return err
+	}
+
+	// Trim whatever the hint over-allocated
+	if o.ExpectedSize > 0 {
+		if err := f.Truncate(int64(n)); err != nil {
+			return err
+		}
+	}
+
+	return syncOutput(f, o)
+
+}
+
+// Function to flush an output to disk when Options.SyncOnClose is set
+//
+// Only outputs with a Sync method (like *os.File) are synced, for any other
+// writer this does nothing.
+//
+//   w io.Writer - Output to sync
+//   o Options   - Options in use
+func syncOutput(w io.Writer, o Options) error {
+
+	s, ok := w.(interface{ Sync() error })
+	if !ok || !o.SyncOnClose {
+		return nil
+	}
+
+	return syncFile(s)
+
+}
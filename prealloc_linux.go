@@ -0,0 +1,24 @@
+//go:build linux
+
+package gocrypt
+
+import (
+	"os"
+	"syscall"
+)
+
+// Function to reserve disk space for a file with fallocate, falling back to
+// extending the file when the filesystem doesn't support it
+//
+//   f    *os.File - File to preallocate
+//   size int64    - Size to reserve in bytes
+func preallocate(f *os.File, size int64) error {
+
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return f.Truncate(size)
+	}
+
+	return err
+
+}
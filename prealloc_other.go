@@ -0,0 +1,13 @@
+//go:build !linux
+
+package gocrypt
+
+import "os"
+
+// Function to reserve disk space for a file by extending it
+//
+//   f    *os.File - File to preallocate
+//   size int64    - Size to reserve in bytes
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
@@ -0,0 +1,77 @@
+package gocrypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpectedSize(t *testing.T) {
+
+	data := bytes.Repeat([]byte("x"), 10000)
+	dir := t.TempDir()
+
+	var want int64
+	for _, hint := range []int64{0, 100, int64(len(data)), 1 << 20} {
+		path := filepath.Join(dir, "out.3dfx")
+		if _, err := EncryptToFile(path, "", data, "pass", testKDF, WithExpectedSize(hint)); err != nil {
+			t.Fatalf("hint %d: encrypt: %v", hint, err)
+		}
+
+		// The file is the size of the ciphertext whatever the hint
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hint == 0 {
+			want = fi.Size()
+		} else if fi.Size() != want {
+			t.Fatalf("hint %d: file is %d bytes, want %d", hint, fi.Size(), want)
+		}
+
+		pt, err := DecryptFromFile(path, nil, "pass")
+		if err != nil || !bytes.Equal(pt, data) {
+			t.Fatalf("hint %d: decrypt: %v", hint, err)
+		}
+	}
+
+	if _, err := NewOptions(WithExpectedSize(-1)); err == nil {
+		t.Fatal("negative size accepted")
+	}
+
+}
+
+// Writer recording whether it was synced
+type syncRecorder struct {
+	bytes.Buffer
+	synced bool
+}
+
+func (w *syncRecorder) Sync() error {
+	w.synced = true
+	return nil
+}
+
+func TestSyncOnClose(t *testing.T) {
+
+	for _, on := range []bool{true, false} {
+		w := &syncRecorder{}
+		opts := []Option{testKDF}
+		if !on {
+			opts = append(opts, WithSyncOnClose(false))
+		}
+		if _, err := EncryptStream(w, bytes.NewReader([]byte("data")), "pass", opts...); err != nil {
+			t.Fatal(err)
+		}
+		if w.synced != on {
+			t.Fatalf("SyncOnClose %t: synced %t", on, w.synced)
+		}
+	}
+
+	// Writers without Sync are left alone
+	if err := syncOutput(&bytes.Buffer{}, defaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+}
@@ -0,0 +1,69 @@
+package gocrypt
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Function to report progress to the callback set in Options, if any
+//
+//   done  int64 - Bytes processed so far
+//   total int64 - Bytes to process in total (0 or less if unknown)
+func (o Options) progress(done, total int64) {
+	if o.Progress != nil {
+		if total <= 0 {
+			total = -1
+		}
+		o.Progress(done, total)
+	}
+}
+
+// Function to wrap the function walkTree calls for each file so progress is
+// reported once per file
+//
+// The total is the size of the files matched in src, added up before the
+// walk starts. Calls are serialized, so the callback never runs
+// concurrently even with Options.Concurrency. Files count as done whether
+// they succeeded or not.
+//
+//   src   string                 - Directory walked
+//   match func(path string) bool - Function telling which files are processed
+//   fn    func                   - Function walkTree calls for each file
+//   o     Options                - Options in use
+func dirProgress(src string, match func(path string) bool, fn func(path, rel, out string) error, o Options) func(path, rel, out string) error {
+
+	if o.Progress == nil {
+		return fn
+	}
+
+	// Files that can't be read are reported by the walk itself
+	var total int64
+	filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() && match(path) {
+			if fi, err := d.Info(); err == nil {
+				total += fi.Size()
+			}
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	var done int64
+	return func(path, rel, out string) error {
+		err := fn(path, rel, out)
+		if match(path) {
+			var size int64
+			if fi, serr := os.Lstat(path); serr == nil {
+				size = fi.Size()
+			}
+			mu.Lock()
+			done += size
+			o.progress(done, total)
+			mu.Unlock()
+		}
+		return err
+	}
+
+}
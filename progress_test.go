@@ -0,0 +1,121 @@
+package gocrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Progress callback recording its calls, failing the test if they overlap
+// or go backwards
+type progressRecorder struct {
+	t      *testing.T
+	active int32
+	calls  int
+	done   int64
+	total  int64
+}
+
+func (p *progressRecorder) report(done, total int64) {
+	if !atomic.CompareAndSwapInt32(&p.active, 0, 1) {
+		p.t.Error("progress reported concurrently")
+		return
+	}
+	defer atomic.StoreInt32(&p.active, 0)
+	time.Sleep(time.Millisecond)
+	if done < p.done {
+		p.t.Errorf("got %d done after %d, want it monotonic", done, p.done)
+	}
+	if p.calls > 0 && total != p.total {
+		p.t.Errorf("got total %d after %d, want it fixed", total, p.total)
+	}
+	p.calls++
+	p.done, p.total = done, total
+}
+
+func TestProgress(t *testing.T) {
+
+	data := make([]byte, 5*minChunkSize+7)
+	rand.Read(data)
+
+	// A reader's size isn't known up front
+	p := &progressRecorder{t: t}
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(data), "pass", testKDF, WithChunkSize(minChunkSize), WithProgress(p.report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 6 || p.done != int64(len(data)) || p.total != -1 {
+		t.Fatalf("got %d calls, %d/%d, want 6 calls reaching %d/-1", p.calls, p.done, p.total, len(data))
+	}
+
+	// The length in the header gives the total when decrypting
+	p = &progressRecorder{t: t}
+	src, dst := t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.bin", string(data))
+	if err := EncryptFileStream("a.bin", src, dst, "pass", testKDF, WithChunkSize(minChunkSize), WithProgress(p.report)); err != nil {
+		t.Fatal(err)
+	}
+	if p.done != int64(len(data)) || p.total != int64(len(data)) {
+		t.Fatalf("got %d/%d, want %d/%d", p.done, p.total, len(data), len(data))
+	}
+	p = &progressRecorder{t: t}
+	if err := DecryptFileStream("a.bin", dst, t.TempDir()+"/", "pass", testKDF, WithProgress(p.report)); err != nil {
+		t.Fatal(err)
+	}
+	if p.done != int64(len(data)) || p.total != int64(len(data)) {
+		t.Fatalf("got %d/%d, want %d/%d", p.done, p.total, len(data), len(data))
+	}
+
+	// Streams without it report -1
+	p = &progressRecorder{t: t}
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(buf.Bytes()), meta.Salt, "pass", WithProgress(p.report)); err != nil {
+		t.Fatal(err)
+	}
+	if p.done != int64(len(data)) || p.total != -1 {
+		t.Fatalf("got %d/%d, want %d/-1", p.done, p.total, len(data))
+	}
+
+}
+
+func TestDirProgress(t *testing.T) {
+
+	src, enc := t.TempDir(), t.TempDir()
+	var total int64
+	for i := 0; i < 16; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, 100*i)
+		writeTestFile(t, filepath.Join(src, fmt.Sprintf("d%d/f%02d", i%2, i)), string(data))
+		total += int64(len(data))
+	}
+
+	// Serialized and reaching the size of the files, even with workers
+	p := &progressRecorder{t: t}
+	if err := EncryptDir(src, enc, "pass", testKDF, WithConcurrency(8), WithProgress(p.report)); err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 16 || p.done != total || p.total != total {
+		t.Fatalf("got %d calls, %d/%d, want 16 calls reaching %d", p.calls, p.done, p.total, total)
+	}
+
+	// Decrypting counts the size of the encrypted files
+	var encTotal int64
+	for name, data := range readTestTree(t, enc) {
+		if strings.HasSuffix(name, ".3dfx") {
+			encTotal += int64(len(data))
+		}
+	}
+	p = &progressRecorder{t: t}
+	if err := DecryptDir(enc, t.TempDir(), "pass", testKDF, WithConcurrency(8), WithProgress(p.report)); err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 16 || p.done != encTotal || p.total != encTotal {
+		t.Fatalf("got %d calls, %d/%d, want 16 calls reaching %d", p.calls, p.done, p.total, encTotal)
+	}
+
+}
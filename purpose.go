@@ -0,0 +1,28 @@
+package gocrypt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Error returned when a stream was encrypted for another purpose
+var ErrPurposeMismatch = errors.New("purpose mismatch")
+
+// Function to derive the key of a purpose from the key derived from the
+// passphrase
+//
+//   master  []byte - Key derived from the passphrase
+//   purpose string - Purpose of the key
+func purposeKey(master []byte, purpose string) ([]byte, error) {
+
+	key := make([]byte, len(master))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("gocrypt purpose "+purpose)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+
+}
@@ -0,0 +1,55 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPurpose(t *testing.T) {
+
+	session := WithPurpose("session")
+	ciphertext, salt, err := Encrypt([]byte("data"), "pass", testKDF, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := Decrypt(ciphertext, salt, "pass", testKDF, session); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	for _, opt := range []Option{WithPurpose("backup"), WithPurpose("")} {
+		if _, err := Decrypt(ciphertext, salt, "pass", testKDF, opt); err != ErrPurposeMismatch {
+			t.Fatalf("got %v, want ErrPurposeMismatch", err)
+		}
+	}
+	tampered := bytes.Replace(ciphertext, []byte("session"), []byte("archive"), 1)
+	if _, err := Decrypt(tampered, salt, "pass", testKDF, WithPurpose("archive")); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	// Streams record their purpose, so a mismatch is reported as such
+	stream, streamSalt := encryptTestStream(t, []byte("data"), session)
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(stream), streamSalt, "pass", session); err != nil || out.String() != "data" {
+		t.Fatalf("got %q, %v", out.String(), err)
+	}
+	for _, opt := range []Option{WithPurpose("backup"), WithPurpose("")} {
+		if err := DecryptStream(ioutil.Discard, bytes.NewReader(stream), streamSalt, "pass", opt); err != ErrPurposeMismatch {
+			t.Fatalf("got %v, want ErrPurposeMismatch", err)
+		}
+	}
+
+	// Rewriting the recorded purpose doesn't get past the key
+	tampered = bytes.Replace(stream, []byte("session"), []byte("archive"), 1)
+	err = DecryptStream(ioutil.Discard, bytes.NewReader(tampered), streamSalt, "pass", WithPurpose("archive"))
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	// See TestReadHeaderCorrupted for the same limit on headers
+	if _, err := NewOptions(WithPurpose(strings.Repeat("p", maxPurposeSize+1))); err == nil {
+		t.Fatal("got nil, want an error for a long purpose")
+	}
+
+}
@@ -0,0 +1,105 @@
+package gocrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Function to re-encrypt a stream file with a different chunk size
+//
+// The file is decrypted and encrypted again in a single streaming pass, so
+// it never has to fit in memory. The new stream keeps the settings found in
+// the header (algorithm, parity, derived nonces, hash chaining, not before
+// time, purpose, comment, stream id, scrypt parameters and where the salt is
+// stored) but gets a new salt and key.
+// Only streams storing their salt in a header or trailer can be rechunked.
+// The output is written to a temporary file renamed to dstPath once
+// complete, so dstPath may be srcPath.
+//
+// Variables to pass in:
+//
+//   srcPath string   - Path of the stream file to rechunk
+//   dstPath string   - Path to write the rechunked stream to
+//   pass string      - Passphrase to use for decryption and encryption
+//   newChunkSize int - Size of the plaintext sealed into each new frame
+//   opts             - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func Rechunk(srcPath, dstPath, pass string, newChunkSize int, opts ...Option) error {
+
+	o, err := newOptions(append(opts[:len(opts):len(opts)], WithChunkSize(newChunkSize)))
+	if err != nil {
+		return fmt.Errorf("rechunk: options: %w", err)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("rechunk: open file: %w", err)
+	}
+	defer src.Close()
+
+	info, _, err := readHeader(src)
+	if err != nil {
+		return fmt.Errorf("rechunk: header: %w", err)
+	}
+	if len(info.Salt) == 0 && !info.SaltTrailer {
+		return errors.New("the salt isn't stored in the stream")
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rechunk: seek: %w", err)
+	}
+
+	o.Cipher = info.Algorithm
+	o.Parity = info.Parity
+	o.DerivedNonces = info.NonceBase != nil
+	o.HashChain = info.HashChain
+	o.NotBefore = info.NotBefore
+	o.Purpose = info.Purpose
+	o.Comment = info.Comment
+	o.KDF = info.KDF
+	o.StreamID = info.StreamID
+	o.Canary = info.Canary
+	saltPos := SaltHeader
+	if info.SaltTrailer {
+		saltPos = SaltTrailer
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dstPath), filepath.Base(dstPath)+".tmp")
+	if err != nil {
+		return fmt.Errorf("rechunk: create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Frames are decrypted in the background and re-split by the encryptor
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(decryptStream(context.Background(), src, src, nil, pass, func(frame []byte) error {
+			_, err := pw.Write(frame)
+			return err
+		}, o))
+	}()
+
+	_, err = encryptStream(context.Background(), tmp, tmp, pr, pass, saltPos, o)
+	// Unblock the decryptor if encryption stopped early
+	pr.CloseWithError(err)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("rechunk: encrypt: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dstPath); err != nil {
+		return fmt.Errorf("rechunk: rename: %w", err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,71 @@
+package gocrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRechunk(t *testing.T) {
+
+	const newChunk = 1 << 20
+	data := make([]byte, 3*newChunk+123)
+	rand.Read(data)
+	dir := t.TempDir()
+
+	for _, pos := range []SaltPosition{SaltHeader, SaltTrailer} {
+
+		stream, _ := encryptTestStream(t, data, WithSaltPosition(pos), WithHashChain(true), WithDerivedNonces(true))
+		src := filepath.Join(dir, "data.3dfx")
+		writeTestFile(t, src, string(stream))
+		if err := Rechunk(src, src, "pass", newChunk); err != nil {
+			t.Fatal(err)
+		}
+
+		// The settings in the header are kept
+		enc, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := Inspect(bytes.NewReader(enc))
+		if err != nil || info.ChunkSize != newChunk || !info.HashChain || info.NonceBase == nil || info.SaltTrailer != (pos == SaltTrailer) || info.KDF.N != 1<<10 {
+			t.Fatalf("salt %v: got %+v, %v", pos, info, err)
+		}
+
+		// Each frame now holds 1 MiB of the plaintext
+		var frames [][]byte
+		err = DecryptEachFrame(bytes.NewReader(enc), nil, "pass", func(frame []byte) error {
+			frames = append(frames, append([]byte(nil), frame...))
+			return nil
+		})
+		if err != nil || len(frames) != 4 {
+			t.Fatalf("salt %v: got %d frames, %v, want 4", pos, len(frames), err)
+		}
+		for i, frame := range frames {
+			end := (i + 1) * newChunk
+			if end > len(data) {
+				end = len(data)
+			}
+			if !bytes.Equal(frame, data[i*newChunk:end]) {
+				t.Fatalf("salt %v: frame %d doesn't hold bytes %d to %d", pos, i, i*newChunk, end)
+			}
+		}
+
+		if err := Rechunk(src, src, "wrong", minChunkSize); err == nil {
+			t.Fatal("rechunked with the wrong passphrase")
+		}
+		if err := Rechunk(src, src, "pass", minChunkSize-1); err == nil {
+			t.Fatal("rechunked below the minimum chunk size")
+		}
+		if got, _ := os.ReadFile(src); !bytes.Equal(got, enc) {
+			t.Fatal("a failed rechunk changed the file")
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Fatalf("got %d files, want no temporary file left", len(entries))
+		}
+
+	}
+
+}
@@ -0,0 +1,94 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Error returned when none of the candidate salts authenticate a file
+var ErrSaltNotFound = errors.New("no candidate salt authenticates the file")
+
+// Returned by the frame callback once the first frame authenticated
+var errFirstFrame = errors.New("first frame authenticated")
+
+// Function to recover the lost salt of an encrypted file from its siblings
+//
+// Each candidate .salt file is tried against the first frame of the
+// encrypted file (or the whole ciphertext for files written by Encrypt) and
+// the first salt that authenticates is returned. This only helps when the
+// salt was shared across a batch of files, a lost salt can't be brute-forced.
+//
+// Variables to pass in:
+//
+//   encPath string                - Path of the encrypted file
+//   candidateSaltSources []string - Paths of the candidate .salt files
+//   pass string                   - Passphrase used to encrypt the file
+//
+// Returns:
+//
+//   []byte - Recovered salt
+//   error  - Error (ErrSaltNotFound if no candidate authenticates)
+func RecoverSalt(encPath string, candidateSaltSources []string, pass string) ([]byte, error) {
+
+	f, err := os.Open(encPath)
+	if err != nil {
+		return nil, fmt.Errorf("recover salt: open file: %w", err)
+	}
+	defer f.Close()
+
+	// Data encrypted by Encrypt may start with a header too
+	info, _, err := readHeader(f)
+	stream := err == nil && !info.OneShot
+
+	var data []byte
+	if !stream {
+		if data, err = ioutil.ReadFile(encPath); err != nil {
+			return nil, fmt.Errorf("recover salt: read file: %w", err)
+		}
+		if len(data) < sealOverhead {
+			return nil, fmt.Errorf("%w data: ciphertext too short", ErrCorrupted)
+		}
+	}
+
+	var tried [][]byte
+	for _, source := range candidateSaltSources {
+
+		salt, err := ioutil.ReadFile(source)
+		if err != nil || len(salt) == 0 {
+			continue
+		}
+
+		// Batches share their salt, don't derive the same key twice
+		seen := false
+		for _, t := range tried {
+			if bytes.Equal(t, salt) {
+				seen = true
+				break
+			}
+		}
+		if seen {
+			continue
+		}
+		tried = append(tried, salt)
+
+		if stream {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("recover salt: seek: %w", err)
+			}
+			err = DecryptEachFrame(f, salt, pass, func([]byte) error { return errFirstFrame })
+			if err == errFirstFrame {
+				return salt, nil
+			}
+		} else if _, err := Decrypt(data, salt, pass); err == nil {
+			return salt, nil
+		}
+
+	}
+
+	return nil, ErrSaltNotFound
+
+}
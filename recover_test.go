@@ -0,0 +1,56 @@
+package gocrypt
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecoverSalt(t *testing.T) {
+
+	dir := t.TempDir()
+	ciphertext, salt, err := Encrypt([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := filepath.Join(dir, "data.3dfx")
+	writeTestFile(t, enc, string(ciphertext))
+
+	var sources []string
+	for i := 0; i < 3; i++ {
+		other, err := GenerateSalt(len(salt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(dir, fmt.Sprintf("other%d.salt", i))
+		writeTestFile(t, p, string(other))
+		sources = append(sources, p)
+	}
+	sources = append(sources, filepath.Join(dir, "missing.salt"))
+
+	if _, err := RecoverSalt(enc, sources, "pass"); err != ErrSaltNotFound {
+		t.Fatalf("got %v, want ErrSaltNotFound", err)
+	}
+
+	good := filepath.Join(dir, "good.salt")
+	writeTestFile(t, good, string(salt))
+	sources = append(sources[:2], append([]string{good}, sources[2:]...)...)
+	got, err := RecoverSalt(enc, sources, "pass")
+	if err != nil || !bytes.Equal(got, salt) {
+		t.Fatalf("got %x, %v, want %x", got, err, salt)
+	}
+	if _, err := RecoverSalt(enc, sources, "wrong"); err != ErrSaltNotFound {
+		t.Fatalf("got %v, want ErrSaltNotFound", err)
+	}
+
+	// Streams are checked against their first frame only
+	stream, streamSalt := encryptTestStream(t, make([]byte, 3*minChunkSize), WithChunkSize(minChunkSize))
+	writeTestFile(t, enc, string(stream))
+	writeTestFile(t, good, string(streamSalt))
+	got, err = RecoverSalt(enc, sources, "pass")
+	if err != nil || !bytes.Equal(got, streamSalt) {
+		t.Fatalf("got %x, %v, want %x", got, err, streamSalt)
+	}
+
+}
@@ -0,0 +1,182 @@
+package gocrypt
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"strings"
+)
+
+// Error returned when a recovery sheet was transcribed incorrectly
+var ErrSheetChecksum = errors.New("recovery sheet checksum mismatch")
+
+// Version of the recovery sheet format
+const recoverySheetVersion = 1
+
+// Characters per group and groups per line of a recovery sheet
+const (
+	sheetGroupSize = 4
+	sheetLineSize  = 8
+)
+
+// Encoding of recovery sheets, base32 without padding
+var sheetEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key recovered from a recovery sheet
+type RecoveryKey struct {
+	Salt []byte // Salt the key was derived with
+	key  []byte
+}
+
+// Function to export the key derived from a salt and passphrase as a
+// printable recovery sheet
+//
+// The sheet holds the key, the salt and the scrypt parameters encoded in
+// base32, grouped by 4 characters, followed by a CRC-32 so transcription
+// errors are caught when importing it. Anyone holding the sheet can decrypt
+// what was encrypted with the salt and passphrase, store it like the
+// passphrase itself.
+//
+// Variables to pass in:
+//
+//   salt []byte  - Salt the data was encrypted with
+//   pass string  - Passphrase the data was encrypted with
+//   opts Options - Options the data was encrypted with
+//
+// Returns:
+//
+//   string - Recovery sheet
+//   error  - Error
+func ExportRecoverySheet(salt []byte, pass string, opts Options) (string, error) {
+
+	if len(salt) == 0 || len(salt) > 255 {
+		return "", errors.New("invalid salt")
+	}
+
+	_, key, err := createHash(salt, pass, opts)
+	if err != nil {
+		return "", err
+	}
+	defer Scrub(key)
+
+	// The scrypt parameters are only a record, left zero for Argon2id
+	var cost [3]byte
+	if p := opts.KDF.resolve(); p.Func == Scrypt {
+		cost = [3]byte{byte(bits.Len(uint(p.N)) - 1), byte(p.R), byte(p.P)}
+	}
+	b := []byte{recoverySheetVersion, cost[0], cost[1], cost[2], byte(len(salt))}
+	b = append(b, salt...)
+	b = append(b, key...)
+	b = appendUint32(b, crc32.ChecksumIEEE(b))
+	defer Scrub(b)
+
+	code := sheetEncoding.EncodeToString(b)
+	var sheet strings.Builder
+	for i := 0; i < len(code); i += sheetGroupSize {
+		if i > 0 {
+			if (i/sheetGroupSize)%sheetLineSize == 0 {
+				sheet.WriteByte('\n')
+			} else {
+				sheet.WriteByte('-')
+			}
+		}
+		end := i + sheetGroupSize
+		if end > len(code) {
+			end = len(code)
+		}
+		sheet.WriteString(code[i:end])
+	}
+
+	return sheet.String(), nil
+
+}
+
+// Function to import a recovery sheet made by ExportRecoverySheet
+//
+// Spaces, dashes and line breaks are ignored and lowercase is accepted, so
+// the sheet can be typed back as printed.
+//
+// Variables to pass in:
+//
+//   sheet string - Recovery sheet
+//
+// Returns:
+//
+//   RecoveryKey - Key to decrypt with
+//   error       - Error (ErrSheetChecksum if it was mistyped)
+func ImportRecoverySheet(sheet string) (RecoveryKey, error) {
+
+	code := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '\n', '\r', '\t':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(sheet))
+
+	// Unused bits of the last character must be zero too, or a mistyped last
+	// character could decode to the same bytes
+	b, err := sheetEncoding.DecodeString(code)
+	if err != nil || sheetEncoding.EncodeToString(b) != code {
+		return RecoveryKey{}, ErrSheetChecksum
+	}
+	defer Scrub(b)
+
+	if len(b) < 5+4 || crc32.ChecksumIEEE(b[:len(b)-4]) != binary.BigEndian.Uint32(b[len(b)-4:]) {
+		return RecoveryKey{}, ErrSheetChecksum
+	}
+	b = b[:len(b)-4]
+
+	if b[0] != recoverySheetVersion {
+		return RecoveryKey{}, errors.New("unsupported recovery sheet version")
+	}
+	// The key is already derived, the scrypt parameters are only a record
+	// of how
+	saltLen := int(b[4])
+	if keyLen := len(b) - 5 - saltLen; keyLen != 16 && keyLen != 24 && keyLen != 32 {
+		return RecoveryKey{}, errors.New("invalid recovery sheet length")
+	}
+
+	return RecoveryKey{
+		Salt: append([]byte(nil), b[5:5+saltLen]...),
+		key:  append([]byte(nil), b[5+saltLen:]...),
+	}, nil
+
+}
+
+// Function to decrypt data with a recovered key
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be decrypted
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func (k RecoveryKey) Decrypt(data []byte) ([]byte, error) {
+
+	info, header, body, _ := splitSealed(data)
+	if len(body) < sealOverhead {
+		return nil, fmt.Errorf("%w data: ciphertext too short", ErrCorrupted)
+	}
+
+	plaintext, err := openSealed(info.Algorithm, k.key, body, header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("recovery key decrypt: %w", err)
+	}
+	if info.Compressed {
+		inflated, err := decompress(plaintext, 0)
+		Scrub(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("recovery key decrypt: %w", err)
+		}
+		plaintext = inflated
+	}
+
+	return plaintext, nil
+
+}
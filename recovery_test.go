@@ -0,0 +1,62 @@
+package gocrypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRecoverySheet(t *testing.T) {
+
+	ciphertext, salt, err := Encrypt([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := NewOptions(testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := ExportRecoverySheet(salt, "pass", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Typed back in lower case, the sheet still imports
+	for _, s := range []string{sheet, strings.ToLower(sheet)} {
+		key, err := ImportRecoverySheet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key.Salt, salt) {
+			t.Fatalf("got salt %x, want %x", key.Salt, salt)
+		}
+		pt, err := key.Decrypt(ciphertext)
+		if err != nil || string(pt) != "data" {
+			t.Fatalf("got %q, %v", pt, err)
+		}
+	}
+
+	// Every single-character transcription error is caught
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	b := []byte(sheet)
+	for i, orig := range b {
+		if !strings.ContainsRune(alphabet, rune(orig)) {
+			continue
+		}
+		for _, c := range []byte(alphabet) {
+			if c == orig {
+				continue
+			}
+			b[i] = c
+			if _, err := ImportRecoverySheet(string(b)); err != ErrSheetChecksum {
+				t.Fatalf("%c at %d: got %v, want ErrSheetChecksum", c, i, err)
+			}
+		}
+		b[i] = orig
+	}
+
+	if _, err := ImportRecoverySheet(sheet[:len(sheet)-1]); err != ErrSheetChecksum {
+		t.Fatalf("got %v, want ErrSheetChecksum", err)
+	}
+
+}
@@ -0,0 +1,151 @@
+package gocrypt
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Maximum number of passphrases DecryptAny will try, each one costs a full
+// scrypt derivation
+const maxDecryptAnyPassphrases = 8
+
+// Function to decrypt data with the first of several passphrases that works
+//
+// This allows rotating passphrases without downtime: while data encrypted
+// under the old passphrase is being re-encrypted, both can be supplied. The
+// passphrases are tried in order, so put the most likely one first.
+//
+// Variables to pass in:
+//
+//   data []byte           - Data to be decrypted
+//   salt []byte           - Salt to use to create hash
+//   passphrases ...string - Passphrases to try (at most 8)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   string - Passphrase that worked
+//   error  - Error
+func DecryptAny(data, salt []byte, passphrases ...string) ([]byte, string, error) {
+
+	if len(passphrases) == 0 {
+		return nil, "", errors.New("no passphrase supplied")
+	}
+
+	if len(passphrases) > maxDecryptAnyPassphrases {
+		return nil, "", fmt.Errorf("too many passphrases: %d (max %d)", len(passphrases), maxDecryptAnyPassphrases)
+	}
+
+	var err error
+	for _, pass := range passphrases {
+		var plaintext []byte
+		plaintext, err = Decrypt(data, salt, pass)
+		if err == nil {
+			return plaintext, pass, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("none of the passphrases matched: %w", err)
+
+}
+
+// Function to re-encrypt a file under a new passphrase
+//
+// The file is decrypted in memory with the old passphrase and encrypted
+// again with the new one and a fresh salt, stored in the header, so no
+// plaintext is ever written to disk. A wrong old passphrase fails before
+// anything is written. The output is written to a temporary file and
+// renamed over the .3dfx file in the destination, so a crash leaves either
+// the old file or the new one. A .salt file left there is removed right
+// after; the new salt is in the header, which DecryptFile prefers, so a
+// crash in between leaves a stale .salt file that does no harm.
+// Metadata kept with Options.PreserveMetadata is carried over. Files with a
+// pepper, a detached MAC or bound to their path aren't supported.
+//
+// Variables to pass in:
+//
+//   file string    - Name of the file (without the .3dfx extension)
+//   from string    - Specify path of file
+//   to   string    - Specify destination path to output file (empty to
+//                    replace the file in place)
+//                    (must end with "/" ie. /opt/app/ instead of /opt/app)
+//   oldPass string - Passphrase the file is encrypted with
+//   newPass string - Passphrase to encrypt the file with
+//   opts           - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func Rekey(file, from, to, oldPass, newPass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("rekey: options: %w", err)
+	}
+	if o.DetachedMAC || o.BindToPath || o.KeyWrapper != nil {
+		return errors.New("rekey: detached MAC, path binding and peppers aren't supported")
+	}
+	if _, err := os.Stat(pepperPath(from + file + ".3dfx")); err == nil {
+		return errors.New("rekey: files with a pepper aren't supported")
+	}
+
+	data, err := ioutil.ReadFile(from + file + ".3dfx")
+	if err != nil {
+		return fmt.Errorf("rekey: read file: %w", err)
+	}
+
+	salt, err := readSaltFile(from+file, data)
+	if err != nil {
+		return fmt.Errorf("rekey: read salt file: %w", err)
+	}
+
+	plaintext, err := Decrypt(data, salt, oldPass, opts...)
+	if err != nil {
+		return err
+	}
+	defer Scrub(plaintext)
+
+	// The header was authenticated along with the data
+	encOpts := withFileHeader(opts)
+	if info, _, _, _ := splitSealed(data); info.Metadata != nil {
+		encOpts = append(encOpts, withMetadata(info.Metadata))
+	}
+	ciphertext, _, err := Encrypt(plaintext, newPass, encOpts...)
+	if err != nil {
+		return err
+	}
+
+	if to == "" {
+		to = from
+	}
+	path := to + file + ".3dfx"
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("rekey: create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Synced whatever SyncOnClose says, the rename must not outrun the data
+	o.SyncOnClose = true
+	err = writeOutput(tmp, ciphertext, o)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("rekey: write file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rekey: rename: %w", err)
+	}
+
+	if err := os.Remove(to + file + ".salt"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rekey: remove salt file: %w", err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,94 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDecryptAny(t *testing.T) {
+
+	ct, salt, err := Encrypt([]byte("data"), "old", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, passes := range [][]string{{"old", "new"}, {"new", "old"}} {
+		pt, pass, err := DecryptAny(ct, salt, passes...)
+		if err != nil || string(pt) != "data" || pass != "old" {
+			t.Fatalf("%v: got %q, %q, %v", passes, pt, pass, err)
+		}
+	}
+
+	if _, _, err := DecryptAny(ct, salt, "new", "newer"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+	if _, _, err := DecryptAny(ct, salt); err == nil {
+		t.Fatal("decrypted without a passphrase")
+	}
+	if _, _, err := DecryptAny(ct, salt, make([]string, maxDecryptAnyPassphrases+1)...); err == nil {
+		t.Fatal("tried more passphrases than allowed")
+	}
+
+}
+
+func TestRekey(t *testing.T) {
+
+	src, dst, out := t.TempDir()+"/", t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "data")
+	if err := EncryptFile("a.txt", src, dst, "old", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dst + "a.txt.3dfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSalt, err := os.ReadFile(dst + "a.txt.salt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A wrong old passphrase leaves everything as it was
+	if err := Rekey("a.txt", dst, "", "wrong", "new", testKDF); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+	if data, err := os.ReadFile(dst + "a.txt.3dfx"); err != nil || !bytes.Equal(data, before) {
+		t.Fatalf("file changed by a failed rekey (%v)", err)
+	}
+	if _, err := os.Stat(dst + "a.txt.salt"); err != nil {
+		t.Fatalf("got %v, want the salt file kept", err)
+	}
+
+	if err := Rekey("a.txt", dst, "", "old", "new", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(dst + "a.txt.3dfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _, _, _ := splitSealed(after); len(info.Salt) == 0 || bytes.Equal(info.Salt, oldSalt) {
+		t.Fatalf("got salt %x, want a fresh one", info.Salt)
+	}
+	if _, err := os.Stat(dst + "a.txt.salt"); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want the salt file removed", err)
+	}
+
+	if err := DecryptFile("a.txt", dst, out, "old", testKDF); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed for the old passphrase", err)
+	}
+	if err := DecryptFile("a.txt", dst, out, "new", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(out + "a.txt"); err != nil || string(data) != "data" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+	// A crash between the rename and removing the salt file leaves the old
+	// salt behind, the one in the header is used
+	writeTestFile(t, dst+"a.txt.salt", string(oldSalt))
+	if err := DecryptFile("a.txt", dst, out, "new", testKDF); err != nil {
+		t.Fatalf("got %v with a stale salt file", err)
+	}
+
+}
@@ -0,0 +1,76 @@
+package gocrypt
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Function to derive a salt deterministically from a seed
+//
+// The salt is expanded from the seed with HKDF-SHA-256, so the same seed
+// always gives the same salt without using a random number generator. It's
+// meant for reproducible test fixtures and golden vectors: a salt must be
+// unique per encryption to do its job, so don't use it in production, least
+// of all with a seed derived from a secret alone.
+//
+// Variables to pass in:
+//
+//   seed []byte - Seed to derive the salt from
+//   n    int    - Length of the salt in bytes (1 to 255)
+//
+// Returns:
+//
+//   []byte - Salt
+//   error  - Error
+func DeriveSaltFromSeed(seed []byte, n int) ([]byte, error) {
+
+	if n < 1 || n > 255 {
+		return nil, fmt.Errorf("derive salt: length must be between 1 and 255 bytes, got %d", n)
+	}
+
+	salt := make([]byte, n)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, seed, nil, []byte("gocrypt salt")), salt); err != nil {
+		return nil, fmt.Errorf("derive salt: %w", err)
+	}
+
+	return salt, nil
+
+}
+
+// Function to encrypt data with a given salt instead of a random one
+//
+// The output is the same as Encrypt's and decrypts with Decrypt and the
+// salt. Reusing a salt with the same passphrase gives the same key, only
+// do it knowingly (see DeriveSaltFromSeed).
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   salt []byte - Salt to use to create hash (8 to 255 bytes)
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Encrypted Data
+//   error  - Error
+func EncryptWithSalt(data, salt []byte, pass string, opts ...Option) ([]byte, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt with salt: options: %w", err)
+	}
+
+	if len(salt) < 8 || len(salt) > 255 {
+		return nil, fmt.Errorf("salt must be between 8 and 255 bytes, got %d", len(salt))
+	}
+
+	ciphertext, _, err := encrypt(context.Background(), data, salt, pass, o)
+
+	return ciphertext, err
+
+}
@@ -0,0 +1,50 @@
+package gocrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeriveSaltFromSeed(t *testing.T) {
+
+	a, err := DeriveSaltFromSeed([]byte("seed"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := DeriveSaltFromSeed([]byte("seed"), 16)
+	c, _ := DeriveSaltFromSeed([]byte("other seed"), 16)
+	if len(a) != 16 || !bytes.Equal(a, b) {
+		t.Fatalf("the same seed gave %x and %x", a, b)
+	}
+	if bytes.Equal(a, c) {
+		t.Fatal("different seeds gave the same salt")
+	}
+
+	for _, n := range []int{0, 256} {
+		if _, err := DeriveSaltFromSeed([]byte("seed"), n); err == nil {
+			t.Errorf("length %d accepted", n)
+		}
+	}
+
+}
+
+func TestEncryptWithSalt(t *testing.T) {
+
+	salt, err := DeriveSaltFromSeed([]byte("seed"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := EncryptWithSalt([]byte("data"), salt, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := Decrypt(ct, salt, "pass")
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	if _, err := EncryptWithSalt([]byte("data"), []byte("short"), "pass"); err == nil {
+		t.Fatal("encrypted with a 5 byte salt")
+	}
+
+}
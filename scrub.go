@@ -0,0 +1,52 @@
+package gocrypt
+
+// Function to overwrite a byte slice with zeros
+//
+// Use it to wipe plaintext as soon as it's no longer needed, typically with
+// defer right after a successful Decrypt:
+//
+//   plaintext, err := gocrypt.Decrypt(data, salt, pass)
+//   if err != nil {
+//   	return err
+//   }
+//   defer gocrypt.Scrub(plaintext)
+//
+// Every byte is written regardless of its value, so the time taken only
+// depends on the length of b.
+//
+// Variables to pass in:
+//
+//   b []byte - Slice to wipe
+func Scrub(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Function to decrypt data and hand the plaintext to a function
+//
+// The plaintext is scrubbed before DecryptFunc returns whatever fn returns,
+// so fn must not keep a reference to it.
+//
+// Variables to pass in:
+//
+//   data []byte           - Data to be decrypted
+//   salt []byte           - Salt to use to create hash
+//   pass string           - Passphrase to use for decryption
+//   fn func([]byte) error - Function to call with the plaintext
+//   opts                  - Options (optional)
+//
+// Returns:
+//
+//   error - Error (from decryption or fn)
+func DecryptFunc(data, salt []byte, pass string, fn func([]byte) error, opts ...Option) error {
+
+	plaintext, err := Decrypt(data, salt, pass, opts...)
+	if err != nil {
+		return err
+	}
+	defer Scrub(plaintext)
+
+	return fn(plaintext)
+
+}
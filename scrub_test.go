@@ -0,0 +1,56 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestScrub(t *testing.T) {
+
+	b := []byte("secret")
+	Scrub(b)
+	if !bytes.Equal(b, make([]byte, 6)) {
+		t.Fatalf("got %q after Scrub", b)
+	}
+	Scrub(nil)
+
+}
+
+func TestDecryptFunc(t *testing.T) {
+
+	ct, salt, err := Encrypt([]byte("secret"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kept []byte
+	err = DecryptFunc(ct, salt, "pass", func(pt []byte) error {
+		if string(pt) != "secret" {
+			t.Fatalf("got %q", pt)
+		}
+		kept = pt
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(kept, make([]byte, len(kept))) {
+		t.Fatalf("plaintext not zeroed: %q", kept)
+	}
+
+	// The error of fn is returned, and the plaintext is zeroed all the same
+	stop := errors.New("stop")
+	err = DecryptFunc(ct, salt, "pass", func(pt []byte) error {
+		kept = pt
+		return stop
+	})
+	if err != stop || !bytes.Equal(kept, make([]byte, len(kept))) {
+		t.Fatalf("got %v and %q", err, kept)
+	}
+
+	if err := DecryptFunc(ct, salt, "wrong", func([]byte) error { return nil }); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+
+}
@@ -0,0 +1,191 @@
+package gocrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default rate a Scrubber reads files at, see Scrubber.MaxBytesPerSecond
+const defaultScrubRate = 32 << 20
+
+// Scrubber periodically verifies the encrypted (.3dfx) files of a directory
+// to catch bit rot in long-term archives.
+//
+// Files are checked like VerifyDir does, by authenticating their tags, and
+// nothing is written to disk. Set OnCorrupt, and optionally
+// MaxBytesPerSecond, before calling Start.
+type Scrubber struct {
+	// Function called with every file failing verification, from the
+	// scrubber's goroutine
+	OnCorrupt func(FileStatus)
+
+	// Rate files are read at, to avoid IO storms (32 MiB/s by default)
+	MaxBytesPerSecond int64
+
+	dir      string
+	salt     []byte
+	pass     string
+	opts     Options
+	interval time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Function to create a Scrubber
+//
+// Each file's key is derived from its own salt like VerifyDir does, and
+// cached so later passes don't derive it again.
+//
+// Variables to pass in:
+//
+//   dir string             - Directory to scrub (walked recursively)
+//   salt []byte            - Salt of the files with no salt of their own (nil if none)
+//   pass string            - Passphrase used to encrypt the files
+//   interval time.Duration - Time between the start of two passes
+//   opts                   - Options (optional)
+//
+// Returns:
+//
+//   *Scrubber - Scrubber
+//   error     - Error
+func NewScrubber(dir string, salt []byte, pass string, interval time.Duration, opts ...Option) (*Scrubber, error) {
+
+	if interval <= 0 {
+		return nil, errors.New("scrub interval must be positive")
+	}
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("new scrubber: options: %w", err)
+	}
+
+	// Set directly so locked Options get it too
+	o.keys = newKeyCache()
+
+	return &Scrubber{dir: dir, salt: salt, pass: pass, opts: o, interval: interval, MaxBytesPerSecond: defaultScrubRate}, nil
+
+}
+
+// Function to start scrubbing in the background
+//
+// A first pass starts right away, then one every interval until ctx is
+// done or Stop is called.
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context to stop scrubbing on
+//
+// Returns:
+//
+//   error - Error if the scrubber is already running
+func (s *Scrubber) Start(ctx context.Context) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return errors.New("scrubber already started")
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			// A failed walk is retried on the next pass
+			s.RunOnce(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(s.done)
+
+	return nil
+
+}
+
+// Function to stop scrubbing and wait for the pass under way to end
+//
+// The cached keys are wiped, they are derived again if scrubbing restarts.
+func (s *Scrubber) Stop() {
+
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	s.opts.keys.clear()
+
+}
+
+// Function to run a single pass over the directory
+//
+// OnCorrupt is called with every file failing verification as they are
+// found.
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context to stop the pass on
+//
+// Returns:
+//
+//   VerifyReport - Report of the files checked
+//   error        - Error
+func (s *Scrubber) RunOnce(ctx context.Context) (VerifyReport, error) {
+
+	paths, err := encryptedFiles(s.dir)
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("scrub: walk: %w", err)
+	}
+
+	var report VerifyReport
+	for _, path := range paths {
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		start := time.Now()
+		status := verifyFile(ctx, path, s.salt, s.pass, s.opts)
+		report.Files = append(report.Files, status)
+		report.TotalBytes += status.Size
+		if status.OK() {
+			report.Healthy++
+		} else {
+			report.Corrupted++
+			if s.OnCorrupt != nil {
+				s.OnCorrupt(status)
+			}
+		}
+
+		// Wait until reading the file took as long as the rate allows
+		if s.MaxBytesPerSecond > 0 {
+			wait := time.Duration(float64(status.Size)/float64(s.MaxBytesPerSecond)*float64(time.Second)) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return report, ctx.Err()
+				}
+			}
+		}
+
+	}
+
+	return report, nil
+
+}
@@ -0,0 +1,91 @@
+package gocrypt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScrubber(t *testing.T) {
+
+	dir := t.TempDir()
+	salt := []byte("0123456789abcdef")
+	for _, name := range []string{"a", "b", "c"} {
+		ct, err := EncryptWithSalt([]byte("data "+name), salt, "pass", testKDF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "b" {
+			ct[len(ct)-1] ^= 1
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".3dfx"), ct, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A stream with its own salt file
+	if err := os.WriteFile(filepath.Join(dir, "d"), []byte("data d"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFileStream("d", dir+"/", dir+"/", "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewScrubber(dir, salt, "pass", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxBytesPerSecond = 0
+
+	var mu sync.Mutex
+	var bad []string
+	s.OnCorrupt = func(f FileStatus) {
+		mu.Lock()
+		bad = append(bad, filepath.Base(f.Path))
+		mu.Unlock()
+	}
+
+	report, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if report.Healthy != 3 || report.Corrupted != 1 || len(bad) != 1 || bad[0] != "b.3dfx" {
+		t.Fatalf("got %d healthy, %d corrupted and %v, want 3, 1 and [b.3dfx]", report.Healthy, report.Corrupted, bad)
+	}
+
+	// Both salts are cached for the next pass
+	if n := len(s.opts.keys.keys); n != 2 {
+		t.Fatalf("%d keys cached, want 2", n)
+	}
+
+	bad = nil
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err == nil {
+		t.Fatal("started twice")
+	}
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bad) != 1 {
+		t.Fatalf("got %v, want one corrupted file", bad)
+	}
+	if n := len(s.opts.keys.keys); n != 0 {
+		t.Fatalf("%d keys left after Stop", n)
+	}
+
+}
+
+func TestNewScrubberInterval(t *testing.T) {
+
+	if _, err := NewScrubber(t.TempDir(), nil, "pass", 0); err == nil {
+		t.Fatal("zero interval accepted")
+	}
+
+}
@@ -0,0 +1,122 @@
+package gocrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Function to encrypt data into a single blob holding its salt
+//
+// The blob is the length of the salt, the salt and the output of Encrypt,
+// so it's all that needs to be stored. Decrypt it with DecryptSealed.
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Encrypted Data with its salt
+//   error  - Error
+func EncryptSealed(data []byte, pass string, opts ...Option) ([]byte, error) {
+
+	ciphertext, salt, err := Encrypt(data, pass, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 0, 1+len(salt)+len(ciphertext))
+	sealed = append(sealed, byte(len(salt)))
+	sealed = append(sealed, salt...)
+
+	return append(sealed, ciphertext...), nil
+
+}
+
+// Function to decrypt a blob made by EncryptSealed
+//
+// Variables to pass in:
+//
+//   data []byte - Encrypted Data with its salt
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptSealed(data []byte, pass string, opts ...Option) ([]byte, error) {
+
+	if len(data) < 1 || int(data[0]) == 0 || len(data) < 1+int(data[0]) {
+		return nil, errors.New("sealed data too short")
+	}
+	n := int(data[0])
+
+	return Decrypt(data[1+n:], data[1:1+n], pass, opts...)
+
+}
+
+// Function to encrypt a string into printable text
+//
+// The text is the blob of EncryptSealed in unpadded URL-safe base64, so it
+// holds its salt and can go in JSON, URLs or environment variables as is.
+// Decrypt it with DecryptString.
+//
+// Variables to pass in:
+//
+//   plaintext string - String to be encrypted
+//   pass string      - Passphrase to use for encryption
+//   opts             - Options (optional)
+//
+// Returns:
+//
+//   string - Encrypted string
+//   error  - Error
+func EncryptString(plaintext, pass string, opts ...Option) (string, error) {
+
+	data := []byte(plaintext)
+	defer Scrub(data)
+
+	sealed, err := EncryptSealed(data, pass, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+
+}
+
+// Function to decrypt a string made by EncryptString
+//
+// Surrounding whitespace (ie. a trailing newline from a file) is ignored.
+//
+// Variables to pass in:
+//
+//   armored string - Encrypted string
+//   pass string    - Passphrase to use for decryption
+//   opts           - Options (optional)
+//
+// Returns:
+//
+//   string - Decrypted string
+//   error  - Error
+func DecryptString(armored, pass string, opts ...Option) (string, error) {
+
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(armored))
+	if err != nil {
+		return "", fmt.Errorf("decrypt string: invalid base64: %w", err)
+	}
+
+	plaintext, err := DecryptSealed(sealed, pass, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer Scrub(plaintext)
+
+	return string(plaintext), nil
+
+}
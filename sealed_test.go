@@ -0,0 +1,62 @@
+package gocrypt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealed(t *testing.T) {
+
+	sealed, err := EncryptSealed([]byte("data"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := DecryptSealed(sealed, "pass", testKDF); err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+	if _, err := DecryptSealed(sealed, "wrong", testKDF); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	tests := map[string][]byte{
+		"empty":              nil,
+		"zero salt length":   {0, 1, 2, 3},
+		"salt past the data": {200, 1, 2, 3},
+		"salt only":          {1},
+	}
+	for name, data := range tests {
+		if _, err := DecryptSealed(data, "pass", testKDF); err == nil || err.Error() != "sealed data too short" {
+			t.Fatalf("%s: got %v, want sealed data too short", name, err)
+		}
+	}
+
+}
+
+func TestEncryptString(t *testing.T) {
+
+	armored, err := EncryptString("secret value", "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(armored, "+/=\n") {
+		t.Fatalf("got %q, want unpadded URL-safe base64", armored)
+	}
+
+	for _, in := range []string{armored, armored + "\n", "  " + armored + "\r\n", "\t" + armored + " "} {
+		if pt, err := DecryptString(in, "pass", testKDF); err != nil || pt != "secret value" {
+			t.Fatalf("%q: got %q, %v", in, pt, err)
+		}
+	}
+	if _, err := DecryptString(armored, "wrong", testKDF); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	for _, in := range []string{"not base64!", armored + "=", armored[:10] + " " + armored[10:]} {
+		_, err := DecryptString(in, "pass", testKDF)
+		if err == nil || !strings.Contains(err.Error(), "invalid base64") {
+			t.Fatalf("%q: got %v, want invalid base64", in, err)
+		}
+	}
+
+}
@@ -0,0 +1,57 @@
+package gocrypt
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+)
+
+// Function to check that a file can be shredded, before anything is
+// encrypted
+//
+//   path string - Path of the file
+func checkShred(path string) error {
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return errors.New("not a regular file")
+	}
+
+	return nil
+
+}
+
+// Function to overwrite a file with random bytes and remove it
+//
+// The file is overwritten in place with one pass and synced before it's
+// removed. See Options.ShredSource for the limits of this.
+//
+//   path string - Path of the file
+func shredFile(path string) error {
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err == nil {
+		_, err = io.CopyN(f, rand.Reader, fi.Size())
+	}
+	if err == nil {
+		err = syncFile(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+
+}
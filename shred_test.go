@@ -0,0 +1,142 @@
+package gocrypt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// KeyWrapper that can't store any key
+type failingKMS struct{}
+
+func (failingKMS) WrapKey(key []byte) ([]byte, error)   { return nil, errors.New("kms unavailable") }
+func (failingKMS) UnwrapKey(ref []byte) ([]byte, error) { return nil, errors.New("kms unavailable") }
+
+func TestShredSource(t *testing.T) {
+
+	src, dst := t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.txt", "secret data")
+
+	// Record each sync, and what the source held at that point
+	var synced []string
+	orig := syncFile
+	defer func() { syncFile = orig }()
+	syncFile = func(s interface{ Sync() error }) error {
+		f := s.(*os.File)
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(src + "a.txt")
+		if err != nil {
+			t.Fatalf("source gone while syncing %s: %v", f.Name(), err)
+		}
+		if f.Name() != src+"a.txt" && string(data) != "secret data" {
+			t.Fatalf("source overwritten before %s was synced", f.Name())
+		}
+		if f.Name() == src+"a.txt" && string(data) == "secret data" {
+			t.Fatal("source synced without being overwritten")
+		}
+		synced = append(synced, f.Name())
+		return nil
+	}
+
+	kms := &fakeKMS{keys: make(map[string][]byte)}
+	err := EncryptFile("a.txt", src, dst, "pass", testKDF, WithShredSource(true), WithKeyWrapper(kms), WithDetachedMAC(true))
+	syncFile = orig
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{dst + "a.txt.3dfx", dst + "a.txt.salt", pepperPath(dst + "a.txt.3dfx"), macPath(dst + "a.txt.3dfx"), src + "a.txt"}
+	if !reflect.DeepEqual(synced, want) {
+		t.Fatalf("got syncs %q, want %q", synced, want)
+	}
+	if _, err := os.Stat(src + "a.txt"); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want the source removed", err)
+	}
+
+	out := t.TempDir() + "/"
+	if err := DecryptFile("a.txt", dst, out, "pass", testKDF, WithKeyWrapper(kms), WithDetachedMAC(true)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(out + "a.txt"); err != nil || string(data) != "secret data" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+}
+
+func TestShredSourceKeptOnFailure(t *testing.T) {
+
+	tests := map[string]struct {
+		to   func(t *testing.T, dst string) string
+		opts []Option
+	}{
+		"unwritable destination": {
+			to: func(t *testing.T, dst string) string { return dst + "missing/" },
+		},
+		"salt file can't be created": {
+			to: func(t *testing.T, dst string) string {
+				if err := os.Mkdir(dst+"a.txt.salt", 0755); err != nil {
+					t.Fatal(err)
+				}
+				return dst
+			},
+		},
+		"failing KeyWrapper": {
+			to:   func(t *testing.T, dst string) string { return dst },
+			opts: []Option{WithKeyWrapper(failingKMS{})},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			src, dst := t.TempDir()+"/", t.TempDir()+"/"
+			writeTestFile(t, src+"a.txt", "secret data")
+			opts := append([]Option{testKDF, WithShredSource(true)}, tt.opts...)
+			if err := EncryptFile("a.txt", src, tt.to(t, dst), "pass", opts...); err == nil {
+				t.Fatal("got nil, want an error")
+			}
+			if data, err := os.ReadFile(src + "a.txt"); err != nil || string(data) != "secret data" {
+				t.Fatalf("got %q, %v, want the source kept", data, err)
+			}
+
+		})
+	}
+
+}
+
+func TestCheckShred(t *testing.T) {
+
+	dir := t.TempDir() + "/"
+	writeTestFile(t, dir+"a.txt", "secret data")
+	if err := os.Symlink(dir+"a.txt", dir+"link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dir+"sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkShred(dir + "a.txt"); err != nil {
+		t.Fatalf("got %v, want a regular file accepted", err)
+	}
+	for _, name := range []string{"link", "sub", "missing"} {
+		if err := checkShred(dir + name); err == nil {
+			t.Fatalf("got nil, want %s refused", name)
+		}
+	}
+
+	// Nothing is encrypted or shredded through a symlink
+	dst := t.TempDir() + "/"
+	if err := EncryptFile("link", dir, dst, "pass", testKDF, WithShredSource(true)); err == nil {
+		t.Fatal("got nil, want the symlink refused")
+	}
+	if data, err := os.ReadFile(dir + "a.txt"); err != nil || string(data) != "secret data" {
+		t.Fatalf("got %q, %v, want the target kept", data, err)
+	}
+	if matches, _ := filepath.Glob(dst + "*"); len(matches) != 0 {
+		t.Fatalf("got %q, want no output", matches)
+	}
+
+}
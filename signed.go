@@ -0,0 +1,124 @@
+package gocrypt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Context prefixed to everything signed by SealSigned
+const signedContext = "gocrypt signed v1"
+
+// Function to build the message signed for a signed blob
+//
+//   salt       []byte - Salt used to encrypt
+//   ciphertext []byte - Encrypted data
+func signedMessage(salt, ciphertext []byte) []byte {
+	msg := make([]byte, 0, len(signedContext)+2+len(salt)+len(ciphertext))
+	msg = append(msg, signedContext...)
+	msg = append(msg, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], uint16(len(salt)))
+	msg = append(msg, salt...)
+	return append(msg, ciphertext...)
+}
+
+// Function to encrypt data and sign the result
+//
+// The ciphertext and salt are signed after encryption (encrypt-then-sign)
+// and the signature is embedded in front of the ciphertext. Ed25519, ECDSA
+// and RSA (PKCS #1 v1.5) signers are supported.
+//
+// Variables to pass in:
+//
+//   data []byte          - Data to be encrypted
+//   pass string          - Passphrase to use for encryption
+//   signer crypto.Signer - Key to sign with
+//
+// Returns:
+//
+//   []byte - Signed encrypted data
+//   []byte - Salt
+//   error  - Error
+func SealSigned(data []byte, pass string, signer crypto.Signer) ([]byte, []byte, error) {
+
+	ciphertext, salt, err := Encrypt(data, pass)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg := signedMessage(salt, ciphertext)
+	var sig []byte
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		sig, err = signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(msg)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("seal signed: sign: %w", err)
+	}
+
+	if len(sig) > 0xffff {
+		return nil, nil, errors.New("signature too large")
+	}
+
+	out := make([]byte, 2, 2+len(sig)+len(ciphertext))
+	binary.BigEndian.PutUint16(out, uint16(len(sig)))
+	out = append(out, sig...)
+	out = append(out, ciphertext...)
+
+	return out, salt, nil
+
+}
+
+// Function to verify signed encrypted data and decrypt it
+//
+// Nothing is decrypted unless the signature is valid.
+//
+// Variables to pass in:
+//
+//   data []byte          - Signed encrypted data
+//   salt []byte          - Salt to use to create hash
+//   pass string          - Passphrase to use for decryption
+//   pub crypto.PublicKey - Public key of the signer
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func OpenSigned(data, salt []byte, pass string, pub crypto.PublicKey) ([]byte, error) {
+
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data)) {
+		return nil, errors.New("signed data too short")
+	}
+	n := 2 + int(binary.BigEndian.Uint16(data))
+	sig, ciphertext := data[2:n], data[n:]
+
+	msg := signedMessage(salt, ciphertext)
+	digest := sha256.Sum256(msg)
+
+	var ok bool
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, msg, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, digest[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	if !ok {
+		return nil, errors.New("invalid signature")
+	}
+
+	return Decrypt(ciphertext, salt, pass)
+
+}
@@ -0,0 +1,54 @@
+package gocrypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSealSigned(t *testing.T) {
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, salt, err := SealSigned([]byte("data"), "pass", priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := OpenSigned(ct, salt, "pass", pub)
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	// Signed by someone else
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSigned(ct, salt, "pass", other); err == nil {
+		t.Fatal("opened with the wrong public key")
+	}
+
+	// Tampered ciphertext fails the signature before decryption
+	tampered := append([]byte(nil), ct...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := OpenSigned(tampered, salt, "pass", pub); err == nil {
+		t.Fatal("opened tampered ciphertext")
+	}
+
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, salt, err = SealSigned([]byte("data"), "pass", ek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := OpenSigned(ct, salt, "pass", &ek.PublicKey); err != nil || string(pt) != "data" {
+		t.Fatalf("ECDSA: got %q, %v", pt, err)
+	}
+
+}
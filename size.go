@@ -0,0 +1,69 @@
+package gocrypt
+
+// Size of the random salt generated for each encryption
+const saltSize = 16
+
+// Function to compute the size of the stream EncryptStream writes for a
+// given amount of plaintext
+//
+// The size accounts for the header, the frames (with their nonces unless
+// they are derived), the salt when it's stored in a trailer and the parity
+// when it's enabled.
+//
+// Variables to pass in:
+//
+//   plaintextSize int64 - Size of the plaintext in bytes
+//   opts Options        - Options the stream will be encrypted with
+//
+// Returns:
+//
+//   int64 - Size of the encrypted stream in bytes
+func CiphertextSize(plaintextSize int64, opts Options) int64 {
+
+	if opts.MinimalOverhead {
+		return 1 + 12 + plaintextSize + 16
+	}
+
+	info := Info{Version: FormatVersion, Algorithm: opts.Cipher.effective(), ChunkSize: opts.chunk(), Parity: opts.Parity, NotBefore: opts.NotBefore, Purpose: opts.Purpose, HashChain: opts.HashChain, Comment: opts.Comment, KDF: opts.KDF, StreamID: opts.StreamID, Canary: opts.Canary, Length: opts.length}
+	if opts.DerivedNonces {
+		info.NonceBase = make([]byte, nonceBaseSize)
+	}
+	switch opts.SaltPosition {
+	case SaltHeader:
+		info.Salt = make([]byte, opts.KDF.resolve().SaltLen)
+	case SaltTrailer:
+		info.SaltTrailer = true
+	}
+	size := int64(len(marshalHeader(info)))
+	if opts.Canary {
+		size += canarySize
+	}
+
+	nonceSize := int64(12)
+	if opts.DerivedNonces {
+		nonceSize = 0
+	}
+	frameSize := func(n int64) int64 {
+		f := frameHeaderSize + nonceSize + n + 16
+		if opts.Parity.enabled() {
+			f = opts.Parity.blockSize(f)
+		}
+		return f
+	}
+
+	// Every chunk is a frame, the last one holding the rest (or nothing when
+	// the plaintext is empty)
+	chunk := int64(opts.chunk())
+	full := int64(0)
+	if plaintextSize > 0 {
+		full = (plaintextSize - 1) / chunk
+	}
+	size += full*frameSize(chunk) + frameSize(plaintextSize-full*chunk)
+
+	if opts.SaltPosition == SaltTrailer {
+		size += int64(opts.KDF.resolve().SaltLen) + 1
+	}
+
+	return size
+
+}
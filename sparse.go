@@ -0,0 +1,56 @@
+package gocrypt
+
+import (
+	"io"
+	"os"
+)
+
+// Size of the blocks checked for zeros when writing a sparse file
+const sparseBlockSize = 4096
+
+// Function to write data to a file, seeking past blocks of zeros instead of
+// writing them so the filesystem can leave holes
+//
+// The file is truncated to its full size at the end, so trailing zeros are
+// kept logically even though they are never written.
+//
+//   f    *os.File - File to write to (positioned at the start)
+//   data []byte   - Data to write
+func writeSparse(f *os.File, data []byte) error {
+
+	for off := 0; off < len(data); off += sparseBlockSize {
+
+		end := off + sparseBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		block := data[off:end]
+
+		if isZero(block) {
+			if _, err := f.Seek(int64(len(block)), io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := f.Write(block); err != nil {
+			return err
+		}
+
+	}
+
+	return f.Truncate(int64(len(data)))
+
+}
+
+// Function to check if a slice is all zeros
+//
+//   b []byte - Slice to check
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package gocrypt
+
+import "syscall"
+
+// Function to get the space a file takes on disk
+//
+//   path string - Path of the file
+func diskUsage(path string) (int64, bool) {
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, false
+	}
+
+	return st.Blocks * 512, true
+
+}
@@ -0,0 +1,41 @@
+package gocrypt
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSparseOutput(t *testing.T) {
+
+	// Zero regions in the middle and at the end, and a block only partly
+	// zero
+	data := make([]byte, 4<<20)
+	copy(data[100:], "start")
+	copy(data[2<<20:], "middle")
+	data[2<<20+sparseBlockSize-1] = 1
+
+	dir := t.TempDir() + "/"
+	salt, err := EncryptToFile(dir+"image.3dfx", "", data, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir+"image.salt", string(salt))
+
+	for _, sparse := range []bool{false, true} {
+		out := t.TempDir() + "/"
+		if err := DecryptFile("image", dir, out, "pass", WithSparseOutput(sparse)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(out + "image")
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("sparse %t: got %d bytes, %v, want the %d bytes encrypted", sparse, len(got), err, len(data))
+		}
+		if sparse {
+			if used, ok := diskUsage(out + "image"); ok && used >= int64(len(data))/2 {
+				t.Fatalf("%d bytes on disk for %d bytes of mostly zeros", used, len(data))
+			}
+		}
+	}
+
+}
@@ -0,0 +1,162 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Blobstore is a storage backend encrypted blobs can be persisted to.
+//
+// Implement it to target object stores like S3 or GCS; FileStore stores
+// blobs on the local filesystem.
+type Blobstore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, data []byte) error
+}
+
+// Function to encrypt data and persist it in a Blobstore
+//
+// The blob is a stream with its salt stored in the header, so it can be
+// decrypted with the passphrase alone.
+//
+// Variables to pass in:
+//
+//   store Blobstore - Storage backend
+//   key   string    - Key to store the blob under
+//   data  []byte    - Data to be encrypted
+//   pass  string    - Passphrase to use for encryption
+//   opts            - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func EncryptToStore(store Blobstore, key string, data []byte, pass string, opts ...Option) error {
+
+	var blob bytes.Buffer
+	opts = append(opts[:len(opts):len(opts)], WithSaltPosition(SaltHeader))
+	if _, err := EncryptStream(&blob, bytes.NewReader(data), pass, opts...); err != nil {
+		return err
+	}
+
+	if err := store.Put(key, blob.Bytes()); err != nil {
+		return fmt.Errorf("encrypt to store: put: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to decrypt a blob persisted in a Blobstore
+//
+// Variables to pass in:
+//
+//   store Blobstore - Storage backend
+//   key   string    - Key the blob is stored under
+//   pass  string    - Passphrase to use for decryption
+//   opts            - Options (optional)
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptFromStore(store Blobstore, key string, pass string, opts ...Option) ([]byte, error) {
+
+	blob, err := store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt from store: get: %w", err)
+	}
+
+	var plaintext bytes.Buffer
+	if err := DecryptStream(&plaintext, bytes.NewReader(blob), nil, pass, opts...); err != nil {
+		return nil, err
+	}
+
+	return plaintext.Bytes(), nil
+
+}
+
+// FileStore is a Blobstore keeping each blob in a file under Dir
+type FileStore struct {
+	Dir string // Directory the blobs are stored in
+}
+
+// Function to get the path of the file a key is stored in
+//
+//   key string - Key of the blob
+func (s FileStore) path(key string) (string, error) {
+
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", errors.New("invalid blob key")
+	}
+
+	return filepath.Join(s.Dir, key), nil
+
+}
+
+// Function to get a blob from the store
+//
+// Variables to pass in:
+//
+//   key string - Key of the blob
+//
+// Returns:
+//
+//   []byte - Blob
+//   error  - Error
+func (s FileStore) Get(key string) ([]byte, error) {
+
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(p)
+
+}
+
+// Function to put a blob in the store
+//
+// The blob is written to a temporary file which is then renamed, so a
+// crash never leaves a partial blob under the key.
+//
+// Variables to pass in:
+//
+//   key  string - Key of the blob
+//   data []byte - Blob
+//
+// Returns:
+//
+//   error - Error
+func (s FileStore) Put(key string, data []byte) error {
+
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.Dir, "."+key+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+
+}
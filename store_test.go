@@ -0,0 +1,62 @@
+package gocrypt
+
+import (
+	"errors"
+	"testing"
+)
+
+// In-memory Blobstore
+type memStore map[string][]byte
+
+func (m memStore) Get(key string) ([]byte, error) {
+
+	data, ok := m[key]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+
+	return data, nil
+
+}
+
+func (m memStore) Put(key string, data []byte) error {
+
+	m[key] = data
+
+	return nil
+
+}
+
+func TestStore(t *testing.T) {
+
+	for _, store := range []Blobstore{memStore{}, FileStore{Dir: t.TempDir()}} {
+		if err := EncryptToStore(store, "blob", []byte("data"), "pass", testKDF); err != nil {
+			t.Fatal(err)
+		}
+		pt, err := DecryptFromStore(store, "blob", "pass")
+		if err != nil || string(pt) != "data" {
+			t.Fatalf("%T: got %q, %v", store, pt, err)
+		}
+		if _, err := DecryptFromStore(store, "blob", "wrong"); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("%T: got %v, want ErrAuthFailed", store, err)
+		}
+		if _, err := DecryptFromStore(store, "missing", "pass"); err == nil {
+			t.Fatalf("%T: decrypted a missing blob", store)
+		}
+	}
+
+}
+
+func TestFileStoreKeys(t *testing.T) {
+
+	store := FileStore{Dir: t.TempDir()}
+	for _, key := range []string{"", ".", "..", "../x", "a/b", `a\b`} {
+		if err := store.Put(key, []byte("data")); err == nil {
+			t.Errorf("%q: accepted", key)
+		}
+		if _, err := store.Get(key); err == nil {
+			t.Errorf("%q: accepted", key)
+		}
+	}
+
+}
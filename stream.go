@@ -0,0 +1,1064 @@
+package gocrypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Error returned when a stream goes over the limits set in Options
+var ErrTooLarge = errors.New("stream too large")
+
+// Error returned when data or a stream ends before all of it was read, ie.
+// a file cut short while it was written or copied
+var ErrTruncated = errors.New("truncated")
+
+// Error returned when a stream is decrypted before its NotBefore time
+var ErrNotYetValid = errors.New("stream not yet valid")
+
+// Size of the plaintext sealed into each frame of a stream, unless
+// Options.ChunkSize says otherwise
+const chunkSize = 64 * 1024
+
+// Bounds of Options.ChunkSize
+const (
+	minChunkSize = 1024
+	maxChunkSize = 16 * 1024 * 1024
+)
+
+// Size of a frame header (flags + length of the nonce and sealed data)
+const frameHeaderSize = 1 + 4
+
+// Flag marking the last frame of a stream
+const frameFinal byte = 1
+
+// Size of the frame specific part of the additional data authenticated with
+// a frame (counter + flags)
+const frameADSize = 8 + 1
+
+// Size of the tag of the previous frame authenticated with a frame when the
+// stream is hash chained
+const chainSize = 16
+
+// Function to allocate the buffer for the additional data of frames
+//
+//   header []byte - Raw header of the stream
+//   chain  bool   - Whether the stream is hash chained
+func newFrameAD(header []byte, chain bool) []byte {
+	n := len(header) + frameADSize
+	if chain {
+		n += chainSize
+	}
+	ad := make([]byte, n)
+	copy(ad, header)
+	return ad
+}
+
+// Function to get the part of the additional data holding the tag of the
+// previous frame, nil when the stream isn't hash chained
+//
+// The tag of each frame is copied there once it's sealed or opened, so
+// every frame authenticates the one before it (the first one authenticates
+// zeros). Changing or dropping a frame breaks every frame after it.
+//
+//   ad     []byte - Buffer from newFrameAD
+//   header []byte - Raw header of the stream
+//   chain  bool   - Whether the stream is hash chained
+func chainSlot(ad, header []byte, chain bool) []byte {
+	if !chain {
+		return nil
+	}
+	return ad[len(header) : len(header)+chainSize]
+}
+
+// Function to build the additional data authenticated with a frame.
+//
+// Binding the stream header prevents it from being tampered with, binding
+// the frame counter prevents frames from being reordered or dropped and
+// binding the flags prevents a stream from being cut short at a frame
+// boundary.
+//
+//   ad      []byte - Buffer from newFrameAD to build it in
+//   counter uint64 - Index of the frame in the stream
+//   flags   byte   - Frame flags
+func frameAD(ad []byte, counter uint64, flags byte) []byte {
+	n := len(ad) - frameADSize
+	binary.BigEndian.PutUint64(ad[n:], counter)
+	ad[n+8] = flags
+	return ad
+}
+
+// Function to derive the nonce of a frame from the stream's nonce base
+//
+// The nonce is the 64-bit base followed by the 32-bit frame counter, so
+// every frame of a stream gets a distinct nonce without storing it.
+//
+//   nonce   []byte - Buffer to build the nonce in
+//   base    []byte - Nonce base stored in the header
+//   counter uint64 - Index of the frame in the stream
+func deriveNonce(nonce, base []byte, counter uint64) ([]byte, error) {
+
+	if counter > math.MaxUint32 {
+		return nil, errors.New("too many frames for derived nonces")
+	}
+
+	copy(nonce, base)
+	binary.BigEndian.PutUint32(nonce[nonceBaseSize:], uint32(counter))
+
+	return nonce, nil
+
+}
+
+// Function to seal a chunk of plaintext into a frame
+//
+// The frame is built in dst, which must not overlap chunk and should have
+// room for the frame header, nonce, chunk and tag to avoid an allocation.
+// When nonceBase is set the nonce is derived from it and not stored in the
+// frame.
+//
+//   dst       []byte      - Buffer to build the frame in
+//   aead      cipher.AEAD - AEAD to seal with
+//   ad        []byte      - Buffer for the additional data
+//   nonceBase []byte      - Nonce base of the stream (nil for random nonces)
+//   counter   uint64      - Index of the frame in the stream
+//   flags     byte        - Frame flags
+//   chunk     []byte      - Plaintext to seal
+func sealFrame(dst []byte, aead cipher.AEAD, ad, nonceBase []byte, counter uint64, flags byte, chunk []byte) ([]byte, error) {
+
+	var frame, nonce []byte
+	if nonceBase != nil {
+		frame = append(dst[:0], make([]byte, frameHeaderSize)...)
+		n, err := deriveNonce(make([]byte, aead.NonceSize()), nonceBase, counter)
+		if err != nil {
+			return nil, err
+		}
+		nonce = n
+	} else {
+		frame = append(dst[:0], make([]byte, frameHeaderSize+aead.NonceSize())...)
+		nonce = frame[frameHeaderSize:]
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	frame = aead.Seal(frame, nonce, chunk, frameAD(ad, counter, flags))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:frameHeaderSize], uint32(len(frame)-frameHeaderSize))
+
+	return frame, nil
+
+}
+
+// Function to get a buffer of n bytes, from the pool if one is set
+//
+//   pool *sync.Pool - Pool of *[]byte buffers (optional)
+//   n    int        - Size of the buffer
+func getBuffer(pool *sync.Pool, n int) *[]byte {
+
+	if pool != nil {
+		if b, ok := pool.Get().(*[]byte); ok && cap(*b) >= n {
+			*b = (*b)[:n]
+			return b
+		}
+	}
+
+	b := make([]byte, n)
+	return &b
+
+}
+
+// Function to wipe a buffer and hand it back to the pool if one is set
+//
+//   pool *sync.Pool - Pool of *[]byte buffers (optional)
+//   b    *[]byte    - Buffer from getBuffer
+func putBuffer(pool *sync.Pool, b *[]byte) {
+
+	Scrub(*b)
+	if pool != nil {
+		pool.Put(b)
+	}
+
+}
+
+// Metadata about an encrypted stream
+type Meta struct {
+	Salt   []byte // Salt used to encrypt
+	Size   int64  // Size of the plaintext in bytes
+	Digest []byte // SHA-256 of the plaintext (only set when Options.Digest is)
+
+	// Tag of the last frame of a hash chained stream, which authenticates
+	// every frame before it. Keep it somewhere else to later check the
+	// stream wasn't rewritten as a whole (only set when Options.HashChain is).
+	ChainHead []byte
+}
+
+// Function to encrypt a stream of data
+//
+// The data is read and sealed in 64 KiB frames (see Options.ChunkSize) so the whole input never has
+// to fit in memory. Each frame has its own nonce and authenticates its
+// position in the stream so reordered, dropped or truncated frames are
+// detected on decryption.
+//
+// Variables to pass in:
+//
+//   dst io.Writer - Writer to output the encrypted stream to
+//   src io.Reader - Reader to get the data to be encrypted from
+//   pass string   - Passphrase to use for encryption
+//   opts          - Options (optional)
+//
+// Returns:
+//
+//   Meta  - Salt and details of the plaintext
+//   error - Error
+func EncryptStream(dst io.Writer, src io.Reader, pass string, opts ...Option) (Meta, error) {
+	return EncryptStreamContext(context.Background(), dst, src, pass, opts...)
+}
+
+// Function to encrypt a stream of data, stopping if ctx is done
+//
+// ctx is checked before each chunk is read (and while waiting for a key
+// derivation slot), and its error returned as soon as it's done. The
+// stream written so far is incomplete and must be discarded.
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context
+//   dst io.Writer       - Writer to output the encrypted stream to
+//   src io.Reader       - Reader to get the data to be encrypted from
+//   pass string         - Passphrase to use for encryption
+//   opts                - Options (optional)
+//
+// Returns:
+//
+//   Meta  - Salt and details of the plaintext
+//   error - Error
+func EncryptStreamContext(ctx context.Context, dst io.Writer, src io.Reader, pass string, opts ...Option) (Meta, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return Meta{}, fmt.Errorf("encrypt stream: options: %w", err)
+	}
+
+	start := time.Now()
+	var meta Meta
+	if err = ctx.Err(); err != nil {
+		return Meta{}, err
+	}
+	if o.MinimalOverhead {
+		meta, err = encryptMinimal(dst, src, pass, o)
+	} else {
+		meta, err = encryptStream(ctx, dst, dst, src, pass, o.SaltPosition, o)
+	}
+	o.observe(start, meta.Size, err)
+
+	return meta, err
+
+}
+
+// Function to encrypt a stream of data, writing the header separately
+//
+//   ctx       context.Context - Context
+//   dst       io.Writer       - Writer to output the frames to
+//   headerDst io.Writer       - Writer to output the header to
+//   src       io.Reader       - Reader to get the data to be encrypted from
+//   pass      string          - Passphrase to use for encryption
+//   saltPos   SaltPosition    - Where to store the salt
+//   o         Options         - Options in use
+func encryptStream(ctx context.Context, dst, headerDst io.Writer, src io.Reader, pass string, saltPos SaltPosition, o Options) (Meta, error) {
+
+	fw, err := newFrameWriter(ctx, dst, headerDst, pass, saltPos, o)
+	if err != nil {
+		return Meta{}, err
+	}
+	defer fw.close()
+
+	// Hash the plaintext as it goes through so no second pass is needed
+	meta := Meta{Salt: fw.salt}
+	var digest hash.Hash
+	if o.Digest {
+		digest = sha256.New()
+	}
+
+	var cr interface {
+		next(buf []byte) (int, bool, error)
+	} = &chunkReader{r: src}
+	if o.FlushInterval > 0 {
+		tcr := newTimedChunkReader(src, o.FlushInterval)
+		defer tcr.stop()
+		cr = tcr
+	}
+	for {
+
+		if err := ctx.Err(); err != nil {
+			return Meta{}, err
+		}
+
+		n, final, err := cr.next(fw.plain)
+		if err != nil {
+			return Meta{}, fmt.Errorf("encrypt stream: read: %w", err)
+		}
+
+		meta.Size += int64(n)
+		if o.length > 0 && (meta.Size > o.length || final && meta.Size != o.length) {
+			return Meta{}, errors.New("encrypt stream: source changed size while reading")
+		}
+		if digest != nil {
+			digest.Write(fw.plain[:n])
+		}
+
+		if err := fw.writeFrame(fw.plain[:n], final); err != nil {
+			return Meta{}, err
+		}
+		o.progress(meta.Size, o.length)
+		if o.FlushInterval > 0 && !final {
+			if err := flushOutput(dst); err != nil {
+				return Meta{}, fmt.Errorf("encrypt stream: flush: %w", err)
+			}
+		}
+
+		if final {
+			if digest != nil {
+				meta.Digest = digest.Sum(nil)
+			}
+			if fw.chain != nil {
+				meta.ChainHead = append([]byte(nil), fw.chain...)
+			}
+			return meta, nil
+		}
+
+	}
+
+}
+
+// Writer sealing frames of a stream one at a time
+type frameWriter struct {
+	dst       io.Writer // Writer the stream goes to
+	headerDst io.Writer // Writer the header went to
+	out       io.Writer // Writer the frames go to (dst, through parity if enabled)
+	aead      cipher.AEAD
+	release   func()
+	ad        []byte
+	chain     []byte // Tag of the previous frame in ad (hash chained streams)
+	nonceBase []byte
+	salt      []byte
+	saltPos   SaltPosition
+	counter   uint64
+	o         Options
+
+	// One buffer holds a plaintext chunk followed by the frame being sealed
+	// so nothing is allocated per frame. The two halves never overlap as
+	// GCM requires.
+	b      *[]byte
+	plain  []byte // Room for a chunk of plaintext
+	sealed []byte // Room for a sealed frame
+}
+
+// Function to derive the key of a new stream and write its header
+//
+//   ctx       context.Context - Context
+//   dst       io.Writer       - Writer to output the frames to
+//   headerDst io.Writer       - Writer to output the header to
+//   pass      string          - Passphrase to use for encryption
+//   saltPos   SaltPosition    - Where to store the salt
+//   o         Options         - Options in use
+func newFrameWriter(ctx context.Context, dst, headerDst io.Writer, pass string, saltPos SaltPosition, o Options) (*frameWriter, error) {
+
+	if c := o.chunk(); c < minChunkSize || c > maxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between %d and %d bytes", minChunkSize, maxChunkSize)
+	}
+	if err := validateComment(o.Comment); err != nil {
+		return nil, fmt.Errorf("encrypt stream: options: %w", err)
+	}
+	if err := o.KDF.validate(); err != nil {
+		return nil, fmt.Errorf("encrypt stream: options: %w", err)
+	}
+	if len(o.StreamID) > maxStreamIDSize {
+		return nil, fmt.Errorf("stream id longer than %d bytes", maxStreamIDSize)
+	}
+	if o.Compression {
+		return nil, errors.New("compression is not supported for streams")
+	}
+
+	if err := o.checkPassphrase(pass); err != nil {
+		return nil, fmt.Errorf("encrypt stream: %w", err)
+	}
+
+	salt, key, err := createHashContext(ctx, nil, pass, o)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, release, err := newAEAD(o.Cipher, key, o.keys)
+	Scrub(key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt stream: cipher: %w", err)
+	}
+
+	fw := &frameWriter{dst: dst, headerDst: headerDst, out: dst, aead: aead, release: release, salt: salt, saltPos: saltPos, o: o}
+
+	info := Info{Version: FormatVersion, Algorithm: o.Cipher.effective(), ChunkSize: o.chunk(), Parity: o.Parity, NotBefore: o.NotBefore, Purpose: o.Purpose, HashChain: o.HashChain, Comment: o.Comment, KDF: o.KDF, StreamID: o.StreamID, Canary: o.Canary, Length: o.length}
+	if o.DerivedNonces {
+		info.NonceBase = make([]byte, nonceBaseSize)
+		if _, err := io.ReadFull(rand.Reader, info.NonceBase); err != nil {
+			release()
+			return nil, fmt.Errorf("encrypt stream: nonce: %w", err)
+		}
+	}
+	switch saltPos {
+	case SaltHeader:
+		info.Salt = salt
+	case SaltTrailer:
+		info.SaltTrailer = true
+	}
+	if o.name != "" {
+		if info.sealedName, err = sealName(aead, o.name); err != nil {
+			release()
+			return nil, fmt.Errorf("encrypt stream: name: %w", err)
+		}
+	}
+	header := marshalHeader(info)
+	if _, err := headerDst.Write(header); err != nil {
+		release()
+		return nil, fmt.Errorf("encrypt stream: write: %w", err)
+	}
+	if o.Canary {
+		canary, err := sealCanary(aead, header)
+		if err == nil {
+			_, err = headerDst.Write(canary)
+		}
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("encrypt stream: canary: %w", err)
+		}
+	}
+	fw.ad = newFrameAD(header, o.HashChain)
+	fw.chain = chainSlot(fw.ad, header, o.HashChain)
+	fw.nonceBase = info.NonceBase
+
+	// Frames go through the parity writer one at a time
+	if o.Parity.enabled() {
+		fw.out, err = newParityWriter(dst, o.Parity)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("encrypt stream: parity: %w", err)
+		}
+	}
+
+	chunk := o.chunk()
+	frameSize := frameHeaderSize + aead.NonceSize() + chunk + aead.Overhead()
+	fw.b = getBuffer(o.BufferPool, chunk+frameSize)
+	fw.plain, fw.sealed = (*fw.b)[:chunk], (*fw.b)[chunk:chunk+frameSize]
+
+	return fw, nil
+
+}
+
+// Function to seal and write the next frame
+//
+// Once the final frame is written the trailing salt, if any, follows and
+// the output is synced.
+//
+//   chunk []byte - Plaintext of the frame (at most the chunk size)
+//   final bool   - Whether this is the last frame of the stream
+func (fw *frameWriter) writeFrame(chunk []byte, final bool) error {
+
+	var flags byte
+	if final {
+		flags = frameFinal
+	}
+
+	frame, err := sealFrame(fw.sealed, fw.aead, fw.ad, fw.nonceBase, fw.counter, flags, chunk)
+	if err != nil {
+		return fmt.Errorf("encrypt stream: seal: %w", err)
+	}
+	fw.counter++
+	copy(fw.chain, frame[len(frame)-fw.aead.Overhead():])
+
+	if _, err := fw.out.Write(frame); err != nil {
+		return fmt.Errorf("encrypt stream: write: %w", err)
+	}
+
+	if !final {
+		return nil
+	}
+
+	if fw.saltPos == SaltTrailer {
+		if _, err := fw.dst.Write(append(fw.salt, byte(len(fw.salt)))); err != nil {
+			return fmt.Errorf("encrypt stream: write: %w", err)
+		}
+	}
+	if err := syncOutput(fw.dst, fw.o); err != nil {
+		return fmt.Errorf("encrypt stream: sync: %w", err)
+	}
+	if fw.headerDst != fw.dst {
+		if err := syncOutput(fw.headerDst, fw.o); err != nil {
+			return fmt.Errorf("encrypt stream: sync: %w", err)
+		}
+	}
+
+	return nil
+
+}
+
+// Function to release the AEAD and buffer of a frameWriter
+func (fw *frameWriter) close() {
+	fw.release()
+	putBuffer(fw.o.BufferPool, fw.b)
+}
+
+// Reader splitting its input in chunks and telling which one is the last
+//
+// After a full chunk one more byte is read ahead, so the last chunk is known
+// even when the input is a multiple of the chunk size. An input of up to one
+// chunk is then always sealed into a single frame, and a stream never ends
+// with an empty frame unless the input is empty.
+type chunkReader struct {
+	r     io.Reader
+	ahead [1]byte
+	n     int  // Number of bytes read ahead
+	eof   bool // The reader has no more data after what was read ahead
+}
+
+// Function to fill the next chunk
+//
+//   buf []byte - Chunk to fill
+func (c *chunkReader) next(buf []byte) (int, bool, error) {
+
+	n := copy(buf, c.ahead[:c.n])
+	c.ahead[0], c.n = 0, 0
+	if c.eof {
+		return n, true, nil
+	}
+
+	m, final, err := readChunk(c.r, buf[n:])
+	n += m
+	if err != nil || final {
+		return n, final, err
+	}
+
+	c.n, c.eof, err = readChunk(c.r, c.ahead[:])
+	if err != nil {
+		return n, false, err
+	}
+
+	return n, c.n == 0 && c.eof, nil
+
+}
+
+// Number of reads returning no data and no error tolerated in a row
+const maxEmptyReads = 100
+
+// Function to fill a chunk from a reader
+//
+// Short reads are retried until the chunk is full. Only a clean io.EOF ends
+// the data, a final short chunk is valid. Any other error is returned as is,
+// including an io.ErrUnexpectedEOF coming from the reader itself (e.g. a cut
+// network connection), so a truncated source is never sealed as complete.
+//
+//   r   io.Reader - Reader to get the data from
+//   buf []byte    - Chunk to fill
+func readChunk(r io.Reader, buf []byte) (int, bool, error) {
+
+	n, empty := 0, 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err == io.EOF {
+			return n, true, nil
+		}
+		if err != nil {
+			return n, false, err
+		}
+		if m > 0 {
+			empty = 0
+		} else if empty++; empty >= maxEmptyReads {
+			return n, false, io.ErrNoProgress
+		}
+	}
+
+	return n, false, nil
+
+}
+
+// Function to decrypt a stream of data
+//
+// Variables to pass in:
+//
+//   dst io.Writer - Writer to output the decrypted data to
+//   src io.Reader - Reader to get the encrypted stream from
+//   salt []byte   - Salt to use to create hash
+//   pass string   - Passphrase to use for decryption
+//   opts          - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptStream(dst io.Writer, src io.Reader, salt []byte, pass string, opts ...Option) error {
+	return DecryptStreamContext(context.Background(), dst, src, salt, pass, opts...)
+}
+
+// Function to decrypt a stream of data, stopping if ctx is done
+//
+// ctx is checked before each frame is read (and while waiting for a key
+// derivation slot), and its error returned as soon as it's done. Data
+// already written to dst must then be discarded.
+//
+// Variables to pass in:
+//
+//   ctx context.Context - Context
+//   dst io.Writer       - Writer to output the decrypted data to
+//   src io.Reader       - Reader to get the encrypted stream from
+//   salt []byte         - Salt to use to create hash
+//   pass string         - Passphrase to use for decryption
+//   opts                - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptStreamContext(ctx context.Context, dst io.Writer, src io.Reader, salt []byte, pass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("decrypt stream: options: %w", err)
+	}
+
+	out := dst
+	var text *textWriter
+	if o.TextMode {
+		text = newTextWriter(dst, o.LineEnding)
+		out = text
+	}
+
+	err = decryptEachFrame(ctx, src, salt, pass, func(frame []byte) error {
+		_, err := out.Write(frame)
+		return err
+	}, o)
+	if err != nil {
+		return err
+	}
+
+	if text != nil {
+		if err := text.Close(); err != nil {
+			return fmt.Errorf("decrypt stream: text: %w", err)
+		}
+	}
+
+	if err := syncOutput(dst, o); err != nil {
+		return fmt.Errorf("decrypt stream: sync: %w", err)
+	}
+
+	return nil
+
+}
+
+// Error returned when a stream doesn't decrypt to the expected length
+var ErrLengthMismatch = errors.New("decrypted length doesn't match the expected length")
+
+// Function to decrypt a stream of data and check its length
+//
+// Use it when the size of the plaintext is known from somewhere else (like
+// metadata) to catch a stream that is shorter or longer than it should be.
+// Decryption stops as soon as the stream goes over the expected length.
+// Data already written to dst when an error is returned must be discarded.
+//
+// Variables to pass in:
+//
+//   dst io.Writer     - Writer to output the decrypted data to
+//   src io.Reader     - Reader to get the encrypted stream from
+//   salt []byte       - Salt to use to create hash
+//   pass string       - Passphrase to use for decryption
+//   expectedLen int64 - Expected length of the plaintext in bytes
+//   opts              - Options (optional)
+//
+// Returns:
+//
+//   error - Error (ErrLengthMismatch if the length doesn't match)
+func DecryptStreamExpect(dst io.Writer, src io.Reader, salt []byte, pass string, expectedLen int64, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("decrypt stream expect: options: %w", err)
+	}
+
+	var n int64
+	err = DecryptEachFrame(src, salt, pass, func(frame []byte) error {
+		n += int64(len(frame))
+		if n > expectedLen {
+			return ErrLengthMismatch
+		}
+		_, err := dst.Write(frame)
+		return err
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	if n != expectedLen {
+		return ErrLengthMismatch
+	}
+
+	if err := syncOutput(dst, o); err != nil {
+		return fmt.Errorf("decrypt stream expect: sync: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to decrypt a stream of data frame by frame
+//
+// fn is called in order with the plaintext of each frame once it has been
+// authenticated, so records can be processed as they arrive without
+// buffering the whole stream. Decryption stops at the first error returned
+// by fn and that error is returned. The frame passed to fn is only valid
+// until fn returns, its buffer is reused for the next frame.
+//
+// Variables to pass in:
+//
+//   src io.Reader              - Reader to get the encrypted stream from
+//   salt []byte                - Salt to use to create hash
+//   pass string                - Passphrase to use for decryption
+//   fn func(frame []byte) error - Function to call with each plaintext frame
+//   opts                       - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptEachFrame(src io.Reader, salt []byte, pass string, fn func(frame []byte) error, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("decrypt stream: options: %w", err)
+	}
+
+	return decryptEachFrame(context.Background(), src, salt, pass, fn, o)
+
+}
+
+// Function to decrypt a stream of data frame by frame with the Options
+// built
+//
+//   ctx  context.Context          - Context
+//   src  io.Reader                - Reader to get the encrypted stream from
+//   salt []byte                   - Salt to use to create hash
+//   pass string                   - Passphrase to use for decryption
+//   fn   func(frame []byte) error - Function to call with each plaintext frame
+//   o    Options                  - Options in use
+func decryptEachFrame(ctx context.Context, src io.Reader, salt []byte, pass string, fn func(frame []byte) error, o Options) error {
+
+	// Count what goes through fn to report it
+	start := time.Now()
+	var n int64
+	count := func(frame []byte) error {
+		n += int64(len(frame))
+		return fn(frame)
+	}
+
+	var err error
+	if o.MinimalOverhead {
+		err = decryptMinimal(src, salt, pass, count, o)
+	} else {
+		err = decryptStream(ctx, src, src, salt, pass, count, o)
+	}
+	o.observe(start, n, err)
+
+	return err
+
+}
+
+// Function to decrypt a stream of data frame by frame, reading the header
+// separately
+//
+// If salt is nil the salt stored in the header is used.
+//
+//   ctx       context.Context          - Context
+//   headerSrc io.Reader                - Reader to get the header from
+//   src       io.Reader                - Reader to get the frames from
+//   salt      []byte                   - Salt to use to create hash
+//   pass      string                   - Passphrase to use for decryption
+//   fn        func(frame []byte) error - Function to call with each plaintext frame
+//   o         Options                  - Options in use
+func decryptStream(ctx context.Context, headerSrc, src io.Reader, salt []byte, pass string, fn func(frame []byte) error, o Options) error {
+
+	fr, err := newFrameReader(ctx, headerSrc, src, salt, pass, o)
+	if err != nil {
+		return err
+	}
+	defer fr.close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		plaintext, err := fr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(plaintext); err != nil {
+			return err
+		}
+		o.progress(fr.emitted, fr.info.Length)
+	}
+
+}
+
+// Reader opening the frames of a stream one at a time
+type frameReader struct {
+	src         io.Reader // Reader the frames come from (through parity if enabled)
+	info        Info
+	aead        cipher.AEAD
+	release     func()
+	ad          []byte
+	chain       []byte // Tag of the previous frame in ad (hash chained streams)
+	nonce       []byte
+	storedNonce int // Size of the nonce stored in each frame
+	maxFrame    int
+	counter     uint64
+	emitted     int64 // Plaintext returned so far
+	offset      int64 // Offset in the stream of the next frame
+	done        bool
+	name        string // Original path sealed in the header, if any
+	o           Options
+
+	b      *[]byte
+	header []byte // Room for a frame header
+	frame  []byte // Room for a frame
+}
+
+// Function to read the header of a stream and derive its key
+//
+// If salt is nil the salt stored in the stream is used.
+//
+//   ctx       context.Context - Context
+//   headerSrc io.Reader       - Reader to get the header from
+//   src       io.Reader       - Reader to get the frames from
+//   salt      []byte          - Salt to use to create hash
+//   pass      string          - Passphrase to use for decryption
+//   o         Options         - Options in use
+func newFrameReader(ctx context.Context, headerSrc, src io.Reader, salt []byte, pass string, o Options) (*frameReader, error) {
+
+	info, raw, err := readHeader(headerSrc)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt stream: header: %w", err)
+	}
+	if info.OneShot {
+		return nil, errors.New("not a stream: data encrypted by Encrypt")
+	}
+
+	// The canary directly follows the header, read it before the salt
+	// trailer takes over src
+	var canary []byte
+	if info.Canary {
+		canary = make([]byte, canarySize)
+		if _, err := io.ReadFull(headerSrc, canary); err != nil {
+			return nil, fmt.Errorf("decrypt stream: canary: %w", err)
+		}
+	}
+
+	// The time is authenticated with every frame, so changing it in the
+	// header only makes decryption fail
+	if !info.NotBefore.IsZero() && o.now().Before(info.NotBefore) {
+		return nil, ErrNotYetValid
+	}
+
+	// The key depends on the purpose anyway, checking it first gives a clear
+	// error instead of a failed authentication
+	if info.Purpose != o.Purpose {
+		return nil, ErrPurposeMismatch
+	}
+
+	// Checked before deriving the key so a mismatch gets a precise error
+	// rather than a failed authentication
+	if err := checkAlgorithm(info.Algorithm, o.Cipher); err != nil {
+		return nil, fmt.Errorf("decrypt stream: header: %w", err)
+	}
+	if err := checkKDF(info.KDF, o.KDF); err != nil {
+		return nil, fmt.Errorf("decrypt stream: header: %w", err)
+	}
+
+	if info.SaltTrailer {
+		var trailer []byte
+		src, trailer, err = readSaltTrailer(src)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt stream: salt: %w", err)
+		}
+		if salt == nil {
+			salt = trailer
+		}
+	}
+
+	if salt == nil {
+		salt = info.Salt
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("no salt given or stored in the header")
+	}
+
+	// The parameters recorded in the header take precedence
+	kdf := o
+	kdf.KDF = info.KDF
+	_, key, err := createHashContext(ctx, salt, pass, kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, release, err := newAEAD(info.Algorithm, key, o.keys)
+	Scrub(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt stream: cipher: %w", err)
+	}
+
+	// A wrong passphrase fails here, before any frame is read
+	if info.Canary {
+		if err := openCanary(aead, raw, canary); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	fr := &frameReader{src: src, info: info, aead: aead, release: release, ad: newFrameAD(raw, info.HashChain), offset: int64(len(raw)), o: o}
+	if info.Canary {
+		fr.offset += canarySize
+	}
+	if info.sealedName != nil {
+		if fr.name, err = openName(aead, info.sealedName); err != nil {
+			release()
+			return nil, fmt.Errorf("decrypt stream: name: %w", err)
+		}
+	}
+	fr.chain = chainSlot(fr.ad, raw, info.HashChain)
+
+	// Frames only carry their nonce when it isn't derived from the header
+	fr.storedNonce = aead.NonceSize()
+	if info.NonceBase != nil {
+		fr.storedNonce = 0
+		fr.nonce = make([]byte, aead.NonceSize())
+	}
+	fr.maxFrame = fr.storedNonce + info.ChunkSize + aead.Overhead()
+	if info.Parity.enabled() {
+		fr.src, err = newParityReader(src, info.Parity, frameHeaderSize+fr.maxFrame)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("decrypt stream: parity: %w", err)
+		}
+	}
+
+	fr.b = getBuffer(o.BufferPool, frameHeaderSize+fr.maxFrame)
+	fr.header, fr.frame = (*fr.b)[:frameHeaderSize], (*fr.b)[frameHeaderSize:frameHeaderSize+fr.maxFrame]
+
+	return fr, nil
+
+}
+
+// Function to read and open the next frame
+//
+// Returns io.EOF once the final frame has been returned. The plaintext is
+// only valid until the next call, its buffer is reused.
+func (fr *frameReader) next() ([]byte, error) {
+
+	if fr.done {
+		return nil, io.EOF
+	}
+
+	// Stop before reading anything more once the frame limit is reached
+	if fr.o.MaxFrames > 0 && fr.counter >= uint64(fr.o.MaxFrames) {
+		return nil, ErrTooLarge
+	}
+
+	if _, err := io.ReadFull(fr.src, fr.header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fr.truncated("missing final frame")
+		}
+		return nil, err
+	}
+
+	flags := fr.header[0]
+	size := int(binary.BigEndian.Uint32(fr.header[1:]))
+	if size < fr.storedNonce+fr.aead.Overhead() || size > fr.maxFrame {
+		return nil, fmt.Errorf("stream %w: invalid frame size", ErrCorrupted)
+	}
+	// The size of the plaintext is known before reading the frame
+	plainSize := int64(size - fr.storedNonce - fr.aead.Overhead())
+	if fr.o.MaxOutputSize > 0 && fr.emitted+plainSize > fr.o.MaxOutputSize {
+		return nil, ErrTooLarge
+	}
+
+	body := fr.frame[:size]
+	if _, err := io.ReadFull(fr.src, body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fr.truncated("incomplete frame")
+		}
+		return nil, err
+	}
+
+	nonce, ciphertext := fr.nonce, body[fr.storedNonce:]
+	if fr.info.NonceBase != nil {
+		if _, err := deriveNonce(nonce, fr.info.NonceBase, fr.counter); err != nil {
+			return nil, fmt.Errorf("decrypt stream: frame: %w", err)
+		}
+	} else {
+		nonce = body[:fr.storedNonce]
+	}
+	// Keep the tag before opening in place, it's chained into the next frame
+	var tag [chainSize]byte
+	copy(tag[:], ciphertext[len(ciphertext)-fr.aead.Overhead():])
+
+	plaintext, err := fr.aead.Open(ciphertext[:0], nonce, ciphertext, frameAD(fr.ad, fr.counter, flags))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt stream: open: %w", ErrAuthFailed)
+	}
+	fr.counter++
+	fr.emitted += int64(len(plaintext))
+	stored := int64(frameHeaderSize + size)
+	if fr.info.Parity.enabled() {
+		stored = fr.info.Parity.blockSize(stored)
+	}
+	fr.offset += stored
+	copy(fr.chain, tag[:])
+
+	if flags&frameFinal != 0 {
+		// Nothing may follow the final frame
+		if n, _ := fr.src.Read(make([]byte, 1)); n != 0 {
+			return nil, fmt.Errorf("stream %w: data after final frame", ErrCorrupted)
+		}
+		if fr.info.Length > 0 && fr.emitted != fr.info.Length {
+			return nil, fmt.Errorf("stream %w: length doesn't match the header", ErrCorrupted)
+		}
+		fr.done = true
+	}
+
+	return plaintext, nil
+
+}
+
+// Function to build the error of a stream that ends too early, with how
+// much of it was decrypted when its length is in the header
+//
+//   what string - What is missing
+func (fr *frameReader) truncated(what string) error {
+
+	if fr.info.Length > 0 {
+		return fmt.Errorf("stream %w: %s (%d of %d bytes decrypted)", ErrTruncated, what, fr.emitted, fr.info.Length)
+	}
+
+	return fmt.Errorf("stream %w: %s", ErrTruncated, what)
+
+}
+
+// Function to release the AEAD and buffer of a frameReader
+func (fr *frameReader) close() {
+	fr.release()
+	putBuffer(fr.o.BufferPool, fr.b)
+}
@@ -0,0 +1,386 @@
+package gocrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+// Function to encrypt data as a stream for a test
+//
+//   t    testing.TB - Test
+//   data []byte     - Data to encrypt
+//   opts []Option   - Options
+func encryptTestStream(t testing.TB, data []byte, opts ...Option) ([]byte, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(data), "pass", append([]Option{testKDF}, opts...)...)
+	if err != nil {
+		t.Fatalf("encrypt stream: %v", err)
+	}
+	return buf.Bytes(), meta.Salt
+}
+
+func TestStream(t *testing.T) {
+
+	for _, n := range []int{0, 1, chunkSize, chunkSize + 1, 3*chunkSize + 100} {
+		data := make([]byte, n)
+		rand.Read(data)
+		ct, salt := encryptTestStream(t, data)
+
+		var out bytes.Buffer
+		if err := DecryptStream(&out, bytes.NewReader(ct), salt, "pass"); err != nil {
+			t.Fatalf("%d bytes: decrypt: %v", n, err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("%d bytes: round trip mismatch", n)
+		}
+		if err := DecryptStream(&out, bytes.NewReader(ct[:len(ct)-1]), salt, "pass"); err == nil {
+			t.Fatalf("%d bytes: truncation not detected", n)
+		}
+		if err := DecryptStream(&out, bytes.NewReader(ct), salt, "wrong"); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("%d bytes: got %v, want ErrAuthFailed", n, err)
+		}
+	}
+
+}
+
+func TestDecryptEachFrame(t *testing.T) {
+
+	data := make([]byte, 3*minChunkSize+10)
+	rand.Read(data)
+	ct, salt := encryptTestStream(t, data, WithChunkSize(minChunkSize))
+
+	var got []byte
+	frames := 0
+	err := DecryptEachFrame(bytes.NewReader(ct), salt, "pass", func(frame []byte) error {
+		frames++
+		got = append(got, frame...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames != 4 || !bytes.Equal(got, data) {
+		t.Fatalf("got %d frames and %d bytes, want 4 and %d", frames, len(got), len(data))
+	}
+
+	// An error from fn stops decryption and is returned as is
+	stop := errors.New("stop")
+	frames = 0
+	err = DecryptEachFrame(bytes.NewReader(ct), salt, "pass", func(frame []byte) error {
+		frames++
+		if frames == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || frames != 2 {
+		t.Fatalf("got %v after %d frames, want stop after 2", err, frames)
+	}
+
+}
+
+func TestStreamDigest(t *testing.T) {
+
+	data := make([]byte, 2*chunkSize+7)
+	rand.Read(data)
+
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(data), "pass", testKDF, WithDigest(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(data)
+	if !bytes.Equal(meta.Digest, want[:]) || meta.Size != int64(len(data)) {
+		t.Fatalf("got digest %x and size %d, want %x and %d", meta.Digest, meta.Size, want, len(data))
+	}
+
+	meta, err = EncryptStream(&buf, bytes.NewReader(data), "pass", testKDF)
+	if err != nil || meta.Digest != nil {
+		t.Fatalf("got digest %x, %v without WithDigest", meta.Digest, err)
+	}
+
+}
+
+func TestStreamBufferPool(t *testing.T) {
+
+	pool := &sync.Pool{}
+	data := make([]byte, 5*minChunkSize+3)
+	rand.Read(data)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ct, out bytes.Buffer
+			meta, err := EncryptStream(&ct, bytes.NewReader(data), "pass", testKDF, WithChunkSize(minChunkSize), WithBufferPool(pool))
+			if err == nil {
+				err = DecryptStream(&out, &ct, meta.Salt, "pass", WithBufferPool(pool))
+			}
+			if err == nil && !bytes.Equal(out.Bytes(), data) {
+				err = errors.New("round trip mismatch")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+}
+
+// Encrypts a 64 frame stream: the allocations per op are those of a whole
+// stream, so near zero per chunk
+func BenchmarkStreamBufferPool(b *testing.B) {
+
+	data := make([]byte, 64*chunkSize)
+	rand.Read(data)
+
+	for _, pool := range []*sync.Pool{nil, {}} {
+		name := "unpooled"
+		if pool != nil {
+			name = "pooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := EncryptStream(io.Discard, bytes.NewReader(data), "pass", testKDF, WithBufferPool(pool)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+}
+
+// Reader counting the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestMaxFrames(t *testing.T) {
+
+	data := make([]byte, 10*minChunkSize)
+	ct, salt := encryptTestStream(t, data, WithChunkSize(minChunkSize))
+
+	// Stops right after the frames allowed rather than reading the rest
+	src := &countingReader{r: bytes.NewReader(ct)}
+	var out bytes.Buffer
+	err := DecryptStream(&out, src, salt, "pass", WithMaxFrames(3))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+	if out.Len() > 3*minChunkSize || src.n > len(ct)/2 {
+		t.Fatalf("wrote %d bytes and read %d of %d before stopping", out.Len(), src.n, len(ct))
+	}
+
+	out.Reset()
+	if err := DecryptStream(&out, bytes.NewReader(ct), salt, "pass", WithMaxFrames(10)); err != nil || out.Len() != len(data) {
+		t.Fatalf("got %d bytes, %v at the limit", out.Len(), err)
+	}
+
+}
+
+func TestMaxOutputSize(t *testing.T) {
+
+	data := make([]byte, 10*minChunkSize)
+	ct, salt := encryptTestStream(t, data, WithChunkSize(minChunkSize))
+
+	// The frame that would go over the limit is neither written nor read
+	src := &countingReader{r: bytes.NewReader(ct)}
+	var out bytes.Buffer
+	err := DecryptStream(&out, src, salt, "pass", WithMaxOutputSize(3*minChunkSize+minChunkSize/2))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+	if out.Len() != 3*minChunkSize || src.n > len(ct)/2 {
+		t.Fatalf("wrote %d bytes and read %d of %d before stopping", out.Len(), src.n, len(ct))
+	}
+
+	out.Reset()
+	if err := DecryptStream(&out, bytes.NewReader(ct), salt, "pass", WithMaxOutputSize(int64(len(data)))); err != nil || out.Len() != len(data) {
+		t.Fatalf("got %d bytes, %v at the limit", out.Len(), err)
+	}
+
+	// Data encrypted by Encrypt is checked as a whole
+	ciphertext, salt, err := Encrypt(data, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext, salt, "pass", testKDF, WithMaxOutputSize(int64(len(data)-1))); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+	if _, err := Decrypt(ciphertext, salt, "pass", testKDF, WithMaxOutputSize(int64(len(data)))); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+// Reader returning io.ErrUnexpectedEOF instead of io.EOF, like a cut
+// connection
+type cutReader struct {
+	r io.Reader
+}
+
+func (c cutReader) Read(p []byte) (int, error) {
+
+	n, err := c.r.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+
+}
+
+func TestStreamPartialReads(t *testing.T) {
+
+	data := make([]byte, 2*minChunkSize+100)
+	rand.Read(data)
+
+	// Short reads fill whole chunks, the final short chunk is data
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, iotest.HalfReader(iotest.DataErrReader(bytes.NewReader(data))), "pass", testKDF, WithChunkSize(minChunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := encryptTestStream(t, data, WithChunkSize(minChunkSize))
+	if buf.Len() != len(want) {
+		t.Fatalf("got %d bytes, want %d as with full reads", buf.Len(), len(want))
+	}
+	var out bytes.Buffer
+	if err := DecryptStream(&out, iotest.OneByteReader(bytes.NewReader(buf.Bytes())), meta.Salt, "pass"); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("got %d bytes, %v", out.Len(), err)
+	}
+
+	// A source cut off isn't sealed as complete
+	_, err = EncryptStream(ioutil.Discard, cutReader{bytes.NewReader(data)}, "pass", testKDF)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	// Neither is a stream missing the end of its final frame
+	ct := buf.Bytes()
+	for _, cut := range []int{1, 20, minChunkSize / 2} {
+		err := DecryptStream(ioutil.Discard, bytes.NewReader(ct[:len(ct)-cut]), meta.Salt, "pass")
+		if !errors.Is(err, ErrTruncated) {
+			t.Fatalf("cut %d: got %v, want ErrTruncated", cut, err)
+		}
+	}
+
+}
+
+func TestSingleFrame(t *testing.T) {
+
+	o, err := NewOptions(testKDF, WithChunkSize(minChunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, minChunkSize - 1, minChunkSize, minChunkSize + 1, 3 * minChunkSize} {
+
+		data := bytes.Repeat([]byte{7}, size)
+		var buf bytes.Buffer
+		meta, err := EncryptStream(&buf, iotest.HalfReader(bytes.NewReader(data)), "pass", testKDF, WithChunkSize(minChunkSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(buf.Len()) != CiphertextSize(int64(size), o) {
+			t.Fatalf("%d bytes: got %d bytes, CiphertextSize says %d", size, buf.Len(), CiphertextSize(int64(size), o))
+		}
+
+		// Inputs up to a chunk fit in one frame, with no empty final frame
+		want := (size + minChunkSize - 1) / minChunkSize
+		if want == 0 {
+			want = 1
+		}
+		var frames int
+		var out bytes.Buffer
+		err = DecryptEachFrame(bytes.NewReader(buf.Bytes()), meta.Salt, "pass", func(frame []byte) error {
+			frames++
+			out.Write(frame)
+			return nil
+		})
+		if err != nil || !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("%d bytes: got %d bytes, %v", size, out.Len(), err)
+		}
+		if frames != want {
+			t.Fatalf("%d bytes: got %d frames, want %d", size, frames, want)
+		}
+
+	}
+
+}
+
+// Reader cancelling a context once it has returned n bytes
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.n -= n; c.n <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestEncryptStreamContext(t *testing.T) {
+
+	data := make([]byte, 8*minChunkSize)
+	rand.Read(data)
+
+	// Nothing is read or written once ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	src := &countingReader{r: bytes.NewReader(data)}
+	if _, err := EncryptStreamContext(ctx, &buf, src, "pass", testKDF); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if src.n != 0 || buf.Len() != 0 {
+		t.Fatalf("read %d and wrote %d bytes, want none", src.n, buf.Len())
+	}
+
+	// Cancelled part way, the rest isn't read and the output isn't a
+	// complete stream
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	src = &countingReader{r: &cancelReader{r: bytes.NewReader(data), n: 2 * minChunkSize, cancel: cancel}}
+	_, err := EncryptStreamContext(ctx, &buf, src, "pass", testKDF, WithChunkSize(minChunkSize), WithSaltPosition(SaltHeader))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if src.n >= len(data) {
+		t.Fatalf("read all %d bytes, want the work stopped", src.n)
+	}
+	err = DecryptStream(ioutil.Discard, bytes.NewReader(buf.Bytes()), nil, "pass")
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated for the partial output", err)
+	}
+
+}
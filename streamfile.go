@@ -0,0 +1,165 @@
+package gocrypt
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Function to encrypt an existing file as a stream
+//
+// Unlike EncryptFile the file is never loaded in memory, it's read and
+// sealed in chunks (see EncryptStream), so files of any size can be
+// encrypted. The output goes to a .3dfx file and, unless
+// Options.SaltPosition stores it in the stream, the salt to a .salt file
+// next to it. Decrypt it with DecryptFileStream.
+//
+// Variables to pass in:
+//
+//   file string - Name of the file
+//   from string - Specify path of file
+//   to   string - Specify destination path to output file
+//                 (must end with "/" ie. /opt/app/ instead of /opt/app)
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func EncryptFileStream(file string, from string, to string, passphrase string, opts ...Option) error {
+
+	return encryptFileStream(context.Background(), from+file, to+file, passphrase, opts)
+
+}
+
+// Function to encrypt a file as a stream to toFile + ".3dfx"
+//
+//   ctx    context.Context - Context
+//   path   string          - Path of the file
+//   toFile string          - Path of the output, without the .3dfx extension
+//   pass   string          - Passphrase to use for encryption
+//   opts   []Option        - Options
+func encryptFileStream(ctx context.Context, path, toFile, passphrase string, opts []Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("encrypt file stream: options: %w", err)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("encrypt file stream: open file: %w", err)
+	}
+	defer src.Close()
+
+	// The size is recorded so a truncated copy can be told apart from a
+	// wrong passphrase
+	if fi, err := src.Stat(); err == nil && fi.Mode().IsRegular() {
+		opts = append(opts[:len(opts):len(opts)], withLength(fi.Size()))
+	}
+
+	xf, err := os.Create(toFile + ".3dfx")
+	if err != nil {
+		return fmt.Errorf("encrypt file stream: create encrypted file: %w", err)
+	}
+
+	meta, err := EncryptStreamContext(ctx, xf, src, passphrase, opts...)
+	if cerr := xf.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Don't leave a partial stream behind
+		os.Remove(toFile + ".3dfx")
+		return err
+	}
+
+	// The salt of a minimal stream is always detached
+	if o.SaltPosition != SaltDetached && !o.MinimalOverhead {
+		return nil
+	}
+
+	sf, err := os.Create(toFile + ".salt")
+	if err != nil {
+		return fmt.Errorf("encrypt file stream: create salt file: %w", err)
+	}
+	defer sf.Close()
+
+	if _, err := sf.Write(meta.Salt); err != nil {
+		return fmt.Errorf("encrypt file stream: write salt file: %w", err)
+	}
+	if err := syncOutput(sf, o); err != nil {
+		return fmt.Errorf("encrypt file stream: sync salt file: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to decrypt a file encrypted by EncryptFileStream
+//
+// The file is decrypted in chunks and never loaded in memory. A truncated
+// file fails with ErrTruncated and a tampered one with an error, but the
+// output may already hold the data decrypted before the failure: it's
+// removed so partial plaintext is never left behind.
+//
+// Variables to pass in:
+//
+//   file string - Name of the file (without the .3dfx extension)
+//   from string - Specify path of file
+//   to   string - Specify destination path to output file
+//                 (must end with "/" ie. /opt/app/ instead of /opt/app)
+//   pass string - Passphrase to use for decryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func DecryptFileStream(file string, from string, to string, passphrase string, opts ...Option) error {
+
+	return decryptFileStream(context.Background(), from+file, to+file, passphrase, opts)
+
+}
+
+// Function to decrypt path + ".3dfx" as a stream to toFile
+//
+//   ctx    context.Context - Context
+//   path   string          - Path of the file, without the .3dfx extension
+//   toFile string          - Path of the output
+//   pass   string          - Passphrase to use for decryption
+//   opts   []Option        - Options
+func decryptFileStream(ctx context.Context, path, toFile, passphrase string, opts []Option) error {
+
+	if _, err := newOptions(opts); err != nil {
+		return fmt.Errorf("decrypt file stream: options: %w", err)
+	}
+
+	src, err := os.Open(path + ".3dfx")
+	if err != nil {
+		return fmt.Errorf("decrypt file stream: open file: %w", err)
+	}
+	defer src.Close()
+
+	// Streams keeping their salt in the header or trailer have no salt file
+	salt, err := ioutil.ReadFile(path + ".salt")
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("decrypt file stream: read salt file: %w", err)
+	}
+
+	xf, err := os.Create(toFile)
+	if err != nil {
+		return fmt.Errorf("decrypt file stream: create file: %w", err)
+	}
+
+	err = DecryptStreamContext(ctx, xf, src, salt, passphrase, opts...)
+	if cerr := xf.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(toFile)
+		return err
+	}
+
+	return nil
+
+}
@@ -0,0 +1,49 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStreamID(t *testing.T) {
+
+	idA, idB := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+	data := make([]byte, 2*minChunkSize)
+	opts := []Option{WithSaltPosition(SaltHeader), WithChunkSize(minChunkSize)}
+	a, _ := encryptTestStream(t, data, append(opts, WithStreamID(idA))...)
+	b, _ := encryptTestStream(t, data, append(opts, WithStreamID(idB))...)
+
+	info, err := Inspect(bytes.NewReader(a))
+	if err != nil || !bytes.Equal(info.StreamID, idA) {
+		t.Fatalf("got %q, %v, want %q", info.StreamID, err, idA)
+	}
+	o, _ := NewOptions(append(opts, testKDF, WithStreamID(idA))...)
+	if int64(len(a)) != CiphertextSize(int64(len(data)), o) {
+		t.Fatalf("got %d bytes, CiphertextSize disagrees", len(a))
+	}
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(a), nil, "pass"); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("got %d bytes, %v", out.Len(), err)
+	}
+
+	// The first frame of a followed by the last frame of b
+	_, header, _ := readHeader(bytes.NewReader(a))
+	frame := frameHeaderSize + 12 + minChunkSize + 16
+	spliced := append(append([]byte(nil), a[:len(header)+frame]...), b[len(b)-frame:]...)
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(spliced), nil, "pass"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	// Nor can the id be rewritten
+	relabeled := bytes.Replace(a, idA, idB, 1)
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(relabeled), nil, "pass"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	if _, err := NewOptions(WithStreamID(make([]byte, maxStreamIDSize+1))); err == nil {
+		t.Fatal("overlong stream id accepted")
+	}
+
+}
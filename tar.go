@@ -0,0 +1,100 @@
+package gocrypt
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+)
+
+// Function to encrypt the entries of a tar stream
+//
+// The entries are re-emitted as a tar archive through the streaming
+// encryptor as they are read, so nothing is staged on disk. The salt is
+// stored in the stream header, so only the passphrase is needed to decrypt.
+//
+// Variables to pass in:
+//
+//   tr *tar.Reader - Tar stream to encrypt
+//   out io.Writer  - Writer to output the encrypted stream to
+//   pass string    - Passphrase to use for encryption
+//   opts           - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func EncryptTarStream(tr *tar.Reader, out io.Writer, pass string, opts ...Option) error {
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(copyTar(tar.NewWriter(pw), tr))
+	}()
+
+	opts = append(opts[:len(opts):len(opts)], WithSaltPosition(SaltHeader))
+	_, err := EncryptStream(out, pr, pass, opts...)
+	// Unblock the tar writer if encryption stopped early
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("encrypt tar stream: encrypt: %w", err)
+	}
+
+	return nil
+
+}
+
+// Function to decrypt a tar stream encrypted by EncryptTarStream
+//
+// Decryption happens in the background as the returned reader is consumed.
+// Errors, including authentication failures, are returned by the reader's
+// Next and Read methods. Read the archive to the end (io.EOF) so the
+// background decryption can finish.
+//
+// Variables to pass in:
+//
+//   in io.Reader - Reader to get the encrypted stream from
+//   pass string  - Passphrase to use for decryption
+//   opts         - Options (optional)
+//
+// Returns:
+//
+//   *tar.Reader - Decrypted tar stream
+//   error       - Error
+func DecryptTarStream(in io.Reader, pass string, opts ...Option) (*tar.Reader, error) {
+
+	if _, err := newOptions(opts); err != nil {
+		return nil, fmt.Errorf("decrypt tar stream: options: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(DecryptStream(pw, in, nil, pass, opts...))
+	}()
+
+	return tar.NewReader(pr), nil
+
+}
+
+// Function to copy every entry of a tar stream to a tar writer
+//
+//   tw *tar.Writer - Writer to copy the entries to
+//   tr *tar.Reader - Reader to copy the entries from
+func copyTar(tw *tar.Writer, tr *tar.Reader) error {
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+
+}
@@ -0,0 +1,75 @@
+package gocrypt
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestTarStream(t *testing.T) {
+
+	entries := map[string][]byte{
+		"a.txt":     []byte("hello"),
+		"dir/b.bin": bytes.Repeat([]byte{0xab}, 3*chunkSize+7),
+	}
+	names := []string{"a.txt", "dir/b.bin"}
+
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	for _, name := range names {
+		body := entries[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var enc bytes.Buffer
+	if err := EncryptTarStream(tar.NewReader(bytes.NewReader(in.Bytes())), &enc, "pass", testKDF); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(enc.Bytes(), []byte("dir/b.bin")) {
+		t.Fatal("entry names left in the clear")
+	}
+
+	tr, err := DecryptTarStream(bytes.NewReader(enc.Bytes()), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(tr)
+		if err != nil || !bytes.Equal(body, entries[h.Name]) {
+			t.Fatalf("%s: got %d bytes, %v, want %d", h.Name, len(body), err, len(entries[h.Name]))
+		}
+		got = append(got, h.Name)
+	}
+	if len(got) != len(names) || got[0] != names[0] || got[1] != names[1] {
+		t.Fatalf("got %v, want %v", got, names)
+	}
+
+	// Errors come from the reader as it's consumed
+	tr, err = DecryptTarStream(bytes.NewReader(enc.Bytes()), "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Next(); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+}
@@ -0,0 +1,55 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// Error wrapped by the errors DecryptTemplate returns when the plaintext
+// decrypts but can't be rendered
+var ErrTemplate = errors.New("template error")
+
+// Function to decrypt a text/template and render it
+//
+// Referencing a variable missing from vars is an error rather than
+// rendering "<no value>". Errors parsing or executing the template wrap
+// ErrTemplate, so they can be told apart from decryption errors with
+// errors.Is.
+//
+// Variables to pass in:
+//
+//   data []byte         - Encrypted template
+//   salt []byte         - Salt to use to create hash
+//   pass string         - Passphrase to use for decryption
+//   vars map[string]any - Variables to render the template with
+//   opts                - Options (optional)
+//
+// Returns:
+//
+//   []byte - Rendered template
+//   error  - Error
+func DecryptTemplate(data, salt []byte, pass string, vars map[string]any, opts ...Option) ([]byte, error) {
+
+	plaintext, err := Decrypt(data, salt, pass, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer Scrub(plaintext)
+
+	tmpl, err := template.New("gocrypt").Option("missingkey=error").Parse(string(plaintext))
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrTemplate, err)
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		err = fmt.Errorf("%w: %v", ErrTemplate, err)
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+
+}
@@ -0,0 +1,40 @@
+package gocrypt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecryptTemplate(t *testing.T) {
+
+	ciphertext, salt, err := Encrypt([]byte("host={{.host}} port={{.port}}"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecryptTemplate(ciphertext, salt, "pass", map[string]any{"host": "db", "port": 5432}, testKDF)
+	if err != nil || string(out) != "host=db port=5432" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+
+	// A missing variable is an error rather than "<no value>"
+	_, err = DecryptTemplate(ciphertext, salt, "pass", map[string]any{"host": "db"}, testKDF)
+	if !errors.Is(err, ErrTemplate) || !strings.Contains(err.Error(), "port") {
+		t.Fatalf("got %v, want ErrTemplate naming the variable", err)
+	}
+
+	invalid, salt2, err := Encrypt([]byte("{{.host"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptTemplate(invalid, salt2, "pass", nil, testKDF); !errors.Is(err, ErrTemplate) {
+		t.Fatalf("got %v, want ErrTemplate", err)
+	}
+
+	// Decryption errors aren't template errors
+	_, err = DecryptTemplate(ciphertext, salt, "wrong", nil, testKDF)
+	if !errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrTemplate) {
+		t.Fatalf("got %v, want ErrAuthFailed only", err)
+	}
+
+}
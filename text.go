@@ -0,0 +1,238 @@
+package gocrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Line ending style decrypted text is normalized to with Options.TextMode
+type LineEnding int
+
+const (
+	LineEndingNative LineEnding = iota // CRLF on Windows, LF elsewhere
+	LineEndingLF                       // Unix style "\n"
+	LineEndingCRLF                     // Windows style "\r\n"
+	LineEndingKeep                     // Leave line endings untouched
+)
+
+// Byte order marks recognized in text mode
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Writer converting decrypted text as it goes through
+//
+// A UTF-8 byte order mark is stripped, UTF-16 text with a byte order mark
+// is converted to UTF-8 and CRLF or LF line endings are rewritten to the
+// configured style. A lone CR is left as is. Close must be called to flush
+// what is held back between writes.
+type textWriter struct {
+	w       io.Writer
+	ending  []byte
+	head    []byte           // Start of the text, until its BOM is known
+	started bool             // The BOM has been handled
+	order   binary.ByteOrder // Byte order of UTF-16 text, nil for UTF-8
+	odd     []byte           // Trailing byte of an incomplete UTF-16 unit
+	high    rune             // High surrogate waiting for its low half
+	cr      bool             // Trailing CR waiting to see if LF follows
+	out     []byte
+}
+
+// Function to create a textWriter
+//
+//   w      io.Writer  - Writer to output the converted text to
+//   ending LineEnding - Line ending style to normalize to
+func newTextWriter(w io.Writer, ending LineEnding) *textWriter {
+
+	tw := &textWriter{w: w}
+	switch ending {
+	case LineEndingLF:
+		tw.ending = []byte("\n")
+	case LineEndingCRLF:
+		tw.ending = []byte("\r\n")
+	case LineEndingNative:
+		tw.ending = []byte("\n")
+		if runtime.GOOS == "windows" {
+			tw.ending = []byte("\r\n")
+		}
+	}
+
+	return tw
+
+}
+
+// Function to convert and write text
+func (tw *textWriter) Write(p []byte) (int, error) {
+
+	b := p
+	if !tw.started {
+		tw.head = append(tw.head, p...)
+		// Wait for enough bytes to tell if the text starts with a BOM
+		if len(tw.head) < len(bomUTF8) && (bytes.HasPrefix(bomUTF8, tw.head) ||
+			bytes.HasPrefix(bomUTF16LE, tw.head) || bytes.HasPrefix(bomUTF16BE, tw.head)) {
+			return len(p), nil
+		}
+		b = tw.start()
+	}
+
+	if err := tw.convert(b); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+
+}
+
+// Function to strip the BOM from the start of the text
+func (tw *textWriter) start() []byte {
+
+	tw.started = true
+	b := tw.head
+	tw.head = nil
+
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		return b[len(bomUTF8):]
+	case bytes.HasPrefix(b, bomUTF16LE):
+		tw.order = binary.LittleEndian
+		return b[len(bomUTF16LE):]
+	case bytes.HasPrefix(b, bomUTF16BE):
+		tw.order = binary.BigEndian
+		return b[len(bomUTF16BE):]
+	}
+
+	return b
+
+}
+
+// Function to convert a piece of text and write it
+//
+//   b []byte - Text following what was already written
+func (tw *textWriter) convert(b []byte) error {
+
+	if tw.order != nil {
+		b = tw.decodeUTF16(b)
+	}
+
+	out := tw.out[:0]
+	if tw.ending == nil {
+		out = append(out, b...)
+	} else {
+		for _, c := range b {
+			if tw.cr {
+				tw.cr = false
+				if c == '\n' {
+					out = append(out, tw.ending...)
+					continue
+				}
+				out = append(out, '\r')
+			}
+			switch c {
+			case '\r':
+				tw.cr = true
+			case '\n':
+				out = append(out, tw.ending...)
+			default:
+				out = append(out, c)
+			}
+		}
+	}
+	tw.out = out
+
+	_, err := tw.w.Write(out)
+	return err
+
+}
+
+// Function to decode UTF-16 text to UTF-8
+//
+//   b []byte - UTF-16 text following what was already decoded
+func (tw *textWriter) decodeUTF16(b []byte) []byte {
+
+	if len(tw.odd) > 0 {
+		b = append(tw.odd, b...)
+		tw.odd = nil
+	}
+	if len(b)%2 != 0 {
+		tw.odd = []byte{b[len(b)-1]}
+		b = b[:len(b)-1]
+	}
+
+	var out []byte
+	var buf [utf8.UTFMax]byte
+	for i := 0; i < len(b); i += 2 {
+		r := rune(tw.order.Uint16(b[i:]))
+		if tw.high != 0 {
+			high := tw.high
+			tw.high = 0
+			if pair := utf16.DecodeRune(high, r); pair != utf8.RuneError {
+				n := utf8.EncodeRune(buf[:], pair)
+				out = append(out, buf[:n]...)
+				continue
+			}
+			// Unpaired high surrogate
+			n := utf8.EncodeRune(buf[:], utf8.RuneError)
+			out = append(out, buf[:n]...)
+		}
+		if r >= 0xD800 && r < 0xDC00 {
+			tw.high = r
+			continue
+		}
+		// Unpaired low surrogates are encoded as U+FFFD
+		n := utf8.EncodeRune(buf[:], r)
+		out = append(out, buf[:n]...)
+	}
+
+	return out
+
+}
+
+// Function to flush what is held back
+func (tw *textWriter) Close() error {
+
+	if !tw.started {
+		if err := tw.convert(tw.start()); err != nil {
+			return err
+		}
+	}
+
+	if len(tw.odd) > 0 || tw.high != 0 {
+		return errors.New("text mode: truncated UTF-16 text")
+	}
+
+	if tw.cr {
+		tw.cr = false
+		if _, err := tw.w.Write([]byte{'\r'}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Function to convert decrypted text in one go
+//
+//   data   []byte     - Decrypted text
+//   ending LineEnding - Line ending style to normalize to
+func convertText(data []byte, ending LineEnding) ([]byte, error) {
+
+	var buf bytes.Buffer
+	tw := newTextWriter(&buf, ending)
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+
+}
@@ -0,0 +1,63 @@
+package gocrypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTextMode(t *testing.T) {
+
+	cases := []struct {
+		in, want string
+		ending   LineEnding
+	}{
+		{"\xEF\xBB\xBFa\r\nb\nc\r", "a\nb\nc\r", LineEndingLF},
+		{"a\nb\r\n", "a\r\nb\r\n", LineEndingCRLF},
+		{"\xFF\xFEh\x00\r\x00\n\x00=\xd8\x00\xde", "h\n\U0001F600", LineEndingLF},
+		{"\xFE\xFF\x00h\x00\n", "h\r\n", LineEndingCRLF},
+		{"\xEF\xBB\xBFa\r\n", "a\r\n", LineEndingKeep},
+		{"", "", LineEndingLF},
+		{"\xEF", "\xEF", LineEndingLF},
+	}
+	for _, c := range cases {
+		text := WithTextMode(true, c.ending)
+		ciphertext, salt, err := Encrypt([]byte(c.in), "pass", testKDF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decrypt(ciphertext, salt, "pass", testKDF, text)
+		if err != nil || string(got) != c.want {
+			t.Fatalf("%q: got %q, %v, want %q", c.in, got, err, c.want)
+		}
+
+		stream, streamSalt := encryptTestStream(t, []byte(c.in))
+		var out bytes.Buffer
+		if err := DecryptStream(&out, bytes.NewReader(stream), streamSalt, "pass", text); err != nil || out.String() != c.want {
+			t.Fatalf("stream %q: got %q, %v, want %q", c.in, out.String(), err, c.want)
+		}
+	}
+
+	// A CRLF split across two frames is still one line ending
+	data := strings.Repeat("x", minChunkSize-1) + "\r\nend\r\n"
+	stream, salt := encryptTestStream(t, []byte(data), WithChunkSize(minChunkSize))
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(stream), salt, "pass", WithTextMode(true, LineEndingLF)); err != nil || out.String() != strings.Repeat("x", minChunkSize-1)+"\nend\n" {
+		t.Fatalf("got %d bytes, %v", out.Len(), err)
+	}
+
+	// Binary data is untouched by default
+	binary := []byte{0xEF, 0xBB, 0xBF, '\r', '\n', 0}
+	ciphertext, salt, err := Encrypt(binary, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decrypt(ciphertext, salt, "pass", testKDF); err != nil || !bytes.Equal(got, binary) {
+		t.Fatalf("got %q, %v, want %q", got, err, binary)
+	}
+
+	if _, err := NewOptions(WithTextMode(true, LineEndingKeep+1)); err == nil {
+		t.Fatal("unknown line ending accepted")
+	}
+
+}
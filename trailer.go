@@ -0,0 +1,66 @@
+package gocrypt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Where the salt of a stream is stored
+type SaltPosition int
+
+const (
+	SaltDetached SaltPosition = iota // Not stored, returned for the caller to keep
+	SaltHeader                       // Stored in the header
+	SaltTrailer                      // Stored after the last frame
+)
+
+// Function to read the salt stored after the last frame of a stream
+//
+// The trailer is the salt followed by its length in one byte. Returns a
+// reader limited to the frames so the trailer isn't mistaken for data after
+// the last frame.
+//
+//   src io.Reader - Stream positioned right after the header (must be an io.ReadSeeker)
+func readSaltTrailer(src io.Reader) (io.Reader, []byte, error) {
+
+	rs, ok := src.(io.ReadSeeker)
+	if !ok {
+		return nil, nil, errors.New("salt trailer needs a seekable input")
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	end, err := rs.Seek(-1, io.SeekEnd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stream %w: missing salt trailer", ErrTruncated)
+	}
+
+	n := make([]byte, 1)
+	if _, err := io.ReadFull(rs, n); err != nil {
+		return nil, nil, err
+	}
+
+	saltStart := end - int64(n[0])
+	if n[0] == 0 || saltStart < start {
+		return nil, nil, fmt.Errorf("stream %w: invalid salt trailer", ErrCorrupted)
+	}
+
+	salt := make([]byte, n[0])
+	if _, err := rs.Seek(saltStart, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.ReadFull(rs, salt); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	return io.LimitReader(rs, saltStart-start), salt, nil
+
+}
@@ -0,0 +1,60 @@
+package gocrypt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSaltTrailer(t *testing.T) {
+
+	data := bytes.Repeat([]byte("t"), 2*chunkSize+100)
+	for _, parity := range []bool{false, true} {
+		opts := []Option{WithSaltPosition(SaltTrailer)}
+		if parity {
+			opts = append(opts, WithParity(2, 3))
+		}
+		ct, salt := encryptTestStream(t, data, opts...)
+		if salt == nil || bytes.Contains(ct[:64], salt) {
+			t.Fatal("salt not kept out of the header")
+		}
+		if !bytes.HasSuffix(ct, append(salt, byte(len(salt)))) {
+			t.Fatal("salt not at the end of the stream")
+		}
+
+		// Nothing but the passphrase is needed
+		var out bytes.Buffer
+		if err := DecryptStream(&out, bytes.NewReader(ct), nil, "pass"); err != nil {
+			t.Fatalf("parity %t: %v", parity, err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("parity %t: round trip mismatch", parity)
+		}
+
+		// The trailer is found by seeking to the end
+		if err := DecryptStream(io.Discard, io.MultiReader(bytes.NewReader(ct)), nil, "pass"); err == nil {
+			t.Fatal("decrypted from a reader that can't seek")
+		}
+	}
+
+	// Files with the salt in a trailer have no salt file
+	dir := t.TempDir() + "/"
+	if err := os.WriteFile(dir+"f", data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFileStream("f", dir, dir, "pass", testKDF, WithSaltPosition(SaltTrailer)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir + "f.salt"); !os.IsNotExist(err) {
+		t.Fatalf("salt file written: %v", err)
+	}
+	out := t.TempDir() + "/"
+	if err := DecryptFileStream("f", dir, out, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(out + "f"); err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("round trip mismatch: %v", err)
+	}
+
+}
@@ -0,0 +1,66 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTruncated(t *testing.T) {
+
+	data := make([]byte, 4*minChunkSize+10)
+	ct, salt := encryptTestStream(t, data, WithChunkSize(minChunkSize), withLength(int64(len(data))))
+	if info, err := Inspect(bytes.NewReader(ct)); err != nil || info.Length != int64(len(data)) {
+		t.Fatalf("got %d, %v, want length %d", info.Length, err, len(data))
+	}
+
+	// Cut between frames or in the middle of one, the stream reports how
+	// much of it was decrypted
+	frame := frameHeaderSize + 12 + minChunkSize + 16
+	end := len(ct) - (frameHeaderSize + 12 + 10 + 16)
+	for cut, decrypted := range map[int]int{end: 4 * minChunkSize, end - frame: 3 * minChunkSize, end - frame/2: 3 * minChunkSize} {
+		err := DecryptStream(ioutil.Discard, bytes.NewReader(ct[:cut]), salt, "pass")
+		if !errors.Is(err, ErrTruncated) {
+			t.Fatalf("cut at %d: got %v, want ErrTruncated", cut, err)
+		}
+		if want := fmt.Sprintf("(%d of %d bytes decrypted)", decrypted, len(data)); !strings.Contains(err.Error(), want) {
+			t.Fatalf("cut at %d: got %v, want %s", cut, err, want)
+		}
+	}
+
+	// A wrong passphrase isn't mistaken for truncation
+	if err := DecryptStream(ioutil.Discard, bytes.NewReader(ct), salt, "wrong"); errors.Is(err, ErrTruncated) || !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+
+	// Data encrypted by Encrypt is checked against its length up front
+	ciphertext, salt, err := Encrypt(data, "pass", testKDF, WithSaltPosition(SaltHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext[:len(ciphertext)-100], salt, "pass"); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated", err)
+	}
+
+	// Files encrypted as streams record their size
+	src, dst, out := t.TempDir()+"/", t.TempDir()+"/", t.TempDir()+"/"
+	writeTestFile(t, src+"a.bin", string(data))
+	if err := EncryptFileStream("a.bin", src, dst, "pass", testKDF, WithChunkSize(minChunkSize)); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(dst + "a.bin.3dfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(dst+"a.bin.3dfx", fi.Size()-int64(frame)); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFileStream("a.bin", dst, out, "pass"); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated", err)
+	}
+
+}
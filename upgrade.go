@@ -0,0 +1,83 @@
+package gocrypt
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Function to re-encrypt a file written with the legacy key derivation
+//
+// Files encrypted by versions before the passphrase fix were protected by a
+// key that didn't depend on the passphrase. The file is decrypted with
+// Options.LegacyKeyDerivation and encrypted again with a key derived from
+// the passphrase. The salt is kept, so only the .3dfx file is replaced, in
+// a single rename so an interrupted upgrade leaves the old file intact.
+//
+// Variables to pass in:
+//
+//   file string - Name of the file (without the .3dfx extension)
+//   from string - Specify path of file
+//                 (must end with "/" ie. /opt/app/ instead of /opt/app)
+//   pass string - Passphrase to use for encryption
+//   opts        - Options (optional)
+//
+// Returns:
+//
+//   error - Error
+func UpgradeFile(file string, from string, passphrase string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("upgrade file: options: %w", err)
+	}
+
+	path := from + file + ".3dfx"
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("upgrade file: read file: %w", err)
+	}
+
+	salt, err := ioutil.ReadFile(from + file + ".salt")
+	if err != nil {
+		return fmt.Errorf("upgrade file: read salt: %w", err)
+	}
+
+	legacy := o
+	legacy.LegacyKeyDerivation = true
+	plaintext, err := Decrypt(data, salt, passphrase, WithOptions(legacy))
+	if err != nil {
+		return err
+	}
+	defer Scrub(plaintext)
+
+	current := o
+	current.LegacyKeyDerivation = false
+	ciphertext, _, err := encrypt(context.Background(), plaintext, salt, passphrase, current)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("upgrade file: create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = writeOutput(tmp, ciphertext, o)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("upgrade file: write file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("upgrade file: rename: %w", err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,97 @@
+package gocrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Maximum size of the data that can be put in a URI
+const maxURIData = 4096
+
+// Function to encrypt data into a shareable URI
+//
+// The URI looks like gocrypt://v1?salt=...&n=...&data=... with the salt and
+// encrypted data base64url encoded, so it's self-contained and safe to paste
+// anywhere. Only small payloads (up to 4 KiB) are accepted to keep URIs sane.
+//
+// Variables to pass in:
+//
+//   data []byte - Data to be encrypted
+//   pass string - Passphrase to use for encryption
+//
+// Returns:
+//
+//   string - URI
+//   error  - Error
+func EncryptURI(data []byte, pass string) (string, error) {
+
+	if len(data) > maxURIData {
+		return "", fmt.Errorf("data too large for a URI: %d bytes (max %d)", len(data), maxURIData)
+	}
+
+	ciphertext, salt, err := Encrypt(data, pass)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("salt", base64.RawURLEncoding.EncodeToString(salt))
+	q.Set("n", strconv.Itoa(scryptN))
+	q.Set("data", base64.RawURLEncoding.EncodeToString(ciphertext))
+	u := url.URL{Scheme: "gocrypt", Host: "v1", RawQuery: q.Encode()}
+
+	return u.String(), nil
+
+}
+
+// Function to decrypt data from a URI made by EncryptURI
+//
+// Variables to pass in:
+//
+//   uri string  - URI
+//   pass string - Passphrase to use for decryption
+//
+// Returns:
+//
+//   []byte - Decrypted Data
+//   error  - Error
+func DecryptURI(uri, pass string) ([]byte, error) {
+
+	// Base64 grows the data by a third, leave some room for the rest
+	if len(uri) > 2*maxURIData {
+		return nil, errors.New("URI too large")
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt URI: parse: %w", err)
+	}
+
+	if u.Scheme != "gocrypt" {
+		return nil, fmt.Errorf("invalid URI scheme %q", u.Scheme)
+	}
+	if u.Host != "v1" {
+		return nil, fmt.Errorf("unsupported URI version %q", u.Host)
+	}
+
+	q := u.Query()
+	if n := q.Get("n"); n != strconv.Itoa(scryptN) {
+		return nil, fmt.Errorf("unsupported scrypt cost %q", n)
+	}
+
+	salt, err := base64.RawURLEncoding.DecodeString(q.Get("salt"))
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("invalid salt in URI")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Get("data"))
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid data in URI")
+	}
+
+	return Decrypt(data, salt, pass)
+
+}
@@ -0,0 +1,40 @@
+package gocrypt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncryptURI(t *testing.T) {
+
+	uri, err := EncryptURI([]byte("data"), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(uri, "gocrypt://v1?") {
+		t.Fatalf("got %q", uri)
+	}
+	pt, err := DecryptURI(uri, "pass")
+	if err != nil || string(pt) != "data" {
+		t.Fatalf("got %q, %v", pt, err)
+	}
+
+	malformed := []string{
+		"http://v1?salt=AA&data=AA",
+		"gocrypt://v2?n=32768&salt=AA&data=AA",
+		"gocrypt://v1?n=1024&salt=AA&data=AA",
+		"gocrypt://v1?n=32768&salt=!!&data=AA",
+		"gocrypt://v1?n=32768&salt=AA",
+		"gocrypt://v1?data=" + strings.Repeat("A", 3*maxURIData),
+	}
+	for _, uri := range malformed {
+		if _, err := DecryptURI(uri, "pass"); err == nil {
+			t.Errorf("%.40s: accepted", uri)
+		}
+	}
+
+	if _, err := EncryptURI(make([]byte, maxURIData+1), "pass"); err == nil {
+		t.Fatal("encrypted more than fits in a URI")
+	}
+
+}
@@ -0,0 +1,288 @@
+package gocrypt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Status of a file checked by VerifyDir
+type FileStatus struct {
+	Path string // Path of the file
+	Size int64  // Size of the file in bytes
+	Err  error  // Why the file failed verification (nil if healthy)
+}
+
+// Function to check if a file passed verification
+//
+// Returns:
+//
+//   bool - true if the file is healthy
+func (s FileStatus) OK() bool {
+	return s.Err == nil
+}
+
+// Report of the files checked by VerifyDir
+type VerifyReport struct {
+	Files      []FileStatus // Status of every file checked, sorted by path
+	Healthy    int          // Number of files that passed verification
+	Corrupted  int          // Number of files that failed verification
+	TotalBytes int64        // Total size of the files checked
+}
+
+// Function to verify every encrypted (.3dfx) file in a directory
+//
+// Files are checked in parallel by authenticating their tags, nothing is
+// written to disk. Each file's key is derived from its own salt, read from
+// its .salt file or its header, and with the KDF parameters recorded in its
+// header. Files encrypted by Encrypt and streams written by
+// EncryptFileStream or EncryptDir are both handled. A file that can't be
+// read or doesn't authenticate is reported as corrupted rather than
+// returned as an error.
+//
+// Variables to pass in:
+//
+//   dir string      - Directory to verify (walked recursively)
+//   salt []byte     - Salt of the files with no salt of their own (nil if none)
+//   pass string     - Passphrase used to encrypt the files
+//   concurrency int - Number of files to verify at once
+//   opts            - Options (optional)
+//
+// Returns:
+//
+//   VerifyReport - Report of the files checked
+//   error        - Error
+func VerifyDir(dir string, salt []byte, pass string, concurrency int, opts ...Option) (VerifyReport, error) {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("verify dir: options: %w", err)
+	}
+
+	paths, err := encryptedFiles(dir)
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("verify dir: walk: %w", err)
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	files := make([]FileStatus, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				files[i] = verifyFile(context.Background(), paths[i], salt, pass, o)
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	report := VerifyReport{Files: files}
+	for _, f := range files {
+		report.TotalBytes += f.Size
+		if f.OK() {
+			report.Healthy++
+		} else {
+			report.Corrupted++
+		}
+	}
+
+	return report, nil
+
+}
+
+// Function to list the encrypted (.3dfx) files in a directory
+//
+//   dir string - Directory to walk (recursively)
+func encryptedFiles(dir string) ([]string, error) {
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(path, ".3dfx") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+
+	return paths, err
+
+}
+
+// Function to verify a single encrypted file
+//
+// The salt of the file takes precedence over the one given: its .salt file
+// first, then the one in its header or trailer.
+//
+//   ctx  context.Context - Context
+//   path string          - Path of the file
+//   salt []byte          - Salt to use if the file has none of its own
+//   pass string          - Passphrase the file was encrypted with
+//   o    Options         - Options in use
+func verifyFile(ctx context.Context, path string, salt []byte, pass string, o Options) FileStatus {
+
+	status := FileStatus{Path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	defer f.Close()
+
+	if fi, err := f.Stat(); err == nil {
+		status.Size = fi.Size()
+	}
+
+	own, err := ioutil.ReadFile(strings.TrimSuffix(path, ".3dfx") + ".salt")
+	if err != nil && !os.IsNotExist(err) {
+		status.Err = fmt.Errorf("read salt file: %w", err)
+		return status
+	}
+
+	// Streams are verified frame by frame, anything else as a whole
+	info, _, herr := readHeader(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		status.Err = err
+		return status
+	}
+	if own != nil || len(info.Salt) > 0 || info.SaltTrailer {
+		salt = own
+	}
+	if herr == nil && !info.OneShot && !o.MinimalOverhead {
+		status.Err = verifyStream(ctx, f, salt, pass, o)
+		return status
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	if o.MinimalOverhead {
+		status.Err = decryptMinimal(bytes.NewReader(data), salt, pass, func([]byte) error { return nil }, o)
+		return status
+	}
+
+	plaintext, err := decrypt(ctx, data, salt, pass, o)
+	Scrub(plaintext)
+	status.Err = err
+
+	return status
+
+}
+
+// Function to authenticate every frame of a stream, discarding the
+// plaintext
+//
+//   ctx  context.Context - Context
+//   src  io.Reader       - Reader to get the encrypted stream from
+//   salt []byte          - Salt to use to create hash (nil to use the stored one)
+//   pass string          - Passphrase the stream was encrypted with
+//   o    Options         - Options in use
+func verifyStream(ctx context.Context, src io.Reader, salt []byte, pass string, o Options) error {
+
+	fr, err := newFrameReader(ctx, src, src, salt, pass, o)
+	if err != nil {
+		return err
+	}
+	defer fr.close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := fr.next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+}
+
+// Error returned by VerifyReader when a frame fails verification
+type FrameError struct {
+	Frame uint64 // Index of the frame in the stream, from 0
+	Err   error  // Why the frame failed
+}
+
+// Function to describe the error
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("frame %d: %v", e.Frame, e.Err)
+}
+
+// Function to get the error the frame failed with
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// Function to verify an encrypted stream without keeping the plaintext
+//
+// Every frame is authenticated and its plaintext discarded as soon as it's
+// opened, so nothing but a single frame is ever held in memory. A frame
+// that fails, including a missing final frame, is reported as a
+// *FrameError holding its index. Errors reading the header or deriving the
+// key are returned as is.
+//
+// Variables to pass in:
+//
+//   src io.Reader - Reader to get the encrypted stream from
+//   salt []byte   - Salt to use to create hash (nil to use the stored one)
+//   pass string   - Passphrase to use for decryption
+//   opts          - Options (optional)
+//
+// Returns:
+//
+//   error - Error (a *FrameError if a frame failed)
+func VerifyReader(src io.Reader, salt []byte, pass string, opts ...Option) error {
+
+	o, err := newOptions(opts)
+	if err != nil {
+		return fmt.Errorf("verify reader: options: %w", err)
+	}
+
+	if o.MinimalOverhead {
+		err := decryptMinimal(src, salt, pass, func([]byte) error { return nil }, o)
+		if err != nil {
+			return &FrameError{Frame: 0, Err: err}
+		}
+		return nil
+	}
+
+	fr, err := newFrameReader(context.Background(), src, src, salt, pass, o)
+	if err != nil {
+		return err
+	}
+	defer fr.close()
+
+	for {
+		frame := fr.counter
+		if _, err := fr.next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return &FrameError{Frame: frame, Err: err}
+		}
+	}
+
+}
@@ -0,0 +1,147 @@
+package gocrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyDir(t *testing.T) {
+
+	src, dst := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("file "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := EncryptDir(src, dst, "pass", testKDF); err != nil {
+		t.Fatalf("encrypt dir: %v", err)
+	}
+
+	// One-shot files, with the salt in the header and with a shared salt
+	ct, salt, err := Encrypt([]byte("one-shot"), "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := []byte("0123456789abcdef")
+	withSalt, err := EncryptWithSalt([]byte("shared salt"), shared, "pass", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dst, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("c.3dfx", ct)
+	write("c.salt", salt)
+	write("d.3dfx", withSalt)
+
+	report, err := VerifyDir(dst, shared, "pass", 2)
+	if err != nil {
+		t.Fatalf("verify dir: %v", err)
+	}
+	if report.Healthy != 4 || report.Corrupted != 0 {
+		t.Fatalf("got %d healthy and %d corrupted, want 4 and 0: %+v", report.Healthy, report.Corrupted, report.Files)
+	}
+
+	// Flip the last byte of a stream and of a one-shot file
+	flip := func(name string) {
+		path := filepath.Join(dst, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 1
+		write(name, data)
+	}
+	flip("b.3dfx")
+	flip("c.3dfx")
+	write("e.3dfx", []byte{1})
+
+	report, err = VerifyDir(dst, shared, "pass", 2)
+	if err != nil {
+		t.Fatalf("verify dir: %v", err)
+	}
+	if report.Healthy != 2 || report.Corrupted != 3 {
+		t.Fatalf("got %d healthy and %d corrupted, want 2 and 3: %+v", report.Healthy, report.Corrupted, report.Files)
+	}
+	for _, f := range report.Files {
+		switch filepath.Base(f.Path) {
+		case "a.3dfx", "d.3dfx":
+			if !f.OK() {
+				t.Errorf("%s: %v", f.Path, f.Err)
+			}
+		default:
+			if f.OK() {
+				t.Errorf("%s: corruption not detected", f.Path)
+			}
+		}
+	}
+
+	report, err = VerifyDir(dst, shared, "wrong", 1)
+	if err != nil {
+		t.Fatalf("verify dir: %v", err)
+	}
+	if report.Healthy != 0 {
+		t.Fatalf("%d files verified with the wrong passphrase", report.Healthy)
+	}
+
+}
+
+func TestVerifyReader(t *testing.T) {
+
+	var buf bytes.Buffer
+	meta, err := EncryptStream(&buf, bytes.NewReader(make([]byte, 3000)), "pass", testKDF, WithChunkSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyReader(bytes.NewReader(buf.Bytes()), meta.Salt, "pass", testKDF); err != nil {
+		t.Fatalf("verify reader: %v", err)
+	}
+
+	data := buf.Bytes()
+	data[len(data)-1] ^= 1
+	err = VerifyReader(bytes.NewReader(data), meta.Salt, "pass", testKDF)
+	var fe *FrameError
+	if !errors.As(err, &fe) || fe.Frame != 2 {
+		t.Fatalf("got %v, want an error on frame 2", err)
+	}
+
+}
+
+func TestVerifyReaderFrames(t *testing.T) {
+
+	stream, salt := encryptTestStream(t, make([]byte, 4*minChunkSize), WithChunkSize(minChunkSize))
+	_, header, err := readHeader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := frameHeaderSize + 12 + minChunkSize + 16
+
+	for i := 0; i < 4; i++ {
+		corrupted := append([]byte(nil), stream...)
+		corrupted[len(header)+i*frame+frame/2] ^= 1
+		err := VerifyReader(bytes.NewReader(corrupted), salt, "pass")
+		var fe *FrameError
+		if !errors.As(err, &fe) || fe.Frame != uint64(i) || !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("frame %d corrupted: got %v", i, err)
+		}
+	}
+
+	// A missing final frame is reported at its index too
+	err = VerifyReader(bytes.NewReader(stream[:len(header)+3*frame]), salt, "pass")
+	var fe *FrameError
+	if !errors.As(err, &fe) || fe.Frame != 3 || !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated at frame 3", err)
+	}
+
+	// A wrong passphrase fails on the first frame
+	err = VerifyReader(bytes.NewReader(stream), salt, "wrong")
+	if !errors.As(err, &fe) || fe.Frame != 0 || !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed at frame 0", err)
+	}
+
+}